@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swatto/promtotwilio/pkg/promtotwilio"
+)
+
+// loadOneShotConfig loads the configuration of the commands running the
+// pipeline once, without the inputs, state and leadership shared with the
+// running instances
+func loadOneShotConfig() promtotwilio.Config {
+	opts := loadConfig()
+	opts.NATSURL = ""
+	opts.SQSQueueURL = ""
+	opts.KafkaBrokers = nil
+	opts.GRPCListenAddr = ""
+	opts.LeaderRedisURL = ""
+	opts.StateFile = ""
+	opts.HeartbeatReceiver = ""
+	opts.WatchdogTimeout = 0
+	opts.ArchiveURL = ""
+	return opts
+}
+
+// runSend sends a message, or the alerts of a payload, once without
+// starting the server, returning the exit code
+func runSend(args []string) int {
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	to := flags.String("to", "", "comma separated receivers (default: RECEIVER)")
+	message := flags.String("message", "", "text of the message to send")
+	alertFile := flags.String("alert-file", "", "path of an Alertmanager payload whose alerts to send")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: promtotwilio send [--to <numbers>] --message <text> | --alert-file <payload.json>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*message == "") == (*alertFile == "") {
+		flags.Usage()
+		return 2
+	}
+
+	opts := loadOneShotConfig()
+	m := promtotwilio.NewMOptionsWithHandler(&opts)
+	defer m.Stop()
+
+	if *alertFile != "" {
+		payload, err := ioutil.ReadFile(*alertFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading alert file: %v\n", err)
+			return 1
+		}
+		query := ""
+		if *to != "" {
+			query = "receiver=" + url.QueryEscape(*to)
+		}
+		code, body := m.SendPayload(payload, query)
+		os.Stdout.Write(body)
+		if code >= 300 {
+			fmt.Fprintf(os.Stderr, "Error sending alerts: status %d\n", code)
+			return 1
+		}
+		return 0
+	}
+
+	receivers := promtotwilio.SplitList(*to)
+	if len(receivers) == 0 {
+		receivers = promtotwilio.SplitList(opts.Receiver)
+	}
+	if len(receivers) == 0 {
+		fmt.Fprintln(os.Stderr, "No receiver, set --to or RECEIVER")
+		return 2
+	}
+	status := 0
+	encoder := json.NewEncoder(os.Stdout)
+	for _, receiver := range receivers {
+		result, err := m.SendMessage(receiver, *message)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error sending message to %s: %v\n", receiver, err)
+			status = 1
+			continue
+		}
+		encoder.Encode(result)
+	}
+	return status
+}
+
+// selfTestReceiver is the receiver the synthetic alert of the self-test is
+// rendered for when no message is sent
+const selfTestReceiver = "+15550100000"
+
+// runSelfTest validates the configuration, checks the Twilio credentials
+// and renders a synthetic alert, or sends it, printing a report, and
+// returns the exit code
+func runSelfTest(args []string) int {
+	flags := flag.NewFlagSet("self-test", flag.ContinueOnError)
+	to := flags.String("to", "", "comma separated receivers a test message is sent to, rendered only when empty")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: promtotwilio --self-test [--to <numbers>]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	status := 0
+	report := func(err error, format string, a ...interface{}) {
+		result := "PASS"
+		if err != nil {
+			result = "FAIL"
+			status = 1
+			format += ": %v"
+			a = append(a, err)
+		}
+		fmt.Printf("%s "+format+"\n", append([]interface{}{result}, a...)...)
+	}
+
+	// exits on invalid configuration, with the error
+	opts := loadOneShotConfig()
+	report(nil, "configuration")
+	m := promtotwilio.NewMOptionsWithHandler(&opts)
+	defer m.Stop()
+
+	account, err := m.CheckCredentials()
+	if err == nil && account.Status != "active" {
+		err = fmt.Errorf("account %q is %s", account.FriendlyName, account.Status)
+	}
+	if err == nil {
+		report(nil, "Twilio credentials of account %q", account.FriendlyName)
+	} else {
+		report(err, "Twilio credentials")
+	}
+
+	payload, _ := json.Marshal(syntheticPayload("SelfTest", "info", "Self-test of promtotwilio", nil, false, time.Now()))
+	query := "dry_run=true&receiver=" + url.QueryEscape(selfTestReceiver)
+	if *to != "" {
+		query = "receiver=" + url.QueryEscape(*to)
+	}
+	code, body := m.SendPayload(payload, query)
+	var response promtotwilio.SendResponse
+	if err := json.Unmarshal(body, &response); err != nil || code >= 300 {
+		report(fmt.Errorf("status %d: %s", code, bytes.TrimSpace(body)), "test alert")
+		return status
+	}
+	if len(response.Results) == 0 {
+		fmt.Println("SKIP test message: the test alert was filtered or suppressed")
+	}
+	for _, result := range response.Results {
+		switch {
+		case result.Status == "failed":
+			report(errors.New(result.Error), "test message to %s", result.Receiver)
+		case *to == "":
+			report(nil, "test message rendered: %q", result.Body)
+		default:
+			report(nil, "test message to %s %s (%s)", result.Receiver, result.Status, result.Sid)
+		}
+	}
+	return status
+}
+
+// runTrigger posts a synthetic alert to a running instance, returning the
+// exit code
+func runTrigger(args []string) int {
+	flags := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	bridgeURL := flags.String("url", "http://localhost:9090", "base URL of the instance")
+	alertname := flags.String("alertname", "Test", "name of the alert")
+	severity := flags.String("severity", "critical", "severity label of the alert")
+	summary := flags.String("summary", "Synthetic alert sent by promtotwilio trigger", "summary annotation of the alert")
+	labels := flags.String("labels", "", "comma separated key=value labels added to the alert")
+	receiver := flags.String("receiver", "", "comma separated receivers, the ones of the instance when empty")
+	resolved := flags.Bool("resolved", false, "send the alert as resolved")
+	secret := flags.String("secret", firstSecret(os.Getenv("WEBHOOK_SECRET")), "webhook secret of the instance")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: promtotwilio trigger [--url <url>] [--alertname <name>] [--severity <severity>]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	u, err := url.Parse(*bridgeURL)
+	if err != nil || u.Host == "" {
+		fmt.Fprintln(os.Stderr, "--url must be a URL such as http://bridge:9090")
+		return 2
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/send"
+	if *receiver != "" {
+		u.RawQuery = "receiver=" + url.QueryEscape(*receiver)
+	}
+
+	payload, err := json.Marshal(syntheticPayload(*alertname, *severity, *summary, promtotwilio.SplitMap(*labels), *resolved, time.Now()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building payload: %v\n", err)
+		return 1
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending alert: %v\n", err)
+		return 1
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if *secret != "" {
+		req.Header.Set("Authorization", "Bearer "+*secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending alert: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode >= 300 {
+		fmt.Fprintf(os.Stderr, "Error sending alert: status %d\n", resp.StatusCode)
+		return 1
+	}
+	return 0
+}
+
+// firstSecret returns the first secret of a comma separated list, the one
+// in use during a rotation
+func firstSecret(secrets string) string {
+	if list := promtotwilio.SplitList(secrets); len(list) > 0 {
+		return list[0]
+	}
+	return ""
+}
+
+// syntheticPayload returns an Alertmanager webhook payload of a single
+// alert, shaped like the ones Alertmanager sends
+func syntheticPayload(alertname, severity, summary string, extra map[string]string, resolved bool, now time.Time) map[string]interface{} {
+	labels := map[string]string{"alertname": alertname, "severity": severity}
+	for k, v := range extra {
+		labels[k] = v
+	}
+	annotations := map[string]string{"summary": summary}
+
+	status, endsAt := "firing", time.Time{}
+	if resolved {
+		status, endsAt = "resolved", now
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := fnv.New64a()
+	for _, k := range keys {
+		h.Write([]byte(k + "\xff" + labels[k] + "\xff"))
+	}
+
+	return map[string]interface{}{
+		"version":           "4",
+		"groupKey":          fmt.Sprintf(`{}:{alertname=%q}`, alertname),
+		"truncatedAlerts":   0,
+		"status":            status,
+		"receiver":          "promtotwilio-trigger",
+		"groupLabels":       map[string]string{"alertname": alertname},
+		"commonLabels":      labels,
+		"commonAnnotations": annotations,
+		"externalURL":       "",
+		"alerts": []map[string]interface{}{{
+			"status":       status,
+			"labels":       labels,
+			"annotations":  annotations,
+			"startsAt":     now.Add(-time.Minute).UTC().Format(time.RFC3339),
+			"endsAt":       endsAt.UTC().Format(time.RFC3339),
+			"generatorURL": "",
+			"fingerprint":  fmt.Sprintf("%016x", h.Sum64()),
+		}},
+	}
+}
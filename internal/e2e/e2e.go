@@ -0,0 +1,286 @@
+// Package e2e runs the bridge against a mock of the Twilio API, to test
+// from go test how Alertmanager payloads end up as messages
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// Sender is the number the bridge sends messages from
+	Sender = "+15550000"
+	// Receiver is the default receiver of the bridge
+	Receiver = "+15550001"
+	// AccountSid is the Twilio account of the bridge
+	AccountSid = "AC00000000000000000000000000000000"
+
+	// wait bounds how long messages are waited for
+	wait = 5 * time.Second
+)
+
+var (
+	buildOnce sync.Once
+	binary    string
+	buildErr  error
+)
+
+// build compiles the bridge once for all the tests
+func build() (string, error) {
+	buildOnce.Do(func() {
+		dir, err := ioutil.TempDir("", "promtotwilio-e2e")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		binary = filepath.Join(dir, "promtotwilio")
+		out, err := exec.Command("go", "build", "-o", binary, "github.com/swatto/promtotwilio").CombinedOutput()
+		if err != nil {
+			buildErr = fmt.Errorf("%v: %s", err, out)
+		}
+	})
+	return binary, buildErr
+}
+
+// SMS is a message the bridge sent to the mock of the Twilio API
+type SMS struct {
+	From string
+	To   string
+	Body string
+}
+
+// Bridge is a running bridge whose messages are sent to a mock of the
+// Twilio API
+type Bridge struct {
+	// URL is the base URL of the bridge
+	URL string
+
+	t      testing.TB
+	twilio *httptest.Server
+	cmd    *exec.Cmd
+	env    []string
+
+	mu       sync.Mutex
+	messages []SMS
+}
+
+// Start runs the bridge with its required settings and the given
+// environment variables, as KEY=value, which must be closed with Close
+func Start(t testing.TB, env ...string) *Bridge {
+	t.Helper()
+	path, err := build()
+	if err != nil {
+		t.Fatalf("Error building the bridge: %v", err)
+	}
+
+	b := &Bridge{t: t}
+	b.twilio = httptest.NewServer(http.HandlerFunc(b.receive))
+
+	addr, err := freeAddr()
+	if err != nil {
+		b.twilio.Close()
+		t.Fatalf("Error finding a free port: %v", err)
+	}
+	b.URL = "http://" + addr
+
+	b.cmd = exec.Command(path)
+	b.cmd.Env = append(os.Environ(),
+		"SID="+AccountSid,
+		"TOKEN=secret",
+		"SENDER="+Sender,
+		"RECEIVER="+Receiver,
+		"TWILIO_API_URL="+b.twilio.URL,
+		"LISTEN_ADDR="+addr,
+		"DRAIN_TIMEOUT=1s",
+	)
+	b.cmd.Env = append(b.cmd.Env, env...)
+	b.env = b.cmd.Env
+	if testing.Verbose() {
+		b.cmd.Stdout = os.Stdout
+		b.cmd.Stderr = os.Stderr
+	}
+	if err := b.cmd.Start(); err != nil {
+		b.twilio.Close()
+		t.Fatalf("Error starting the bridge: %v", err)
+	}
+	if err := b.waitReady(); err != nil {
+		b.Close()
+		t.Fatalf("Bridge not ready: %v", err)
+	}
+	return b
+}
+
+func freeAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func (b *Bridge) waitReady() error {
+	deadline := time.Now().Add(wait)
+	for {
+		resp, err := http.Get(b.URL + "/")
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Run runs a command of the bridge, such as send, with its environment,
+// returning its output
+func (b *Bridge) Run(args ...string) (string, error) {
+	cmd := exec.Command(b.cmd.Path, args...)
+	cmd.Env = b.env
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// Close stops the bridge and the mock of the Twilio API
+func (b *Bridge) Close() {
+	if err := b.cmd.Process.Signal(os.Interrupt); err == nil {
+		b.cmd.Wait()
+	}
+	b.twilio.Close()
+}
+
+// receive records the messages sent to the mock of the Twilio API
+func (b *Bridge) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/"+AccountSid+".json") {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"friendly_name": "e2e", "status": "active"}`)
+		return
+	}
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/Messages.json") {
+		http.NotFound(w, r)
+		return
+	}
+	b.mu.Lock()
+	b.messages = append(b.messages, SMS{From: r.FormValue("From"), To: r.FormValue("To"), Body: r.FormValue("Body")})
+	sid := fmt.Sprintf("SM%032d", len(b.messages))
+	b.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"sid": %q, "status": "queued", "num_segments": "1"}`, sid)
+}
+
+// Messages returns the messages sent so far
+func (b *Bridge) Messages() []SMS {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]SMS(nil), b.messages...)
+}
+
+// Reset forgets the messages sent so far
+func (b *Bridge) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = nil
+}
+
+// Send posts the payload to /send with the given query string, and returns
+// the status code of the response
+func (b *Bridge) Send(payload []byte, query string) int {
+	b.t.Helper()
+	url := b.URL + "/send"
+	if query != "" {
+		url += "?" + query
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		b.t.Fatalf("Error sending payload: %v", err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+// ExpectSMS waits for a message to the receiver whose body contains text
+func (b *Bridge) ExpectSMS(to, text string) {
+	b.t.Helper()
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		for _, m := range b.Messages() {
+			if m.To == to && strings.Contains(m.Body, text) {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	b.t.Errorf("no message to %s containing %q, got %+v", to, text, b.Messages())
+}
+
+// ExpectNoSMS checks that no message is sent to the receiver for a while,
+// or to anyone when to is empty
+func (b *Bridge) ExpectNoSMS(to string) {
+	b.t.Helper()
+	time.Sleep(500 * time.Millisecond)
+	for _, m := range b.Messages() {
+		if to == "" || m.To == to {
+			b.t.Errorf("unexpected message %+v", m)
+		}
+	}
+}
+
+// Alert is an alert of a canned payload
+type Alert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+// Firing returns a firing alert with the given name and summary
+func Firing(alertname, summary string) Alert {
+	return Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": alertname},
+		Annotations: map[string]string{"summary": summary},
+		StartsAt:    time.Date(2016, 3, 19, 5, 54, 1, 0, time.UTC),
+	}
+}
+
+// Resolved returns the resolved alert with the given name and summary
+func Resolved(alertname, summary string) Alert {
+	alert := Firing(alertname, summary)
+	alert.Status = "resolved"
+	return alert
+}
+
+// Payload returns the Alertmanager webhook payload of the alerts, whose
+// status is firing when any of them is
+func Payload(alerts ...Alert) []byte {
+	status := "resolved"
+	for _, alert := range alerts {
+		if alert.Status == "firing" {
+			status = "firing"
+		}
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"version":  "4",
+		"status":   status,
+		"receiver": "sms",
+		"groupKey": "{}:{}",
+		"alerts":   alerts,
+	})
+	return payload
+}
@@ -0,0 +1,105 @@
+package e2e
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFiringAlert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the bridge")
+	}
+	b := Start(t)
+	defer b.Close()
+
+	if code := b.Send(Payload(Firing("DiskFull", "Disk full on db-1")), ""); code != http.StatusOK {
+		t.Errorf("Send() == %d, want %d", code, http.StatusOK)
+	}
+	b.ExpectSMS(Receiver, "Disk full on db-1")
+}
+
+func TestResolvedAlert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the bridge")
+	}
+	b := Start(t)
+	defer b.Close()
+
+	b.Send(Payload(Resolved("DiskFull", "Disk full on db-1")), "")
+	b.ExpectNoSMS("")
+}
+
+func TestSendCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the bridge")
+	}
+	b := Start(t)
+	defer b.Close()
+
+	if out, err := b.Run("send", "--to", "+15550009", "--message", "Smoke test"); err != nil {
+		t.Fatalf("send: %v: %s", err, out)
+	}
+	b.ExpectSMS("+15550009", "Smoke test")
+
+	if out, err := b.Run("send"); err == nil {
+		t.Errorf("send without message succeeded: %s", out)
+	}
+}
+
+func TestTriggerCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the bridge")
+	}
+	b := Start(t)
+	defer b.Close()
+
+	if out, err := b.Run("trigger", "--url", b.URL, "--alertname", "Drill", "--summary", "Fire drill"); err != nil {
+		t.Fatalf("trigger: %v: %s", err, out)
+	}
+	b.ExpectSMS(Receiver, "Fire drill")
+}
+
+func TestSelfTestCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the bridge")
+	}
+	b := Start(t)
+	defer b.Close()
+
+	out, err := b.Run("--self-test")
+	if err != nil {
+		t.Fatalf("self-test: %v: %s", err, out)
+	}
+	for _, want := range []string{"PASS configuration", "PASS Twilio credentials", "Self-test of promtotwilio"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("self-test output %q doesn't contain %q", out, want)
+		}
+	}
+	if len(b.Messages()) != 0 {
+		t.Errorf("self-test without --to sent %d messages", len(b.Messages()))
+	}
+
+	if out, err := b.Run("--self-test", "--to", "+15550009"); err != nil {
+		t.Fatalf("self-test --to: %v: %s", err, out)
+	}
+	b.ExpectSMS("+15550009", "Self-test of promtotwilio")
+}
+
+func TestWebhookSecretRotation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the bridge")
+	}
+	b := Start(t, "WEBHOOK_SECRET=old-secret,new-secret")
+	defer b.Close()
+
+	if status := b.Send(Payload(Firing("DiskFull", "Disk full")), ""); status != http.StatusUnauthorized {
+		t.Errorf("status without secret == %d, want %d", status, http.StatusUnauthorized)
+	}
+	for _, secret := range []string{"old-secret", "new-secret"} {
+		if out, err := b.Run("trigger", "--url", b.URL, "--secret", secret, "--summary", "Rotated "+secret); err != nil {
+			t.Fatalf("trigger with %s: %v: %s", secret, err, out)
+		}
+		b.ExpectSMS(Receiver, "Rotated "+secret)
+	}
+}
@@ -0,0 +1,116 @@
+// Package template provides the helper functions available to message
+// templates, similar to the ones of Alertmanager templates.
+package template
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// FuncMap returns the helper functions available to message templates
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"truncate":         Truncate,
+		"upper":            strings.ToUpper,
+		"lower":            strings.ToLower,
+		"humanizeDuration": HumanizeDuration,
+		"reLabel":          ReLabel,
+		"join":             Join,
+		"default":          Default,
+	}
+}
+
+// New returns a template named name, parsed from text, with the helper
+// functions available
+func New(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(FuncMap()).Option("missingkey=zero").Parse(text)
+}
+
+// Truncate shortens s to at most n characters, ending it with an ellipsis
+// when it has been cut
+func Truncate(n int, s string) string {
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+	if n == 0 {
+		return ""
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// HumanizeDuration formats a number of seconds, or a time.Duration, in a
+// human readable way, e.g. "1d 2h 3m 4s"
+func HumanizeDuration(v interface{}) (string, error) {
+	var seconds float64
+	switch d := v.(type) {
+	case time.Duration:
+		seconds = d.Seconds()
+	case float64:
+		seconds = d
+	case int:
+		seconds = float64(d)
+	case int64:
+		seconds = float64(d)
+	default:
+		return "", fmt.Errorf("humanizeDuration: unsupported value %v of type %T", v, v)
+	}
+
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return fmt.Sprintf("%.4g", seconds), nil
+	}
+
+	sign := ""
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	if seconds < 1 {
+		if seconds == 0 {
+			return "0s", nil
+		}
+		return fmt.Sprintf("%s%.4gms", sign, seconds*1000), nil
+	}
+
+	total := int64(seconds)
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}}
+
+	var parts []string
+	for _, unit := range units {
+		if n := total / unit.size; n > 0 || (unit.suffix == "s" && len(parts) == 0) {
+			parts = append(parts, fmt.Sprintf("%d%s", n, unit.suffix))
+			total -= n * unit.size
+		}
+	}
+	return sign + strings.Join(parts, " "), nil
+}
+
+// ReLabel replaces the matches of the regular expression pattern in text
+// by replacement, which may reference capture groups like $1
+func ReLabel(pattern, replacement, text string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(text, replacement), nil
+}
+
+// Join concatenates the items with sep in between
+func Join(sep string, items []string) string {
+	return strings.Join(items, sep)
+}
+
+// Default returns value, or def when value is missing or empty
+func Default(def string, value interface{}) string {
+	if value == nil || value == "" {
+		return def
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,70 @@
+package template
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n        int
+		s        string
+		expected string
+	}{
+		{10, "short", "short"},
+		{5, "too long", "too …"},
+		{3, "éèàü", "éè…"},
+		{0, "text", ""},
+	}
+
+	for _, test := range tests {
+		if output := Truncate(test.n, test.s); output != test.expected {
+			t.Errorf("Truncate(%d, %q) == %q, want %q", test.n, test.s, output, test.expected)
+		}
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := []struct {
+		v        interface{}
+		expected string
+	}{
+		{0, "0s"},
+		{0.25, "250ms"},
+		{59, "59s"},
+		{3600.0, "1h"},
+		{93784, "1d 2h 3m 4s"},
+		{90 * time.Second, "1m 30s"},
+		{-61, "-1m 1s"},
+	}
+
+	for _, test := range tests {
+		output, err := HumanizeDuration(test.v)
+		if err != nil || output != test.expected {
+			t.Errorf("HumanizeDuration(%v) == %q, %v, want %q", test.v, output, err, test.expected)
+		}
+	}
+
+	if _, err := HumanizeDuration("1m"); err == nil {
+		t.Errorf("HumanizeDuration(string) didn't fail")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tmpl, err := New("test", `{{ .severity | default "none" | upper }} {{ reLabel "(.*):\\d+" "$1" .instance }} {{ join ", " .teams }} {{ truncate 8 .summary }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, map[string]interface{}{
+		"instance": "db-1:9100",
+		"teams":    []string{"db", "ops"},
+		"summary":  "Disk is almost full",
+	})
+	expected := "NONE db-1 db, ops Disk is…"
+	if err != nil || b.String() != expected {
+		t.Errorf("Execute() == %q, %v, want %q", b.String(), err, expected)
+	}
+}
@@ -0,0 +1,81 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// Supported access log formats
+const (
+	logFormatSimple = "simple"
+	logFormatNginx  = "nginx"
+	logFormatJSON   = "json"
+)
+
+// ValidLogFormat reports whether f is a supported access log format
+func ValidLogFormat(f string) bool {
+	return f == logFormatSimple || f == logFormatNginx || f == logFormatJSON
+}
+
+// accessLogEntry is a single request as written in the JSON access log
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id,omitempty"`
+	RemoteAddr string  `json:"remote_addr"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	Duration   float64 `json:"duration_ms"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+// LogRequests writes a line to w in the given format for every request
+// handled by h
+func LogRequests(h fasthttp.RequestHandler, format string, w io.Writer) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		h(ctx)
+		writeAccessLog(w, format, ctx, start, time.Since(start))
+	}
+}
+
+func writeAccessLog(w io.Writer, format string, ctx *fasthttp.RequestCtx, start time.Time, d time.Duration) {
+	var err error
+	switch format {
+	case logFormatNginx:
+		proto := "HTTP/1.1"
+		if !ctx.Request.Header.IsHTTP11() {
+			proto = "HTTP/1.0"
+		}
+		_, err = fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %d %q %q\n",
+			ctx.RemoteIP(), start.Format("02/Jan/2006:15:04:05 -0700"),
+			ctx.Method(), redact(string(ctx.RequestURI())), proto,
+			ctx.Response.StatusCode(), len(ctx.Response.Body()),
+			ctx.Referer(), ctx.UserAgent())
+	case logFormatJSON:
+		err = json.NewEncoder(w).Encode(accessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			RequestID:  requestID(ctx),
+			RemoteAddr: ctx.RemoteIP().String(),
+			Method:     string(ctx.Method()),
+			Path:       string(ctx.Path()),
+			Status:     ctx.Response.StatusCode(),
+			Bytes:      len(ctx.Response.Body()),
+			Duration:   float64(d) / float64(time.Millisecond),
+			UserAgent:  string(ctx.UserAgent()),
+		})
+	default:
+		_, err = fmt.Fprintf(w, "%s %s %s %d %s\n",
+			start.Format(time.RFC3339), ctx.Method(), redact(string(ctx.RequestURI())),
+			ctx.Response.StatusCode(), d)
+	}
+	if err != nil {
+		log.Warnf("Error writing access log: %v", err)
+	}
+}
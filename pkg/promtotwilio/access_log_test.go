@@ -0,0 +1,58 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestLogRequests(t *testing.T) {
+	handler := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusTeapot)
+		ctx.SetBodyString("short and stout")
+	}
+
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{logFormatSimple, []string{"GET /send?receiver=x 418"}},
+		{logFormatNginx, []string{`"GET /send?receiver=x HTTP/1.1" 418 15 "" "test-agent"`}},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/send?receiver=x")
+		ctx.Request.Header.SetUserAgent("test-agent")
+		LogRequests(handler, test.format, &buf)(ctx)
+
+		for _, s := range test.contains {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s access log %q doesn't contain %q", test.format, buf.String(), s)
+			}
+		}
+	}
+}
+
+func TestLogRequestsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/send")
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set(requestIDHeader, "abc-123")
+	WithRequestID(LogRequests(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+	}, logFormatJSON, &buf))(ctx)
+
+	var entry accessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("access log %q isn't valid JSON: %v", buf.String(), err)
+	}
+	if entry.RequestID != "abc-123" || entry.Method != "POST" || entry.Path != "/send" || entry.Status != fasthttp.StatusBadRequest {
+		t.Errorf("unexpected access log entry %+v", entry)
+	}
+}
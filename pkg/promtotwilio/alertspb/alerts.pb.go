@@ -0,0 +1,392 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: alerts.proto
+
+package alertspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SendAlertRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// alertname, severity and summary set the label and annotation of the
+	// same name, taking precedence over labels and annotations
+	Alertname   string            `protobuf:"bytes,1,opt,name=alertname,proto3" json:"alertname,omitempty"`
+	Severity    string            `protobuf:"bytes,2,opt,name=severity,proto3" json:"severity,omitempty"`
+	Summary     string            `protobuf:"bytes,3,opt,name=summary,proto3" json:"summary,omitempty"`
+	Labels      map[string]string `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations map[string]string `protobuf:"bytes,5,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// receivers are given as the receiver parameters of /send, the routing
+	// rules and RECEIVER applying when there are none
+	Receivers     []string `protobuf:"bytes,6,rep,name=receivers,proto3" json:"receivers,omitempty"`
+	Resolved      bool     `protobuf:"varint,7,opt,name=resolved,proto3" json:"resolved,omitempty"`
+	DryRun        bool     `protobuf:"varint,8,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendAlertRequest) Reset() {
+	*x = SendAlertRequest{}
+	mi := &file_alerts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendAlertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendAlertRequest) ProtoMessage() {}
+
+func (x *SendAlertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendAlertRequest.ProtoReflect.Descriptor instead.
+func (*SendAlertRequest) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SendAlertRequest) GetAlertname() string {
+	if x != nil {
+		return x.Alertname
+	}
+	return ""
+}
+
+func (x *SendAlertRequest) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *SendAlertRequest) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *SendAlertRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *SendAlertRequest) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *SendAlertRequest) GetReceivers() []string {
+	if x != nil {
+		return x.Receivers
+	}
+	return nil
+}
+
+func (x *SendAlertRequest) GetResolved() bool {
+	if x != nil {
+		return x.Resolved
+	}
+	return false
+}
+
+func (x *SendAlertRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type SendAlertResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Sent          int32                  `protobuf:"varint,2,opt,name=sent,proto3" json:"sent,omitempty"`
+	Failed        int32                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	Batched       int32                  `protobuf:"varint,4,opt,name=batched,proto3" json:"batched,omitempty"`
+	Delayed       int32                  `protobuf:"varint,5,opt,name=delayed,proto3" json:"delayed,omitempty"`
+	Suppressed    int32                  `protobuf:"varint,6,opt,name=suppressed,proto3" json:"suppressed,omitempty"`
+	Filtered      int32                  `protobuf:"varint,7,opt,name=filtered,proto3" json:"filtered,omitempty"`
+	Results       []*Result              `protobuf:"bytes,8,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendAlertResponse) Reset() {
+	*x = SendAlertResponse{}
+	mi := &file_alerts_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendAlertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendAlertResponse) ProtoMessage() {}
+
+func (x *SendAlertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendAlertResponse.ProtoReflect.Descriptor instead.
+func (*SendAlertResponse) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SendAlertResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SendAlertResponse) GetSent() int32 {
+	if x != nil {
+		return x.Sent
+	}
+	return 0
+}
+
+func (x *SendAlertResponse) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *SendAlertResponse) GetBatched() int32 {
+	if x != nil {
+		return x.Batched
+	}
+	return 0
+}
+
+func (x *SendAlertResponse) GetDelayed() int32 {
+	if x != nil {
+		return x.Delayed
+	}
+	return 0
+}
+
+func (x *SendAlertResponse) GetSuppressed() int32 {
+	if x != nil {
+		return x.Suppressed
+	}
+	return 0
+}
+
+func (x *SendAlertResponse) GetFiltered() int32 {
+	if x != nil {
+		return x.Filtered
+	}
+	return 0
+}
+
+func (x *SendAlertResponse) GetResults() []*Result {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// Result is the outcome of the message to a receiver, whose number is masked
+type Result struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Receiver      string                 `protobuf:"bytes,1,opt,name=receiver,proto3" json:"receiver,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Sid           string                 `protobuf:"bytes,3,opt,name=sid,proto3" json:"sid,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_alerts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Result) GetReceiver() string {
+	if x != nil {
+		return x.Receiver
+	}
+	return ""
+}
+
+func (x *Result) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Result) GetSid() string {
+	if x != nil {
+		return x.Sid
+	}
+	return ""
+}
+
+func (x *Result) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_alerts_proto protoreflect.FileDescriptor
+
+const file_alerts_proto_rawDesc = "" +
+	"\n" +
+	"\falerts.proto\x12\x0fpromtotwilio.v1\"\xd1\x03\n" +
+	"\x10SendAlertRequest\x12\x1c\n" +
+	"\talertname\x18\x01 \x01(\tR\talertname\x12\x1a\n" +
+	"\bseverity\x18\x02 \x01(\tR\bseverity\x12\x18\n" +
+	"\asummary\x18\x03 \x01(\tR\asummary\x12E\n" +
+	"\x06labels\x18\x04 \x03(\v2-.promtotwilio.v1.SendAlertRequest.LabelsEntryR\x06labels\x12T\n" +
+	"\vannotations\x18\x05 \x03(\v22.promtotwilio.v1.SendAlertRequest.AnnotationsEntryR\vannotations\x12\x1c\n" +
+	"\treceivers\x18\x06 \x03(\tR\treceivers\x12\x1a\n" +
+	"\bresolved\x18\a \x01(\bR\bresolved\x12\x17\n" +
+	"\adry_run\x18\b \x01(\bR\x06dryRun\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x81\x02\n" +
+	"\x11SendAlertResponse\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x12\n" +
+	"\x04sent\x18\x02 \x01(\x05R\x04sent\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x05R\x06failed\x12\x18\n" +
+	"\abatched\x18\x04 \x01(\x05R\abatched\x12\x18\n" +
+	"\adelayed\x18\x05 \x01(\x05R\adelayed\x12\x1e\n" +
+	"\n" +
+	"suppressed\x18\x06 \x01(\x05R\n" +
+	"suppressed\x12\x1a\n" +
+	"\bfiltered\x18\a \x01(\x05R\bfiltered\x121\n" +
+	"\aresults\x18\b \x03(\v2\x17.promtotwilio.v1.ResultR\aresults\"d\n" +
+	"\x06Result\x12\x1a\n" +
+	"\breceiver\x18\x01 \x01(\tR\breceiver\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x10\n" +
+	"\x03sid\x18\x03 \x01(\tR\x03sid\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error2\\\n" +
+	"\x06Alerts\x12R\n" +
+	"\tSendAlert\x12!.promtotwilio.v1.SendAlertRequest\x1a\".promtotwilio.v1.SendAlertResponseB:Z8github.com/swatto/promtotwilio/pkg/promtotwilio/alertspbb\x06proto3"
+
+var (
+	file_alerts_proto_rawDescOnce sync.Once
+	file_alerts_proto_rawDescData []byte
+)
+
+func file_alerts_proto_rawDescGZIP() []byte {
+	file_alerts_proto_rawDescOnce.Do(func() {
+		file_alerts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_alerts_proto_rawDesc), len(file_alerts_proto_rawDesc)))
+	})
+	return file_alerts_proto_rawDescData
+}
+
+var file_alerts_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_alerts_proto_goTypes = []any{
+	(*SendAlertRequest)(nil),  // 0: promtotwilio.v1.SendAlertRequest
+	(*SendAlertResponse)(nil), // 1: promtotwilio.v1.SendAlertResponse
+	(*Result)(nil),            // 2: promtotwilio.v1.Result
+	nil,                       // 3: promtotwilio.v1.SendAlertRequest.LabelsEntry
+	nil,                       // 4: promtotwilio.v1.SendAlertRequest.AnnotationsEntry
+}
+var file_alerts_proto_depIdxs = []int32{
+	3, // 0: promtotwilio.v1.SendAlertRequest.labels:type_name -> promtotwilio.v1.SendAlertRequest.LabelsEntry
+	4, // 1: promtotwilio.v1.SendAlertRequest.annotations:type_name -> promtotwilio.v1.SendAlertRequest.AnnotationsEntry
+	2, // 2: promtotwilio.v1.SendAlertResponse.results:type_name -> promtotwilio.v1.Result
+	0, // 3: promtotwilio.v1.Alerts.SendAlert:input_type -> promtotwilio.v1.SendAlertRequest
+	1, // 4: promtotwilio.v1.Alerts.SendAlert:output_type -> promtotwilio.v1.SendAlertResponse
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_alerts_proto_init() }
+func file_alerts_proto_init() {
+	if File_alerts_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_alerts_proto_rawDesc), len(file_alerts_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_alerts_proto_goTypes,
+		DependencyIndexes: file_alerts_proto_depIdxs,
+		MessageInfos:      file_alerts_proto_msgTypes,
+	}.Build()
+	File_alerts_proto = out.File
+	file_alerts_proto_goTypes = nil
+	file_alerts_proto_depIdxs = nil
+}
@@ -0,0 +1,125 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: alerts.proto
+
+package alertspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Alerts_SendAlert_FullMethodName = "/promtotwilio.v1.Alerts/SendAlert"
+)
+
+// AlertsClient is the client API for Alerts service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Alerts sends alerts as the Alertmanager webhooks of /send are
+type AlertsClient interface {
+	SendAlert(ctx context.Context, in *SendAlertRequest, opts ...grpc.CallOption) (*SendAlertResponse, error)
+}
+
+type alertsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAlertsClient(cc grpc.ClientConnInterface) AlertsClient {
+	return &alertsClient{cc}
+}
+
+func (c *alertsClient) SendAlert(ctx context.Context, in *SendAlertRequest, opts ...grpc.CallOption) (*SendAlertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendAlertResponse)
+	err := c.cc.Invoke(ctx, Alerts_SendAlert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AlertsServer is the server API for Alerts service.
+// All implementations must embed UnimplementedAlertsServer
+// for forward compatibility.
+//
+// Alerts sends alerts as the Alertmanager webhooks of /send are
+type AlertsServer interface {
+	SendAlert(context.Context, *SendAlertRequest) (*SendAlertResponse, error)
+	mustEmbedUnimplementedAlertsServer()
+}
+
+// UnimplementedAlertsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAlertsServer struct{}
+
+func (UnimplementedAlertsServer) SendAlert(context.Context, *SendAlertRequest) (*SendAlertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendAlert not implemented")
+}
+func (UnimplementedAlertsServer) mustEmbedUnimplementedAlertsServer() {}
+func (UnimplementedAlertsServer) testEmbeddedByValue()                {}
+
+// UnsafeAlertsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AlertsServer will
+// result in compilation errors.
+type UnsafeAlertsServer interface {
+	mustEmbedUnimplementedAlertsServer()
+}
+
+func RegisterAlertsServer(s grpc.ServiceRegistrar, srv AlertsServer) {
+	// If the following call pancis, it indicates UnimplementedAlertsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Alerts_ServiceDesc, srv)
+}
+
+func _Alerts_SendAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendAlertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AlertsServer).SendAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Alerts_SendAlert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AlertsServer).SendAlert(ctx, req.(*SendAlertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Alerts_ServiceDesc is the grpc.ServiceDesc for Alerts service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Alerts_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "promtotwilio.v1.Alerts",
+	HandlerType: (*AlertsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendAlert",
+			Handler:    _Alerts_SendAlert_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "alerts.proto",
+}
@@ -0,0 +1,4 @@
+// Package alertspb holds the messages and the Alerts service of alerts.proto
+package alertspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative alerts.proto
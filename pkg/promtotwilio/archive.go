@@ -0,0 +1,281 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// s3DefaultRegion is the region of the S3 buckets without ARCHIVE_REGION
+	s3DefaultRegion = "us-east-1"
+	// gcsEndpoint is the S3 compatible XML API of Google Cloud Storage,
+	// authenticated with HMAC keys
+	gcsEndpoint = "https://storage.googleapis.com"
+)
+
+// archiveTarget is the bucket, and the prefix in it, the archives are
+// uploaded to
+type archiveTarget struct {
+	// endpoint is the base URL of the storage API, the objects being
+	// addressed in the path style
+	endpoint string
+	bucket   string
+	prefix   string
+	region   string
+}
+
+// parseArchiveURL parses an s3://bucket/prefix or gs://bucket/prefix URL,
+// the endpoint, when set, overriding the one of the provider, e.g. for an
+// S3 compatible storage
+func parseArchiveURL(rawurl, region, endpoint string) (archiveTarget, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return archiveTarget{}, fmt.Errorf("%q isn't a URL such as s3://bucket/prefix", rawurl)
+	}
+	target := archiveTarget{bucket: u.Host, prefix: strings.Trim(u.Path, "/"), region: region}
+	if target.prefix != "" {
+		target.prefix += "/"
+	}
+	switch u.Scheme {
+	case "s3":
+		if target.region == "" {
+			target.region = s3DefaultRegion
+		}
+		target.endpoint = "https://s3." + target.region + ".amazonaws.com"
+	case "gs":
+		target.region = "auto"
+		target.endpoint = gcsEndpoint
+	default:
+		return archiveTarget{}, fmt.Errorf("unsupported scheme %q, must be s3 or gs", u.Scheme)
+	}
+	if endpoint != "" {
+		target.endpoint = strings.TrimRight(endpoint, "/")
+	}
+	return target, nil
+}
+
+// ValidArchiveURL returns the error of an invalid ARCHIVE_URL
+func ValidArchiveURL(rawurl string) error {
+	_, err := parseArchiveURL(rawurl, "", "")
+	return err
+}
+
+// archiver periodically uploads the messages of the history and the
+// captured payloads not archived yet to a bucket, as JSON Lines, deleting
+// the archives older than the retention
+type archiver struct {
+	target    archiveTarget
+	creds     AWSCredentials
+	interval  time.Duration
+	retention time.Duration
+	client    *http.Client
+	now       func() time.Time
+
+	history  *messageHistory
+	payloads *payloadRing
+	// archivedUntil and archivedID are the time of the last message and the
+	// ID of the last payload archived
+	archivedUntil time.Time
+	archivedID    int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newArchiver(target archiveTarget, creds AWSCredentials, interval, retention time.Duration, history *messageHistory, payloads *payloadRing) *archiver {
+	return &archiver{
+		target:    target,
+		creds:     creds,
+		interval:  interval,
+		retention: retention,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+		history:   history,
+		payloads:  payloads,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// start archives every interval until Stop is called
+func (a *archiver) start() {
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.archive()
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops archiving, after archiving what wasn't yet
+func (a *archiver) Stop() {
+	close(a.stop)
+	<-a.done
+	a.archive()
+}
+
+// archive uploads the messages and payloads not archived yet and applies
+// the retention, logging the errors
+func (a *archiver) archive() {
+	now := a.now().UTC()
+	if err := a.archiveMessages(now); err != nil {
+		archiveFailuresTotal.Inc()
+		log.Errorf("Error archiving the messages: %v", err)
+	}
+	if err := a.archivePayloads(now); err != nil {
+		archiveFailuresTotal.Inc()
+		log.Errorf("Error archiving the payloads: %v", err)
+	}
+	if a.retention > 0 {
+		if err := a.expire(now.Add(-a.retention)); err != nil {
+			archiveFailuresTotal.Inc()
+			log.Errorf("Error deleting the expired archives: %v", err)
+		}
+	}
+}
+
+// objectKey returns the key of an archive of the kind made at the time
+func (a *archiver) objectKey(kind string, now time.Time) string {
+	return a.target.prefix + kind + "/" + now.Format("2006/01/02/20060102T150405Z") + ".jsonl"
+}
+
+func (a *archiver) archiveMessages(now time.Time) error {
+	if a.history == nil {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	entries := a.history.list()
+	until := a.archivedUntil
+	// the history lists the most recent messages first
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Time.After(a.archivedUntil) {
+			encoder.Encode(entries[i])
+			until = entries[i].Time
+		}
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+	if err := a.put(a.objectKey("messages", now), body.Bytes()); err != nil {
+		return err
+	}
+	a.archivedUntil = until
+	return nil
+}
+
+func (a *archiver) archivePayloads(now time.Time) error {
+	if a.payloads == nil {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	payloads := a.payloads.list()
+	archived := a.archivedID
+	for i := len(payloads) - 1; i >= 0; i-- {
+		if payloads[i].ID > a.archivedID {
+			encoder.Encode(payloads[i])
+			archived = payloads[i].ID
+		}
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+	if err := a.put(a.objectKey("payloads", now), body.Bytes()); err != nil {
+		return err
+	}
+	a.archivedID = archived
+	return nil
+}
+
+// s3ListResponse is a page of the objects of a bucket
+type s3ListResponse struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// expire deletes the archives last modified before the time
+func (a *archiver) expire(before time.Time) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {a.target.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		body, err := a.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return err
+		}
+		var list s3ListResponse
+		if err := xml.Unmarshal(body, &list); err != nil {
+			return err
+		}
+		for _, object := range list.Contents {
+			if object.LastModified.Before(before) {
+				if _, err := a.do(http.MethodDelete, object.Key, nil, nil); err != nil {
+					return err
+				}
+			}
+		}
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			return nil
+		}
+		token = list.NextContinuationToken
+	}
+}
+
+func (a *archiver) put(key string, body []byte) error {
+	_, err := a.do(http.MethodPut, key, nil, body)
+	return err
+}
+
+// do sends a signed request about an object of the bucket, or the bucket
+// itself when the key is empty, returning the response body
+func (a *archiver) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	endpoint := a.target.endpoint + "/" + awsEscape(a.target.bucket, true) + "/" + awsEscape(key, false)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	signV4(req, body, a.creds, a.target.region, "s3", a.now())
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%s %s: status %d: %s", method, key, res.StatusCode, bytes.TrimSpace(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,102 @@
+package promtotwilio
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseArchiveURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		region   string
+		endpoint string
+		expected archiveTarget
+	}{
+		{"s3://alerts", "", "", archiveTarget{"https://s3.us-east-1.amazonaws.com", "alerts", "", "us-east-1"}},
+		{"s3://alerts/promtotwilio/", "eu-west-1", "", archiveTarget{"https://s3.eu-west-1.amazonaws.com", "alerts", "promtotwilio/", "eu-west-1"}},
+		{"gs://alerts/a/b", "", "", archiveTarget{"https://storage.googleapis.com", "alerts", "a/b/", "auto"}},
+		{"s3://alerts/archive", "", "http://minio:9000/", archiveTarget{"http://minio:9000", "alerts", "archive/", "us-east-1"}},
+	}
+	for _, test := range tests {
+		target, err := parseArchiveURL(test.url, test.region, test.endpoint)
+		if err != nil || target != test.expected {
+			t.Errorf("parseArchiveURL(%q) == %+v, %v, want %+v", test.url, target, err, test.expected)
+		}
+	}
+
+	for _, url := range []string{"", "alerts", "s3:///prefix", "https://alerts/prefix"} {
+		if err := ValidArchiveURL(url); err == nil {
+			t.Errorf("ValidArchiveURL(%q) succeeded", url)
+		}
+	}
+}
+
+func TestArchiver(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string]string)
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("unsigned request %v", r.Header)
+		}
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			if r.URL.Path != "/alerts/" || r.FormValue("list-type") != "2" || r.FormValue("prefix") != "archive/" {
+				t.Errorf("unexpected list %s", r.URL)
+			}
+			w.Write([]byte(`<ListBucketResult>
+<Contents><Key>archive/messages/2019/12/01/20191201T000000Z.jsonl</Key><LastModified>2019-12-01T00:00:00.000Z</LastModified></Contents>
+<Contents><Key>archive/payloads/2020/01/01/20200101T120000Z.jsonl</Key><LastModified>2020-01-01T12:00:00.000Z</LastModified></Contents>
+</ListBucketResult>`))
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := parseArchiveURL("s3://alerts/archive", "", server.URL)
+	history := newMessageHistory(10)
+	payloads := newPayloadRing(10)
+	a := newArchiver(target, AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, time.Hour, 7*24*time.Hour, history, payloads)
+	now := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	history.add("req-1", SendResult{Receiver: "+33600000001", Status: "sent"})
+	history.add("req-2", SendResult{Receiver: "+33600000002", Status: "failed"})
+	payloads.add(newSendRequestCtx("/send", `{"status": "firing"}`))
+	a.archive()
+
+	messages := objects["/alerts/archive/messages/2020/01/02/20200102T150405Z.jsonl"]
+	if lines := strings.Split(strings.TrimSpace(messages), "\n"); len(lines) != 2 || !strings.Contains(lines[0], "req-1") || !strings.Contains(lines[1], "req-2") {
+		t.Errorf("messages archive == %q, want the two messages oldest first", messages)
+	}
+	if archived := objects["/alerts/archive/payloads/2020/01/02/20200102T150405Z.jsonl"]; !strings.Contains(archived, `firing`) {
+		t.Errorf("payloads archive == %q, want the payload", archived)
+	}
+	if expected := []string{"/alerts/archive/messages/2019/12/01/20191201T000000Z.jsonl"}; !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("deleted == %q, want %q", deleted, expected)
+	}
+
+	// only what wasn't archived yet is uploaded
+	now = now.Add(time.Hour)
+	history.add("req-3", SendResult{Receiver: "+33600000003", Status: "sent"})
+	a.archive()
+	messages = objects["/alerts/archive/messages/2020/01/02/20200102T160405Z.jsonl"]
+	if strings.Count(messages, "\n") != 1 || !strings.Contains(messages, "req-3") {
+		t.Errorf("messages archive == %q, want the new message", messages)
+	}
+	if _, ok := objects["/alerts/archive/payloads/2020/01/02/20200102T160405Z.jsonl"]; ok {
+		t.Errorf("payloads archived without new payloads")
+	}
+}
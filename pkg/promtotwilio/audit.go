@@ -0,0 +1,201 @@
+package promtotwilio
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// defaultAuditLimit is the number of entries returned by /admin/audit
+// without a limit query parameter
+const defaultAuditLimit = 100
+
+// AuditEntry records an action made through the admin API
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	RemoteIP  string    `json:"remote_ip"`
+	RequestID string    `json:"request_id"`
+	Action    string    `json:"action"`
+	// Before and After describe what the action changed
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// auditLog appends the admin actions to a file as JSON lines, which are
+// never rewritten
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// openAuditLog opens, or creates, the audit log at path
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{path: path, file: file}, nil
+}
+
+// record appends an entry, synced to disk before returning
+func (a *auditLog) record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+// auditFilter selects the entries returned by query, its empty fields
+// matching every entry
+type auditFilter struct {
+	actor  string
+	action string
+	since  time.Time
+	limit  int
+}
+
+func (f auditFilter) match(entry AuditEntry) bool {
+	return (f.actor == "" || entry.Actor == f.actor) &&
+		(f.action == "" || entry.Action == f.action) &&
+		!entry.Time.Before(f.since)
+}
+
+// query returns the entries matching the filter, most recent first
+func (a *auditLog) query(filter auditFilter) ([]AuditEntry, error) {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if filter.limit > 0 && len(entries) > filter.limit {
+		entries = entries[:filter.limit]
+	}
+	return entries, nil
+}
+
+// Close closes the audit log file
+func (a *auditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// systemActor is the actor of the changes promtotwilio makes on its own,
+// e.g. muting a receiver during an alert storm
+const systemActor = "promtotwilio"
+
+// audit records an admin action of the request, when AUDIT_LOG_FILE is set
+func (m OptionsWithHandler) audit(ctx *fasthttp.RequestCtx, action string, before, after map[string]interface{}) {
+	if m.Audit == nil {
+		return
+	}
+	err := m.Audit.record(AuditEntry{
+		Time:      time.Now().UTC(),
+		Actor:     m.adminActor(ctx),
+		RemoteIP:  ctx.RemoteIP().String(),
+		RequestID: requestID(ctx),
+		Action:    action,
+		Before:    before,
+		After:     after,
+	})
+	if err != nil {
+		requestLogger(ctx).Errorf("Error recording %s in the audit log: %v", action, err)
+	}
+}
+
+// auditSystem records a change made by promtotwilio, such as a silence or
+// a reload of the templates, when AUDIT_LOG_FILE is set
+func (m OptionsWithHandler) auditSystem(action string, before, after map[string]interface{}) {
+	if m.Audit == nil {
+		return
+	}
+	err := m.Audit.record(AuditEntry{
+		Time:   time.Now().UTC(),
+		Actor:  systemActor,
+		Action: action,
+		Before: before,
+		After:  after,
+	})
+	if err != nil {
+		log.Errorf("Error recording %s in the audit log: %v", action, err)
+	}
+}
+
+// listAudit returns the entries of the audit log, most recent first,
+// filtered by the actor, action and since query parameters
+func (m OptionsWithHandler) listAudit(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	args := ctx.QueryArgs()
+	filter := auditFilter{
+		actor:  string(args.Peek("actor")),
+		action: string(args.Peek("action")),
+		limit:  defaultAuditLimit,
+	}
+	if since := args.Peek("since"); len(since) > 0 {
+		d, err := time.ParseDuration(string(since))
+		if err != nil || d <= 0 {
+			writeProblem(ctx, fasthttp.StatusBadRequest, "since must be a duration such as 24h")
+			return
+		}
+		filter.since = time.Now().Add(-d)
+	}
+	if limit := args.Peek("limit"); len(limit) > 0 {
+		n, err := strconv.Atoi(string(limit))
+		if err != nil || n < 1 {
+			writeProblem(ctx, fasthttp.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.limit = n
+	}
+
+	entries, err := m.Audit.query(filter)
+	if err != nil {
+		writeProblem(ctx, fasthttp.StatusInternalServerError, "error reading the audit log: "+err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	ctx.SetContentType("application/json")
+	if err := json.NewEncoder(ctx).Encode(entries); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package promtotwilio
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := writeTempFile(t, "")
+	defer os.Remove(path)
+	audit, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+
+	client := &fakeTwilioClient{err: errors.New("twilio down")}
+	m := OptionsWithHandler{
+		Options:     &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:      client,
+		DeadLetters: newDeadLetters(),
+		Audit:       audit,
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B15550001", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+
+	client.err = nil
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("alice:secret")))
+	ctx.Request.SetRequestURI("/admin/replay?since=1h")
+	m.HandleFastHTTP(ctx)
+
+	content, _ := ioutil.ReadFile(path)
+	if strings.Count(string(content), "\n") != 1 {
+		t.Fatalf("audit log == %q, want a single entry", content)
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/admin/audit?actor=alice&since=1h")
+	m.HandleFastHTTP(ctx)
+	var entries []AuditEntry
+	if err := json.Unmarshal(ctx.Response.Body(), &entries); err != nil {
+		t.Fatalf("%v: %s", err, ctx.Response.Body())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries == %+v, want the replay", entries)
+	}
+	entry := entries[0]
+	if entry.Action != "dead_letters.replay" || entry.Before["failed_messages"] != 1.0 || entry.After["failed_messages"] != 0.0 || entry.After["sent"] != 1.0 {
+		t.Errorf("entry == %+v", entry)
+	}
+	if time.Since(entry.Time) > time.Minute {
+		t.Errorf("entry.Time == %v", entry.Time)
+	}
+
+	for _, uri := range []string{"/admin/audit?actor=bob", "/admin/audit?action=payload.replay", "/admin/audit?limit=0"} {
+		ctx = &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		m.HandleFastHTTP(ctx)
+		if uri == "/admin/audit?limit=0" {
+			if ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+				t.Errorf("%s answered %d, want 400", uri, ctx.Response.StatusCode())
+			}
+		} else if body := strings.TrimSpace(string(ctx.Response.Body())); body != "[]" {
+			t.Errorf("%s == %s, want []", uri, body)
+		}
+	}
+}
+
+func TestAuditQueryOrder(t *testing.T) {
+	path := writeTempFile(t, "")
+	defer os.Remove(path)
+	audit, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+
+	for _, action := range []string{"one", "two", "three"} {
+		if err := audit.record(AuditEntry{Time: time.Now(), Actor: "token", Action: action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := audit.query(auditFilter{limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "three" || entries[1].Action != "two" {
+		t.Errorf("query() == %+v, want the last two entries, most recent first", entries)
+	}
+}
+
+func TestAdminActor(t *testing.T) {
+	tests := []struct {
+		authorization string
+		expected      string
+	}{
+		{"", "anonymous"},
+		{"Bearer abc", "token"},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret")), "alice"},
+		{"Basic !!!", "anonymous"},
+	}
+	for _, test := range tests {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.Set("Authorization", test.authorization)
+		if actor := (OptionsWithHandler{}).adminActor(ctx); actor != test.expected {
+			t.Errorf("adminActor(%q) == %q, want %q", test.authorization, actor, test.expected)
+		}
+	}
+}
+
+func TestAuditSystem(t *testing.T) {
+	path := writeTempFile(t, "")
+	defer os.Remove(path)
+	audit, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Annotations: []string{"summary"}, StormThreshold: 1, StormWindow: time.Minute},
+		Client:  &fakeTwilioClient{},
+		Storm:   newStormGuard(1, time.Minute, nil),
+		Audit:   audit,
+	}
+	for i := 0; i < 3; i++ {
+		m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B15550001", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+	}
+
+	entries, err := audit.query(auditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries == %+v, want the start of the storm", entries)
+	}
+	entry := entries[0]
+	if entry.Actor != systemActor || entry.Action != "silence.start" || entry.After["reason"] != "storm" || entry.After["receiver"] != maskNumber("+15550001") {
+		t.Errorf("entry == %+v", entry)
+	}
+}
+
+func TestAuditTemplateReload(t *testing.T) {
+	path := writeTempFile(t, "")
+	defer os.Remove(path)
+	dir, err := ioutil.TempDir("", "promtotwilio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	template := filepath.Join(dir, "default.tmpl")
+	if err := ioutil.WriteFile(template, []byte("{{ .Annotations.summary }}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewTemplateDir(dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMOptionsWithHandler(&Config{Sender: "+100", AuditLogFile: path, TemplateDir: d})
+	defer m.Stop()
+	if err := ioutil.WriteFile(template, []byte("{{ .Annotations.summary "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(template, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []AuditEntry
+	for deadline := time.Now().Add(5 * time.Second); len(entries) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		entries, err = m.Audit.query(auditFilter{action: "templates.reload", limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(entries) != 1 || entries[0].Actor != systemActor || entries[0].After["status"] != "failed" || entries[0].After["template_dir"] != dir {
+		t.Errorf("entries == %+v, want the failed reload", entries)
+	}
+}
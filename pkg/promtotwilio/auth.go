@@ -0,0 +1,129 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// webhookCredential returns the secret of a webhook: the bearer token or
+// the basic auth password of its Authorization header, as Alertmanager
+// sends them with the authorization or basic_auth of its http_config
+func webhookCredential(ctx *fasthttp.RequestCtx) []byte {
+	header := ctx.Request.Header.Peek("Authorization")
+	switch {
+	case len(header) > 7 && bytes.EqualFold(header[:7], []byte("Bearer ")):
+		return header[7:]
+	case len(header) > 6 && bytes.EqualFold(header[:6], []byte("Basic ")):
+		decoded, err := base64.StdEncoding.DecodeString(string(header[6:]))
+		if err != nil {
+			return nil
+		}
+		if i := bytes.IndexByte(decoded, ':'); i >= 0 {
+			return decoded[i+1:]
+		}
+	}
+	return nil
+}
+
+// authorized reports whether a webhook carries one of WEBHOOK_SECRET or a
+// valid JWT, all webhooks being authorized when neither is configured.
+// Several secrets are accepted so that they can be rotated without
+// rejecting webhooks.
+func (m OptionsWithHandler) authorized(ctx *fasthttp.RequestCtx) bool {
+	if len(m.Options.WebhookSecrets) == 0 && m.JWT == nil {
+		return true
+	}
+	credential := webhookCredential(ctx)
+	if m.JWT != nil && looksLikeJWT(string(credential)) {
+		if err := m.JWT.verify(string(credential)); err != nil {
+			requestLogger(ctx).Warnf("Invalid JWT: %v", err)
+			return false
+		}
+		return true
+	}
+	authorized := false
+	for _, secret := range m.Options.WebhookSecrets {
+		// every secret is compared, in constant time, not to leak which
+		// one matched
+		if subtle.ConstantTimeCompare(credential, []byte(secret)) == 1 {
+			authorized = true
+		}
+	}
+	return authorized
+}
+
+// rejectUnauthorized answers 401 to a webhook without a valid secret
+func rejectUnauthorized(ctx *fasthttp.RequestCtx) {
+	requestLogger(ctx).Warnf("Unauthorized webhook from %s", ctx.RemoteIP())
+	ctx.Response.Header.Set("WWW-Authenticate", `Bearer realm="promtotwilio"`)
+	writeProblem(ctx, fasthttp.StatusUnauthorized, "missing or invalid webhook secret")
+}
+
+// adminPath reports whether a path exposes the messages, costs, phone
+// numbers or runtime profiles, and is protected by the admin credentials and
+// only served on ADMIN_PORT when set
+func adminPath(path string) bool {
+	switch path {
+	case "/metrics", "/events", "/ui", "/v1/messages", "/messages/export":
+		return true
+	}
+	return strings.HasPrefix(path, "/admin/") || pprofPath(path)
+}
+
+// adminAuthorized reports whether a request carries ADMIN_TOKEN as bearer
+// token or ADMIN_USER and ADMIN_PASSWORD as basic auth, all requests being
+// authorized when neither is set
+func (m OptionsWithHandler) adminAuthorized(ctx *fasthttp.RequestCtx) bool {
+	o := m.Options
+	if o.AdminToken == "" && o.AdminPassword == "" {
+		return true
+	}
+
+	header := ctx.Request.Header.Peek("Authorization")
+	if o.AdminToken != "" && len(header) > 7 && bytes.EqualFold(header[:7], []byte("Bearer ")) {
+		return subtle.ConstantTimeCompare(header[7:], []byte(o.AdminToken)) == 1
+	}
+	if o.AdminPassword != "" && len(header) > 6 && bytes.EqualFold(header[:6], []byte("Basic ")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(header[6:]))
+		if err != nil {
+			return false
+		}
+		expected := []byte(o.AdminUser + ":" + o.AdminPassword)
+		return subtle.ConstantTimeCompare(decoded, expected) == 1
+	}
+	return false
+}
+
+// adminActor names who made an admin request in the audit log: the basic
+// auth user, "token" for ADMIN_TOKEN, or "anonymous" without credentials
+func (m OptionsWithHandler) adminActor(ctx *fasthttp.RequestCtx) string {
+	header := ctx.Request.Header.Peek("Authorization")
+	switch {
+	case len(header) > 7 && bytes.EqualFold(header[:7], []byte("Bearer ")):
+		return "token"
+	case len(header) > 6 && bytes.EqualFold(header[:6], []byte("Basic ")):
+		decoded, err := base64.StdEncoding.DecodeString(string(header[6:]))
+		if err == nil {
+			if i := bytes.IndexByte(decoded, ':'); i >= 0 {
+				return string(decoded[:i])
+			}
+		}
+	}
+	return "anonymous"
+}
+
+// rejectAdminUnauthorized answers 401 to a request of an admin path without
+// valid credentials, asking browsers for them when basic auth is enabled
+func (m OptionsWithHandler) rejectAdminUnauthorized(ctx *fasthttp.RequestCtx) {
+	requestLogger(ctx).Warnf("Unauthorized request of %s from %s", ctx.Path(), ctx.RemoteIP())
+	if m.Options.AdminPassword != "" {
+		ctx.Response.Header.Set("WWW-Authenticate", `Basic realm="promtotwilio"`)
+	} else {
+		ctx.Response.Header.Set("WWW-Authenticate", `Bearer realm="promtotwilio"`)
+	}
+	writeProblem(ctx, fasthttp.StatusUnauthorized, "missing or invalid admin credentials")
+}
@@ -0,0 +1,74 @@
+package promtotwilio
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestAuthorized(t *testing.T) {
+	m := OptionsWithHandler{Options: &Config{WebhookSecrets: []string{"old-secret", "new-secret"}}}
+
+	tests := []struct {
+		authorization string
+		authorized    bool
+	}{
+		{"", false},
+		{"Bearer old-secret", true},
+		{"bearer new-secret", true},
+		{"Bearer other", false},
+		{"Basic YWxlcnRtYW5hZ2VyOm5ldy1zZWNyZXQ=", true}, // alertmanager:new-secret
+		{"Basic YWxlcnRtYW5hZ2VyOm90aGVy", false},        // alertmanager:other
+		{"Basic !!!", false},
+	}
+	for _, tt := range tests {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.Set("Authorization", tt.authorization)
+		if got := m.authorized(ctx); got != tt.authorized {
+			t.Errorf("authorized(%q) == %v, want %v", tt.authorization, got, tt.authorized)
+		}
+	}
+
+	if !(OptionsWithHandler{Options: &Config{}}).authorized(&fasthttp.RequestCtx{}) {
+		t.Errorf("webhook unauthorized without WEBHOOK_SECRET")
+	}
+}
+
+func TestSendUnauthorized(t *testing.T) {
+	m := OptionsWithHandler{Options: &Config{WebhookSecrets: []string{"secret"}}}
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": []}`)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusUnauthorized)
+	}
+}
+
+func TestAdminAuthorized(t *testing.T) {
+	m := OptionsWithHandler{Options: &Config{AdminToken: "admin-token", AdminUser: "admin", AdminPassword: "hunter2"}}
+
+	tests := []struct {
+		path, authorization string
+		code                int
+	}{
+		{"/metrics", "", fasthttp.StatusUnauthorized},
+		{"/metrics", "Bearer admin-token", fasthttp.StatusOK},
+		{"/metrics", "Bearer webhook-secret", fasthttp.StatusUnauthorized},
+		{"/metrics", "Basic YWRtaW46aHVudGVyMg==", fasthttp.StatusOK},       // admin:hunter2
+		{"/metrics", "Basic YWRtaW46b3RoZXI=", fasthttp.StatusUnauthorized}, // admin:other
+		{"/admin/messages", "", fasthttp.StatusUnauthorized},
+		{"/v1/messages", "", fasthttp.StatusUnauthorized},
+		{"/messages/export", "", fasthttp.StatusUnauthorized},
+		{"/ui", "", fasthttp.StatusUnauthorized},
+		{"/", "", fasthttp.StatusOK},
+		{"/v1/health", "", fasthttp.StatusOK},
+	}
+	for _, tt := range tests {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(tt.path)
+		ctx.Request.Header.Set("Authorization", tt.authorization)
+		m.HandleFastHTTP(ctx)
+		if ctx.Response.StatusCode() != tt.code {
+			t.Errorf("GET %s with %q == %d, want %d", tt.path, tt.authorization, ctx.Response.StatusCode(), tt.code)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// batchedMessage is a message waiting in a digest
+type batchedMessage struct {
+	status string
+	text   string
+}
+
+// batcher buffers messages per receiver and sends them every interval as a
+// single digest message, to cut costs during alert storms
+type batcher struct {
+	interval time.Duration
+	send     func(receiver, text string) error
+
+	mu      sync.Mutex
+	pending map[string][]batchedMessage
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newBatcher(interval time.Duration, send func(receiver, text string) error) *batcher {
+	return &batcher{
+		interval: interval,
+		send:     send,
+		pending:  make(map[string][]batchedMessage),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start sends the digests every interval until Stop is called
+func (b *batcher) start() {
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.flush()
+			case <-b.stop:
+				b.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the batcher after sending the pending digests
+func (b *batcher) Stop() {
+	close(b.stop)
+	<-b.done
+}
+
+// add queues a message with the given alert status for the receiver
+func (b *batcher) add(receiver, status, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[receiver] = append(b.pending[receiver], batchedMessage{status, text})
+}
+
+// flush sends a digest to every receiver with pending messages
+func (b *batcher) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string][]batchedMessage)
+	b.mu.Unlock()
+
+	for receiver, messages := range pending {
+		if err := b.send(receiver, digest(messages)); err != nil {
+			log.Errorf("Error sending digest of %d messages: %v", len(messages), err)
+		}
+	}
+}
+
+// digest summarizes messages into a single one
+func digest(messages []batchedMessage) string {
+	if len(messages) == 1 {
+		return messages[0].text
+	}
+
+	counts := make(map[string]int)
+	lines := make([]string, 0, len(messages)+1)
+	for _, m := range messages {
+		counts[m.status]++
+	}
+
+	var summary []string
+	for _, status := range []string{"firing", "resolved"} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%d alerts (%s):", len(messages), strings.Join(summary, ", ")))
+	for _, m := range messages {
+		lines = append(lines, "- "+m.text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// depth returns the number of messages waiting for the next digests
+func (b *batcher) depth() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, messages := range b.pending {
+		n += len(messages)
+	}
+	return n
+}
@@ -0,0 +1,63 @@
+package promtotwilio
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent = make(map[string]string)
+	)
+	b := newBatcher(time.Hour, func(receiver, text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent[receiver] = text
+		return nil
+	})
+	b.start()
+
+	b.add("+100", "firing", "Disk full")
+	b.add("+100", "firing", "CPU high")
+	b.add("+100", "resolved", "RESOLVED: Memory low")
+	b.add("+200", "firing", "Disk full")
+	b.Stop()
+
+	expected := map[string]string{
+		"+100": "3 alerts (2 firing, 1 resolved):\n- Disk full\n- CPU high\n- RESOLVED: Memory low",
+		"+200": "Disk full",
+	}
+	for receiver, text := range expected {
+		if sent[receiver] != text {
+			t.Errorf("digest sent to %s == %q, want %q", receiver, sent[receiver], text)
+		}
+	}
+}
+
+func TestSendDigestRecorded(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:     &Config{Sender: "+100"},
+		Client:      client,
+		History:     newMessageHistory(10),
+		DeadLetters: newDeadLetters(),
+	}
+	if err := m.sendDigest("+15550001", "Disk full"); err != nil {
+		t.Fatal(err)
+	}
+	client.err = errors.New("boom")
+	if err := m.sendDigest("+15550002", "CPU high"); err == nil {
+		t.Fatal("sendDigest() succeeded with a failing client")
+	}
+
+	entries := m.History.list()
+	if len(entries) != 2 || entries[0].Status != "failed" || entries[1].Sid != "SM1" {
+		t.Errorf("history == %+v, want the failed and sent digests", entries)
+	}
+	if letters := m.DeadLetters.since(time.Time{}); len(letters) != 1 || letters[0].body != "CPU high" {
+		t.Errorf("dead letters == %+v, want the failed digest", letters)
+	}
+}
@@ -0,0 +1,120 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentPrice is the price of a message segment used to estimate
+// the cost of the messages Twilio didn't price yet, the one of the US
+const DefaultSegmentPrice = 0.0079
+
+// budget caps the number of messages sent, and what they cost, per
+// calendar month (UTC)
+type budget struct {
+	limit  int
+	amount float64
+	now    func() time.Time
+
+	mu       sync.Mutex
+	month    string
+	used     int
+	spent    float64
+	notified bool
+}
+
+func newBudget(limit int, amount float64) *budget {
+	return &budget{limit: limit, amount: amount, now: time.Now}
+}
+
+// rollover resets the budget when a new month starts, b.mu must be held
+func (b *budget) rollover() {
+	if month := b.now().UTC().Format("2006-01"); month != b.month {
+		b.month = month
+		b.used = 0
+		b.spent = 0
+		b.notified = false
+	}
+}
+
+// spend records a message sent and its cost
+func (b *budget) spend(cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	b.used++
+	b.spent += cost
+}
+
+// reached reports whether either limit is reached, b.mu must be held
+func (b *budget) reached() bool {
+	return (b.limit > 0 && b.used >= b.limit) || (b.amount > 0 && b.spent >= b.amount)
+}
+
+// exceeded reports whether the budget of the month is used up, and whether
+// the caller is the first one to notice it this month
+func (b *budget) exceeded() (exceeded bool, first bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	if !b.reached() {
+		return false, false
+	}
+	first = !b.notified
+	b.notified = true
+	return true, first
+}
+
+// usedUp reports whether the budget of the month is used up, without
+// counting as noticing it
+func (b *budget) usedUp() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	return b.reached()
+}
+
+// snapshot returns the usage of the month
+func (b *budget) snapshot() *persistedBudget {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	return &persistedBudget{Month: b.month, Used: b.used, Spent: b.spent, Notified: b.notified}
+}
+
+// restore replaces the usage of the month with a saved one
+func (b *budget) restore(saved *persistedBudget) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.month = saved.Month
+	b.used = saved.Used
+	b.spent = saved.Spent
+	b.notified = saved.Notified
+}
+
+// messageCost returns the price of a message sent, estimated from its
+// segments when Twilio didn't price it yet
+func messageCost(receipt *MessageReceipt, segmentPrice float64) float64 {
+	if receipt.Price > 0 {
+		return receipt.Price
+	}
+	segments := receipt.Segments
+	if segments < 1 {
+		segments = 1
+	}
+	return float64(segments) * segmentPrice
+}
+
+// describeBudget describes the limits of the budget of the configuration
+func describeBudget(o *Config) string {
+	var limits []string
+	if o.Budget > 0 {
+		limits = append(limits, fmt.Sprintf("%d messages", o.Budget))
+	}
+	if o.BudgetAmount > 0 {
+		limits = append(limits, fmt.Sprintf("%.2f", o.BudgetAmount))
+	}
+	return strings.Join(limits, " or ")
+}
@@ -0,0 +1,60 @@
+package promtotwilio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	now := time.Date(2019, time.January, 31, 23, 0, 0, 0, time.UTC)
+	b := newBudget(2, 0)
+	b.now = func() time.Time { return now }
+
+	b.spend(0)
+	if exceeded, _ := b.exceeded(); exceeded {
+		t.Fatalf("budget exceeded after 1 message")
+	}
+	b.spend(0)
+	if exceeded, first := b.exceeded(); !exceeded || !first {
+		t.Fatalf("exceeded() == %v, %v after 2 messages, want true, true", exceeded, first)
+	}
+	if exceeded, first := b.exceeded(); !exceeded || first {
+		t.Fatalf("exceeded() == %v, %v on second check, want true, false", exceeded, first)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if exceeded, _ := b.exceeded(); exceeded {
+		t.Errorf("budget not reset on a new month")
+	}
+}
+
+func TestBudgetAmount(t *testing.T) {
+	b := newBudget(0, 0.02)
+	b.spend(messageCost(&MessageReceipt{Segments: 2}, 0.0079))
+	if b.usedUp() {
+		t.Fatalf("budget used up after 0.0158")
+	}
+	b.spend(messageCost(&MessageReceipt{Segments: 1, Price: 0.0075}, 0.0079))
+	if !b.usedUp() {
+		t.Errorf("budget not used up after 0.0233")
+	}
+	if exceeded, first := b.exceeded(); !exceeded || !first {
+		t.Errorf("exceeded() == %v, %v, want true, true", exceeded, first)
+	}
+}
+
+func TestMessageCost(t *testing.T) {
+	tests := []struct {
+		receipt MessageReceipt
+		cost    float64
+	}{
+		{MessageReceipt{Segments: 3, Price: 0.0225}, 0.0225},
+		{MessageReceipt{Segments: 3}, 0.03},
+		{MessageReceipt{}, 0.01},
+	}
+	for _, test := range tests {
+		if cost := messageCost(&test.receipt, 0.01); cost != test.cost {
+			t.Errorf("messageCost(%+v) == %v, want %v", test.receipt, cost, test.cost)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package promtotwilio
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/buger/jsonparser"
+	"github.com/valyala/fasthttp"
+)
+
+// cloudEventsContentType is the Content-Type of structured mode CloudEvents
+const cloudEventsContentType = "application/cloudevents+json"
+
+// unwrapCloudEvent replaces in place a structured mode CloudEvent with the
+// Alertmanager payload of its data. The payload of binary mode CloudEvents,
+// which carry the event attributes in ce- headers, is already the body.
+func unwrapCloudEvent(ctx *fasthttp.RequestCtx) error {
+	if !strings.HasPrefix(string(ctx.Request.Header.ContentType()), cloudEventsContentType) {
+		if id := ctx.Request.Header.Peek("ce-id"); len(id) > 0 {
+			requestLogger(ctx).Debugf("Binary mode CloudEvent %s of type %s", id, ctx.Request.Header.Peek("ce-type"))
+		}
+		return nil
+	}
+
+	event := ctx.PostBody()
+	if contentType, _ := jsonparser.GetString(event, "datacontenttype"); contentType != "" && !strings.HasPrefix(contentType, "application/json") {
+		return errors.New("datacontenttype must be application/json")
+	}
+	var data []byte
+	if encoded, err := jsonparser.GetString(event, "data_base64"); err == nil {
+		if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return err
+		}
+	} else if value, dataType, _, err := jsonparser.Get(event, "data"); err == nil && dataType == jsonparser.Object {
+		data = value
+	} else {
+		return errors.New("data must be an Alertmanager payload")
+	}
+
+	id, _ := jsonparser.GetString(event, "id")
+	eventType, _ := jsonparser.GetString(event, "type")
+	requestLogger(ctx).Debugf("Structured mode CloudEvent %s of type %s", id, eventType)
+	ctx.Request.SetBody(data)
+	ctx.Request.Header.SetContentType("application/json")
+	return nil
+}
@@ -0,0 +1,36 @@
+package promtotwilio
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSendRequestCloudEvents(t *testing.T) {
+	const payload = `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"binary", "application/json", payload},
+		{"structured", "application/cloudevents+json; charset=utf-8", `{"specversion": "1.0", "id": "1", "type": "alertmanager", "source": "am", "datacontenttype": "application/json", "data": ` + payload + `}`},
+		{"base64", "application/cloudevents+json", `{"specversion": "1.0", "id": "1", "type": "alertmanager", "source": "am", "data_base64": "eyJzdGF0dXMiOiAiZmlyaW5nIiwgImFsZXJ0cyI6IFt7ImFubm90YXRpb25zIjogeyJzdW1tYXJ5IjogIkRpc2sgZnVsbCJ9fV19"}`},
+	}
+	for _, test := range tests {
+		client := &fakeTwilioClient{}
+		m := OptionsWithHandler{
+			Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+			Client:  client,
+		}
+
+		ctx := newSendRequestCtx("/cloudevents", test.body)
+		ctx.Request.Header.SetContentType(test.contentType)
+		ctx.Request.Header.Set("ce-specversion", "1.0")
+		m.HandleFastHTTP(ctx)
+
+		if ctx.Response.StatusCode() != fasthttp.StatusOK || len(client.messages) != 1 || client.messages[0].Body != "Disk full" {
+			t.Errorf("%s: status %d, sent %+v", test.name, ctx.Response.StatusCode(), client.messages)
+		}
+	}
+}
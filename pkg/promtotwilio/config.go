@@ -0,0 +1,290 @@
+package promtotwilio
+
+import (
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Config is the configuration of the bridge
+type Config struct {
+	AccountSid string
+	AuthToken  string
+	Receiver   string
+	Sender     string
+	// WhatsAppSender is the number messages are sent to WhatsApp receivers
+	// from, Sender when empty
+	WhatsAppSender string
+	// CountrySenders maps calling codes to the senders of the receivers
+	// whose number starts with them, instead of Sender
+	CountrySenders map[string][]string
+	// WebhookSecrets, when set, are the secrets accepted from the webhooks,
+	// several of them during a rotation
+	WebhookSecrets []string
+	// JWTJWKSURL, when set, is the JWKS the JWTs of the webhooks are
+	// verified with, accepted in addition to WebhookSecrets when their
+	// issuer and audience are JWTIssuer and JWTAudience
+	JWTJWKSURL  string
+	JWTIssuer   string
+	JWTAudience string
+	// AdminToken and AdminUser and AdminPassword, when set, are the bearer
+	// token and basic auth credentials protecting /metrics, /events, /ui and
+	// the admin endpoints
+	AdminToken    string
+	AdminUser     string
+	AdminPassword string
+	// Contacts maps contact names, usable wherever receivers are, to their
+	// receiver
+	Contacts map[string]string
+	// DefaultCountry, when set, is the ISO 3166 code of the country whose
+	// national numbers are converted to E.164
+	DefaultCountry string
+	// Groups maps group names to their receivers
+	Groups map[string][]string
+	// ReceiverMap maps Alertmanager receiver names to their receivers
+	ReceiverMap map[string][]string
+	// Routes are time based routing rules, evaluated in RoutingLocation
+	Routes          []*Route
+	RoutingLocation *time.Location
+	// Holidays are days treated like weekends by the routing rules
+	Holidays Holidays
+	// TwilioAPIURL overrides the Twilio API base URL, e.g. for a mock
+	TwilioAPIURL string
+	// TwilioProxy is the proxy used to reach the Twilio API, if any
+	TwilioProxy *url.URL
+	// TwilioTimeout bounds the requests to the Twilio API, 30 seconds when
+	// unset
+	TwilioTimeout time.Duration
+	// TwilioMaxIdleConns, TwilioIdleConnTimeout and TwilioKeepAlive, when
+	// set, are the number of idle connections to the Twilio API kept for
+	// reuse, how long they are kept, and the TCP keep-alive period of the
+	// connections
+	TwilioMaxIdleConns    int
+	TwilioIdleConnTimeout time.Duration
+	TwilioKeepAlive       time.Duration
+	// TwilioMaxConcurrent, when set, caps the number of requests made to
+	// the Twilio API at once, the messages beyond waiting for their turn
+	TwilioMaxConcurrent int
+	// ValidityPeriod and SmartEncoding are passed on to Twilio with every
+	// message
+	ValidityPeriod time.Duration
+	SmartEncoding  bool
+	// StatusCallbackURL, when set, is the URL of the /status endpoint Twilio
+	// reports the statuses of the messages to, the undelivered ones being
+	// sent again as SMS for WhatsApp messages, or else from
+	// EscalationSender or another sender
+	StatusCallbackURL string
+	EscalationSender  string
+	// Retry is the policy applied to failed Twilio requests
+	Retry RetryPolicy
+
+	// FilterInclude and FilterExclude select the alerts sent: all the
+	// include matchers must match, and not all the exclude ones
+	FilterInclude []*Matcher
+	FilterExclude []*Matcher
+	// PriorityAlerts are the matchers of the alerts whose messages bypass
+	// batching, alert storm suppression and the concurrency and queue bounds
+	PriorityAlerts []*Matcher
+	// Formatter, when set, builds the messages instead of the templates and
+	// options below
+	Formatter Formatter
+	// Template, when set, renders the whole message instead of the options below
+	Template *template.Template
+	// AlertTemplates override Template for some alert names
+	AlertTemplates map[string]*template.Template
+	// TemplateDir, when set, holds templates reloaded when they change,
+	// overriding the ones above
+	TemplateDir *TemplateDir
+	// Annotations lists, in order, the alert annotations joined into the message
+	Annotations []string
+	// Labels lists the alert labels appended to the message as key=value pairs
+	Labels []string
+	// SeverityPrefixes maps a severity label value to a message prefix
+	SeverityPrefixes map[string]string
+	// MaxAlertsPerWebhook, when set, is the number of alerts of a webhook
+	// whose messages are sent, the last one telling how many were left out
+	MaxAlertsPerWebhook int
+	// MaxMessageLength, when set, is the number of characters the messages
+	// are truncated to, according to TruncateStrategy and ending with
+	// TruncateEllipsis
+	MaxMessageLength int
+	TruncateStrategy string
+	TruncateEllipsis string
+	// IncludeExternalURL appends the Alertmanager URL to the message
+	IncludeExternalURL bool
+	// SendResolved also sends messages for resolved notifications
+	SendResolved bool
+	// FiringPrefix and ResolvedPrefix are prepended according to the alert status
+	FiringPrefix   string
+	ResolvedPrefix string
+	// LogFormat is the format of the access log, disabled when empty
+	LogFormat string
+	// LogFile, when set, receives the application and access logs instead of
+	// the standard outputs, and is rotated by size and age
+	LogFile           string
+	LogFileMaxSize    int64
+	LogFileMaxAge     time.Duration
+	LogFileMaxBackups int
+	// PayloadCaptureSize is the number of webhook payloads kept for debugging
+	PayloadCaptureSize int
+	// AuditLogFile, when set, is the file the admin actions are appended to
+	AuditLogFile string
+	// PagerDutyRoutingKey, when set, is the integration key of the PagerDuty
+	// service the alerts are sent to, through the Events API at PagerDutyURL
+	PagerDutyRoutingKey string
+	PagerDutyURL        string
+	// OpsgenieAPIKey, when set, is the API key of the Opsgenie integration
+	// the alerts are sent to, through the API at OpsgenieURL
+	OpsgenieAPIKey string
+	OpsgenieURL    string
+	// BatchInterval, when set, is how often messages of alerts without a
+	// severity of BatchBypassSeverities are sent as a single digest
+	BatchInterval         time.Duration
+	BatchBypassSeverities []string
+	// StormThreshold, when set, is the number of messages a receiver can get
+	// within StormWindow before the next ones are suppressed
+	StormThreshold int
+	StormWindow    time.Duration
+	// Budget, when set, is the number of messages which can be sent per month
+	// before only the alerts matching BudgetCritical are, BudgetAdmin being
+	// notified once
+	Budget         int
+	BudgetCritical []*Matcher
+	BudgetAdmin    string
+	// BudgetAmount, when set, is the amount the messages sent can cost per
+	// month, in the currency of the Twilio account, the messages Twilio
+	// didn't price yet costing BudgetSegmentPrice per segment
+	BudgetAmount       float64
+	BudgetSegmentPrice float64
+	// HistorySize is the number of messages listed by /admin/messages and
+	// the dashboard
+	HistorySize int
+	// NATSURL, when set, is the NATS server the payloads published on
+	// NATSSubject are received from, in the NATSQueue queue group
+	NATSURL     string
+	NATSSubject string
+	NATSQueue   string
+	// SQSQueueURL, when set, is the SQS queue the payloads are polled from,
+	// SQSBatchSize at a time, hidden from other pollers for
+	// SQSVisibilityTimeout while being sent
+	SQSQueueURL          string
+	SQSRegion            string
+	SQSBatchSize         int
+	SQSVisibilityTimeout time.Duration
+	// KafkaBrokers, when set, are the brokers of the Kafka cluster the
+	// payloads of KafkaTopic are consumed from, as a member of KafkaGroup,
+	// over TLS when KafkaTLS is set and authenticated with SASL PLAIN when
+	// KafkaUsername is
+	KafkaBrokers  []string
+	KafkaTopic    string
+	KafkaGroup    string
+	KafkaTLS      bool
+	KafkaUsername string
+	KafkaPassword string
+	// GRPCListenAddr, when set, is the address the SendAlert RPC is served
+	// on, with the certificate and key of GRPCTLSCert and GRPCTLSKey
+	GRPCListenAddr string
+	GRPCTLSCert    string
+	GRPCTLSKey     string
+	// AWSCredentials sign the requests to AWS
+	AWSCredentials AWSCredentials
+	// ArchiveURL, when set, is the s3:// or gs:// bucket and prefix the
+	// message history and captured payloads are uploaded to every
+	// ArchiveInterval, the archives older than ArchiveRetention being deleted
+	ArchiveURL       string
+	ArchiveRegion    string
+	ArchiveEndpoint  string
+	ArchiveInterval  time.Duration
+	ArchiveRetention time.Duration
+	// LeaderRedisURL, when set, is the Redis server holding the LeaderLeaseKey
+	// lease of an active/standby pair, only the instance holding it for
+	// LeaderLeaseDuration sends messages
+	LeaderRedisURL      string
+	LeaderLeaseKey      string
+	LeaderLeaseDuration time.Duration
+	LeaderID            string
+	// FlapThreshold, when set, is the number of status changes of an alert
+	// within FlapWindow from which it is considered flapping
+	FlapThreshold int
+	FlapWindow    time.Duration
+	// ForDuration, when set, is how long the messages of firing alerts are
+	// held back, being dropped if the alerts resolve in the meantime
+	ForDuration time.Duration
+	// IdempotencyTTL is how long the responses of /send requests with an
+	// Idempotency-Key are replayed to retries
+	IdempotencyTTL time.Duration
+	// HeartbeatReceiver, when set, gets a heartbeat message every
+	// HeartbeatInterval, or on demand
+	HeartbeatReceiver string
+	HeartbeatInterval time.Duration
+	// WatchdogTimeout, when set, is how long without webhooks before
+	// WatchdogReceiver is warned
+	WatchdogTimeout  time.Duration
+	WatchdogReceiver string
+	// ForwardURLs receive a copy of every webhook payload
+	ForwardURLs []string
+	// PingURL, when set, is requested after the successful webhooks
+	PingURL string
+	// StateFile, when set, is where the state of the duplicate detection,
+	// alert storm protection, budget and notified alerts is saved every
+	// StateSaveInterval
+	StateFile         string
+	StateSaveInterval time.Duration
+	// MaxConcurrentSends, when set, caps the number of webhooks served at
+	// once, the others being answered 503
+	MaxConcurrentSends int
+	// QueueMaxDepth, when set, bounds the number of messages waiting in the
+	// batch and delay queues, the webhooks received when they are full being
+	// answered 503
+	QueueMaxDepth int
+	// RequestTimeout, when set, bounds how long a request is served before
+	// being answered 503
+	RequestTimeout time.Duration
+	// DrainTimeout bounds how long in-flight requests are waited for on shutdown
+	DrainTimeout time.Duration
+	// ListenAddr is the address the server listens on
+	ListenAddr string
+	// AdminListenAddr, when set, is the address the operational endpoints
+	// are served on instead of ListenAddr
+	AdminListenAddr string
+	// AlertmanagerURL, when set, is the Alertmanager whose active silences
+	// are shown on the dashboard
+	AlertmanagerURL string
+	// Pprof serves the runtime profiles of net/http/pprof under
+	// /debug/pprof/ as admin endpoints
+	Pprof bool
+}
+
+// SplitList returns the non-empty, trimmed items of a comma separated list
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// contains reports whether the list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitMap parses a comma separated list of key=value pairs
+func SplitMap(s string) map[string]string {
+	m := make(map[string]string)
+	for _, item := range SplitList(s) {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 2 {
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return m
+}
@@ -0,0 +1,68 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// contact is an entry of the contacts file
+type contact struct {
+	Number string `json:"number"`
+	// Channel is sms, the default, or whatsapp
+	Channel string `json:"channel"`
+}
+
+// LoadContacts reads a JSON file mapping contact names to their number and
+// channel, and returns the receivers of the contacts by name
+func LoadContacts(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts map[string]contact
+	if err := json.Unmarshal(content, &contacts); err != nil {
+		return nil, err
+	}
+	receivers := make(map[string]string, len(contacts))
+	for name, c := range contacts {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, ",;=") {
+			return nil, fmt.Errorf("invalid contact name %q", name)
+		}
+		if c.Number == "" {
+			return nil, fmt.Errorf("contact %s: no number", name)
+		}
+		switch c.Channel {
+		case "", "sms":
+			receivers[name] = c.Number
+		case "whatsapp":
+			receivers[name] = whatsAppPrefix + c.Number
+		default:
+			return nil, fmt.Errorf("contact %s: unknown channel %q", name, c.Channel)
+		}
+	}
+	return receivers, nil
+}
+
+// resolveReceivers replaces the contact names among receivers by their
+// receiver and converts the national numbers of DEFAULT_COUNTRY to E.164,
+// leaving the other numbers as they are
+func resolveReceivers(o *Config, receivers []string) []string {
+	resolved := make([]string, len(receivers))
+	for i, receiver := range receivers {
+		if r, ok := o.Contacts[receiver]; ok {
+			receiver = r
+		}
+		if o.DefaultCountry != "" {
+			if strings.HasPrefix(receiver, whatsAppPrefix) {
+				receiver = whatsAppPrefix + toE164(strings.TrimPrefix(receiver, whatsAppPrefix), o.DefaultCountry)
+			} else {
+				receiver = toE164(receiver, o.DefaultCountry)
+			}
+		}
+		resolved[i] = receiver
+	}
+	return resolved
+}
@@ -0,0 +1,69 @@
+package promtotwilio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestLoadContacts(t *testing.T) {
+	path := writeTempFile(t, `{
+		"alice": {"number": "+100"},
+		"db-oncall": {"number": "+200", "channel": "whatsapp"}
+	}`)
+	defer os.Remove(path)
+
+	contacts, err := LoadContacts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"alice": "+100", "db-oncall": "whatsapp:+200"}
+	if !reflect.DeepEqual(contacts, expected) {
+		t.Errorf("LoadContacts() == %v, want %v", contacts, expected)
+	}
+}
+
+func TestLoadContactsInvalid(t *testing.T) {
+	for _, content := range []string{
+		`{"alice": {}}`,
+		`{"alice": {"number": "+100", "channel": "pigeon"}}`,
+		`{"alice,bob": {"number": "+100"}}`,
+	} {
+		path := writeTempFile(t, content)
+		if _, err := LoadContacts(path); err == nil {
+			t.Errorf("LoadContacts(%q) succeeded", content)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestRequestReceiversContacts(t *testing.T) {
+	o := &Config{
+		Receiver: "alice",
+		Groups:   map[string][]string{"db": {"db-oncall", "+300"}},
+		Contacts: map[string]string{"alice": "+100", "db-oncall": "whatsapp:+200"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"+100"}},
+		{"receiver=db-oncall,%2B400", []string{"whatsapp:+200", "+400"}},
+		{"group=db", []string{"whatsapp:+200", "+300"}},
+	}
+	for _, tt := range tests {
+		args := &fasthttp.Args{}
+		args.Parse(tt.query)
+		receivers, _, err := requestReceivers(o, args, "", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(receivers, tt.expected) {
+			t.Errorf("requestReceivers(%q) == %v, want %v", tt.query, receivers, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package promtotwilio
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// dashboardHTML is the template of the /ui page
+//
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// configItem is a setting shown on the dashboard
+type configItem struct {
+	Name  string
+	Value string
+}
+
+// dashboard renders an overview of the recent messages and of the
+// configuration, without the credentials
+func (m OptionsWithHandler) dashboard(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	data := struct {
+		Messages      []HistoryEntry
+		Failures      int
+		Batched       int
+		Delayed       int
+		Silences      []dashboardSilence
+		SilencesError string
+		Config        []configItem
+	}{Config: m.dashboardConfig()}
+	silences, err := m.silences()
+	data.Silences = silences
+	if err != nil {
+		data.SilencesError = redact(err.Error())
+	}
+	if m.History != nil {
+		data.Messages = m.History.list()
+	}
+	for _, message := range data.Messages {
+		if message.Status == "failed" {
+			data.Failures++
+		}
+	}
+	if m.Batcher != nil {
+		data.Batched = m.Batcher.depth()
+	}
+	if m.Delayer != nil {
+		data.Delayed = m.Delayer.depth()
+	}
+
+	ctx.SetContentType("text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(ctx, data); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
+
+// dashboardConfig lists the settings shown on the dashboard
+func (m OptionsWithHandler) dashboardConfig() []configItem {
+	o := m.Options
+	masked := func(numbers []string) string {
+		for i, n := range numbers {
+			numbers[i] = maskNumber(n)
+		}
+		return strings.Join(numbers, ", ")
+	}
+	disabled := func(set bool, value string) string {
+		if !set {
+			return "disabled"
+		}
+		return value
+	}
+
+	groups := make([]string, 0, len(o.Groups))
+	for name := range o.Groups {
+		groups = append(groups, name)
+	}
+
+	items := []configItem{
+		{"Senders", masked(SplitList(o.Sender))},
+		{"Default receivers", masked(SplitList(o.Receiver))},
+		{"Receiver groups", strings.Join(groups, ", ")},
+		{"Routing rules", fmt.Sprintf("%d, in %s", len(o.Routes), routingTime(o, time.Now()).Location())},
+		{"Retries", fmt.Sprintf("%d, within %s", o.Retry.MaxRetries, o.Retry.MaxElapsed)},
+		{"Send resolved", fmt.Sprint(o.SendResolved)},
+		{"Batch interval", disabled(o.BatchInterval > 0, o.BatchInterval.String())},
+		{"Storm threshold", disabled(o.StormThreshold > 0, fmt.Sprintf("%d in %s", o.StormThreshold, o.StormWindow))},
+		{"Duplicate detection", disabled(o.IdempotencyTTL > 0, o.IdempotencyTTL.String())},
+	}
+	if m.Budget != nil {
+		used := m.Budget.snapshot()
+		items = append(items, configItem{"SMS budget", fmt.Sprintf("%d messages sent this month for %.2f, of %s", used.Used, used.Spent, describeBudget(o))})
+	} else {
+		items = append(items, configItem{"SMS budget", "disabled"})
+	}
+	return items
+}
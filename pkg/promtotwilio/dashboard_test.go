@@ -0,0 +1,74 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestDashboard(t *testing.T) {
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+15550100", Receiver: "+15550001", AuthToken: "secret"},
+		History: newMessageHistory(10),
+	}
+	m.History.add("a", SendResult{Receiver: "+****0001", Alertname: "<DiskFull>", Status: "failed", Error: "twilio error"})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/ui")
+	m.HandleFastHTTP(ctx)
+	body := string(ctx.Response.Body())
+	for _, want := range []string{"1 of the last 1 messages failed", "&lt;DiskFull&gt;", "****0100"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(body, "secret") || strings.Contains(body, "+15550001") {
+		t.Errorf("dashboard leaks credentials or numbers")
+	}
+}
+
+func TestDashboardSilences(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/silences" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"id": "1", "status": {"state": "active"}, "matchers": [{"name": "env", "value": "staging", "isRegex": false, "isEqual": true}, {"name": "job", "value": "db.*", "isRegex": true, "isEqual": false}],
+			 "endsAt": "2030-01-01T10:00:00Z", "createdBy": "alice", "comment": "maintenance"},
+			{"id": "2", "status": {"state": "expired"}, "matchers": [{"name": "env", "value": "prod", "isRegex": false}], "endsAt": "2019-01-01T10:00:00Z", "comment": "past upgrade"}]`))
+	}))
+	defer server.Close()
+
+	storm := newStormGuard(1, time.Hour, func(receiver, text string) error { return nil })
+	storm.allow("+15550001")
+	storm.allow("+15550001")
+	m := OptionsWithHandler{
+		Options: &Config{AlertmanagerURL: server.URL},
+		Storm:   storm,
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/ui")
+	m.HandleFastHTTP(ctx)
+	body := string(ctx.Response.Body())
+	for _, want := range []string{`env=&#34;staging&#34;, job!~&#34;db.*&#34;`, "2030-01-01 10:00:00", "alice: maintenance", "receiver=&#43;****0001", "alert storm, 1 messages suppressed"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(body, "past upgrade") {
+		t.Errorf("dashboard shows an expired silence")
+	}
+
+	m.Options.AlertmanagerURL = "http://127.0.0.1:1"
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/ui")
+	m.HandleFastHTTP(ctx)
+	if body := string(ctx.Response.Body()); !strings.Contains(body, "Error fetching the Alertmanager silences") || !strings.Contains(body, "receiver=&#43;****0001") {
+		t.Errorf("dashboard doesn't show the error and the other silences")
+	}
+}
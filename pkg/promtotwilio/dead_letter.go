@@ -0,0 +1,127 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// deadLetterSize is the number of failed messages kept for replay
+const deadLetterSize = 1000
+
+// deadLetter is a message which couldn't be sent
+type deadLetter struct {
+	id        int
+	time      time.Time
+	requestID string
+	receiver  string
+	body      string
+	client    TwilioClient
+}
+
+// deadLetters keeps the failed messages, oldest first, so they can be sent
+// again after a Twilio or credentials outage
+type deadLetters struct {
+	mu      sync.Mutex
+	letters []deadLetter
+	nextID  int
+}
+
+func newDeadLetters() *deadLetters {
+	return &deadLetters{nextID: 1}
+}
+
+// add keeps a failed message, dropping the oldest one when full
+func (d *deadLetters) add(client TwilioClient, requestID, receiver, body string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.letters = append(d.letters, deadLetter{
+		id:        d.nextID,
+		time:      time.Now(),
+		requestID: requestID,
+		receiver:  receiver,
+		body:      body,
+		client:    client,
+	})
+	d.nextID++
+	if len(d.letters) > deadLetterSize {
+		d.letters = d.letters[1:]
+	}
+}
+
+// since returns the messages which failed after t, oldest first
+func (d *deadLetters) since(t time.Time) []deadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var letters []deadLetter
+	for _, l := range d.letters {
+		if !l.time.Before(t) {
+			letters = append(letters, l)
+		}
+	}
+	return letters
+}
+
+// remove forgets a message sent on replay
+func (d *deadLetters) remove(id int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, l := range d.letters {
+		if l.id == id {
+			d.letters = append(d.letters[:i], d.letters[i+1:]...)
+			return
+		}
+	}
+}
+
+// ReplayResponse is the body returned by /admin/replay
+type ReplayResponse struct {
+	Sent    int          `json:"sent"`
+	Failed  int          `json:"failed"`
+	Results []SendResult `json:"results"`
+}
+
+// replayFailed sends again, in order, the messages which failed within the
+// duration of the since query parameter
+func (m OptionsWithHandler) replayFailed(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	since, err := time.ParseDuration(string(ctx.QueryArgs().Peek("since")))
+	if err != nil || since <= 0 {
+		writeProblem(ctx, fasthttp.StatusBadRequest, "since must be a duration such as 1h")
+		return
+	}
+
+	logger := requestLogger(ctx)
+	response := ReplayResponse{Results: []SendResult{}}
+	before := len(m.DeadLetters.since(time.Time{}))
+	for _, l := range m.DeadLetters.since(time.Now().Add(-since)) {
+		logger.Infof("Replaying message %d of request %s", l.id, l.requestID)
+		result, err := m.sendMessage(l.client, logger, requestID(ctx), l.receiver, l.body)
+		if err != nil {
+			result = &SendResult{Receiver: l.receiver, Status: "failed", Error: redact(err.Error())}
+			response.Failed++
+		} else {
+			m.DeadLetters.remove(l.id)
+			response.Sent++
+		}
+		result.Receiver = maskNumber(result.Receiver)
+		response.Results = append(response.Results, *result)
+	}
+	m.audit(ctx, "dead_letters.replay",
+		map[string]interface{}{"since": since.String(), "failed_messages": before},
+		map[string]interface{}{"sent": response.Sent, "failed": response.Failed, "failed_messages": len(m.DeadLetters.since(time.Time{}))})
+
+	ctx.SetContentType("application/json")
+	if err := json.NewEncoder(ctx).Encode(response); err != nil {
+		logger.Errorf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestReplayFailed(t *testing.T) {
+	client := &fakeTwilioClient{err: errors.New("twilio down")}
+	m := OptionsWithHandler{
+		Options:     &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:      client,
+		DeadLetters: newDeadLetters(),
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B15550001,%2B15550002", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+
+	client.err = nil
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/admin/replay?since=1h")
+	m.HandleFastHTTP(ctx)
+
+	var response ReplayResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 2 || response.Failed != 0 || len(client.messages) != 2 || client.messages[0].Body != "Disk full" {
+		t.Fatalf("unexpected response %+v, messages %+v", response, client.messages)
+	}
+	if letters := m.DeadLetters.since(time.Time{}); len(letters) != 0 {
+		t.Errorf("%d messages left after replay, want 0", len(letters))
+	}
+}
+
+func TestReplayFailedRedacted(t *testing.T) {
+	client := &fakeTwilioClient{err: errors.New("twilio down")}
+	m := OptionsWithHandler{
+		Options:     &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:      client,
+		DeadLetters: newDeadLetters(),
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B15550100001", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+
+	client.err = errors.New("Twilio error 21211: invalid 'To' number +15550100001")
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/admin/replay?since=1h")
+	m.HandleFastHTTP(ctx)
+
+	if body := string(ctx.Response.Body()); strings.Contains(body, "+15550100001") {
+		t.Errorf("replay response contains the number: %s", body)
+	}
+}
@@ -0,0 +1,104 @@
+package promtotwilio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+// delayer holds back messages of firing alerts, so that alerts resolving
+// on their own within the delay don't page anyone
+type delayer struct {
+	mu      sync.Mutex
+	pending map[string][]*delayedMessage
+}
+
+// delayedMessage is a message waiting for its delay to elapse
+type delayedMessage struct {
+	timer *time.Timer
+	send  func()
+}
+
+func newDelayer() *delayer {
+	return &delayer{pending: make(map[string][]*delayedMessage)}
+}
+
+// alertKey identifies an alert across notifications by its fingerprint, or
+// its labels for Alertmanager versions not sending one
+func alertKey(alert []byte) string {
+	if fingerprint, _ := jsonparser.GetString(alert, "fingerprint"); fingerprint != "" {
+		return fingerprint
+	}
+	labels, _, _, _ := jsonparser.Get(alert, "labels")
+	return string(labels)
+}
+
+// schedule calls send after the delay unless the alert is cancelled first
+func (d *delayer) schedule(key string, delay time.Duration, send func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	message := &delayedMessage{send: send}
+	message.timer = time.AfterFunc(delay, func() {
+		if d.remove(key, message) {
+			send()
+		}
+	})
+	d.pending[key] = append(d.pending[key], message)
+}
+
+// remove forgets a pending message, reporting whether it was still pending
+func (d *delayer) remove(key string, message *delayedMessage) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, m := range d.pending[key] {
+		if m == message {
+			d.pending[key] = append(d.pending[key][:i], d.pending[key][i+1:]...)
+			if len(d.pending[key]) == 0 {
+				delete(d.pending, key)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// cancel drops the pending messages of the alert, reporting whether there
+// were any
+func (d *delayer) cancel(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	messages, ok := d.pending[key]
+	for _, m := range messages {
+		m.timer.Stop()
+	}
+	delete(d.pending, key)
+	return ok
+}
+
+// Stop sends the pending messages right away
+func (d *delayer) Stop() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[string][]*delayedMessage)
+	d.mu.Unlock()
+
+	for _, messages := range pending {
+		for _, m := range messages {
+			if m.timer.Stop() {
+				m.send()
+			}
+		}
+	}
+}
+
+// depth returns the number of messages waiting for their delay to elapse
+func (d *delayer) depth() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, messages := range d.pending {
+		n += len(messages)
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package promtotwilio
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDelayer(t *testing.T) {
+	d := newDelayer()
+	var sent int32
+	send := func() { atomic.AddInt32(&sent, 1) }
+
+	d.schedule("a", 10*time.Millisecond, send)
+	d.schedule("b", 10*time.Millisecond, send)
+	if !d.cancel("b") {
+		t.Errorf("cancel(%q) == false, want true", "b")
+	}
+	if d.cancel("c") {
+		t.Errorf("cancel(%q) == true, want false", "c")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&sent); got != 1 {
+		t.Errorf("%d messages sent, want 1", got)
+	}
+	if d.cancel("a") {
+		t.Errorf("cancel(%q) == true after the message was sent", "a")
+	}
+}
+
+func TestDelayerStop(t *testing.T) {
+	d := newDelayer()
+	var sent int32
+	d.schedule("a", time.Hour, func() { atomic.AddInt32(&sent, 1) })
+	d.Stop()
+	if got := atomic.LoadInt32(&sent); got != 1 {
+		t.Errorf("%d messages sent on stop, want 1", got)
+	}
+}
+
+func TestAlertKey(t *testing.T) {
+	tests := []struct {
+		alert string
+		want  string
+	}{
+		{`{"fingerprint": "abc", "labels": {"a": "b"}}`, "abc"},
+		{`{"labels": {"a": "b"}}`, `{"a": "b"}`},
+	}
+	for _, test := range tests {
+		if got := alertKey([]byte(test.alert)); got != test.want {
+			t.Errorf("alertKey(%q) == %q, want %q", test.alert, got, test.want)
+		}
+	}
+}
+
+func TestSendRequestDelayedRecorded(t *testing.T) {
+	m := OptionsWithHandler{
+		Options:     &Config{Sender: "+100", Receiver: "+15550001", Annotations: []string{"summary"}, ForDuration: time.Hour},
+		Client:      &fakeTwilioClient{err: errors.New("boom")},
+		Delayer:     newDelayer(),
+		History:     newMessageHistory(10),
+		DeadLetters: newDeadLetters(),
+	}
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"status": "firing", "labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+	// the request buffers are reused once the response is written
+	ctx.Request.SetBodyString(`{"status": "resolved", "alerts": [{"labels": {"alertname": "Overwritten"}}]}`)
+	m.Delayer.Stop()
+
+	entries := m.History.list()
+	if len(entries) != 2 || entries[0].Status != "failed" || entries[0].Alertname != "DiskFull" || entries[1].Status != "delayed" {
+		t.Errorf("history == %+v, want the failed delayed message", entries)
+	}
+	if letters := m.DeadLetters.since(time.Time{}); len(letters) != 1 || letters[0].body != "Disk full" {
+		t.Errorf("dead letters == %+v, want the delayed message", letters)
+	}
+}
@@ -0,0 +1,176 @@
+package promtotwilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// maxTrackedMessages bounds the number of messages whose delivery is
+// tracked, the oldest ones being forgotten
+const maxTrackedMessages = 10000
+
+// deliveryTracker remembers the messages sent with a status callback until
+// Twilio reports whether they were delivered, to escalate the ones which
+// weren't
+type deliveryTracker struct {
+	mu       sync.Mutex
+	messages map[string]trackedMessage
+	// sids lists the tracked messages, oldest first
+	sids []string
+}
+
+// trackedMessage is a message waiting for its final status
+type trackedMessage struct {
+	Message
+	// client is the client of the account the message was sent from, that
+	// of the subaccount of its route if any
+	client TwilioClient
+	// escalated is set for the messages sent again through another channel,
+	// which aren't escalated further
+	escalated bool
+}
+
+func newDeliveryTracker() *deliveryTracker {
+	return &deliveryTracker{messages: make(map[string]trackedMessage)}
+}
+
+// track remembers a message sent with the given SID
+func (d *deliveryTracker) track(sid string, message trackedMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages[sid] = message
+	d.sids = append(d.sids, sid)
+	for len(d.sids) > maxTrackedMessages {
+		delete(d.messages, d.sids[0])
+		d.sids = d.sids[1:]
+	}
+}
+
+// take returns the message with the given SID, forgetting it
+func (d *deliveryTracker) take(sid string) (trackedMessage, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	message, ok := d.messages[sid]
+	delete(d.messages, sid)
+	return message, ok
+}
+
+// finalStatus reports whether a message status reported by Twilio is final
+func finalStatus(status string) bool {
+	switch status {
+	case "delivered", "read", "undelivered", "failed":
+		return true
+	}
+	return false
+}
+
+// twilioSignature returns the X-Twilio-Signature of a request to the URL
+// with the form parameters, signed with the auth token
+func twilioSignature(authToken, url string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(url))
+	for _, key := range keys {
+		mac.Write([]byte(key + params[key]))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// statusCallback handles the status callbacks of the messages, sending the
+// undelivered ones again through another channel
+func (m OptionsWithHandler) statusCallback(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+	params := make(map[string]string)
+	ctx.PostArgs().VisitAll(func(key, value []byte) {
+		params[string(key)] = string(value)
+	})
+	if m.Options.AuthToken != "" {
+		signature := twilioSignature(m.Options.AuthToken, m.Options.StatusCallbackURL, params)
+		if !hmac.Equal([]byte(signature), ctx.Request.Header.Peek("X-Twilio-Signature")) {
+			writeProblem(ctx, fasthttp.StatusForbidden, "invalid Twilio signature")
+			return
+		}
+	}
+
+	sid, status := params["MessageSid"], params["MessageStatus"]
+	logger := requestLogger(ctx).WithField("sid", sid)
+	if !finalStatus(status) {
+		return
+	}
+	message, tracked := m.Deliveries.take(sid)
+	if status != "undelivered" && status != "failed" {
+		return
+	}
+
+	smsUndeliveredTotal.WithLabelValues(status).Inc()
+	logger.Warnf("Message to %s %s, error code %s", maskNumber(params["To"]), status, params["ErrorCode"])
+	if !tracked || message.escalated {
+		return
+	}
+	if escalation, ok := m.escalation(message.Message); ok {
+		// the escalations are sent in the background, Twilio expecting a
+		// quick response
+		go m.escalate(logger, message.client, escalation)
+	}
+}
+
+// escalation returns the message to send again through another channel: as
+// SMS for WhatsApp messages, or else from ESCALATION_SENDER or another
+// sender of the pool. It returns false when there is no other channel.
+func (m OptionsWithHandler) escalation(message Message) (Message, bool) {
+	if strings.HasPrefix(message.To, whatsAppPrefix) {
+		message.To = strings.TrimPrefix(message.To, whatsAppPrefix)
+		message.From = m.sender(message.To)
+		return message, true
+	}
+	if sender := m.Options.EscalationSender; sender != "" && sender != message.From {
+		message.From = sender
+		return message, true
+	}
+	if m.Senders != nil {
+		for _, sender := range m.Senders.senders {
+			if sender != message.From {
+				message.From = sender
+				return message, true
+			}
+		}
+	}
+	return message, false
+}
+
+// escalate sends an undelivered message again through another channel from
+// the account of the client, tracking it without escalating it further
+func (m OptionsWithHandler) escalate(logger *log.Entry, client TwilioClient, message Message) {
+	if client == nil {
+		client = m.Client
+	}
+	messagesEscalatedTotal.Inc()
+	message.StatusCallback = m.Options.StatusCallbackURL
+	receipt, err := client.SendMessage(&message)
+	if err != nil {
+		messagesFailedTotal.Inc()
+		logger.Errorf("Error escalating the message to %s: %v", maskNumber(message.To), err)
+		return
+	}
+	messagesSentTotal.Inc()
+	if m.Budget != nil {
+		m.Budget.spend(messageCost(receipt, m.Options.BudgetSegmentPrice))
+	}
+	m.Deliveries.track(receipt.Sid, trackedMessage{Message: message, client: client, escalated: true})
+	logger.Infof("Message escalated to %s from %s as %s", maskNumber(message.To), maskNumber(message.From), receipt.Sid)
+}
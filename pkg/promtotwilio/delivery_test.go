@@ -0,0 +1,153 @@
+package promtotwilio
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+)
+
+const statusCallbackURL = "https://bridge.example.com/v1/status"
+
+// newStatusCallbackCtx returns a status callback of Twilio about a message,
+// signed with the auth token
+func newStatusCallbackCtx(authToken, sid, to, status string) *fasthttp.RequestCtx {
+	params := map[string]string{"MessageSid": sid, "To": to, "MessageStatus": status}
+	form := url.Values{}
+	for key, value := range params {
+		form.Set(key, value)
+	}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	ctx.Request.Header.Set("X-Twilio-Signature", twilioSignature(authToken, statusCallbackURL, params))
+	ctx.Request.SetRequestURI("/v1/status")
+	ctx.Request.SetBodyString(form.Encode())
+	return ctx
+}
+
+// waitMessages waits for the client to have sent n messages
+func waitMessages(t *testing.T, client *fakeTwilioClient, n int) []*Message {
+	deadline := time.Now().Add(time.Second)
+	for {
+		client.mu.Lock()
+		messages := append([]*Message(nil), client.messages...)
+		client.mu.Unlock()
+		if len(messages) >= n || time.Now().After(deadline) {
+			return messages
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStatusCallbackEscalation(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:    &Config{AuthToken: "secret", StatusCallbackURL: statusCallbackURL},
+		Client:     client,
+		Senders:    newSenderPool([]string{"+100", "+101"}),
+		Deliveries: newDeliveryTracker(),
+	}
+	if _, err := m.SendMessage("+200", "Disk full"); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.messages[0].StatusCallback; got != statusCallbackURL {
+		t.Errorf("StatusCallback == %q, want %q", got, statusCallbackURL)
+	}
+
+	before := testutil.ToFloat64(smsUndeliveredTotal.WithLabelValues("undelivered"))
+	m.HandleFastHTTP(newStatusCallbackCtx("secret", "SM1", "+200", "undelivered"))
+	messages := waitMessages(t, client, 2)
+	if len(messages) != 2 || messages[1].From != "+101" || messages[1].To != "+200" || messages[1].Body != "Disk full" {
+		t.Fatalf("escalated messages == %+v, want Disk full to +200 from +101", messages)
+	}
+	if got := testutil.ToFloat64(smsUndeliveredTotal.WithLabelValues("undelivered")); got != before+1 {
+		t.Errorf("smsUndeliveredTotal == %g, want %g", got, before+1)
+	}
+
+	// the escalated messages aren't escalated again
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		m.Deliveries.mu.Lock()
+		_, tracked := m.Deliveries.messages["SM2"]
+		m.Deliveries.mu.Unlock()
+		if tracked || time.Now().After(deadline) {
+			break
+		}
+	}
+	m.HandleFastHTTP(newStatusCallbackCtx("secret", "SM2", "+200", "failed"))
+	time.Sleep(50 * time.Millisecond)
+	if messages := waitMessages(t, client, 0); len(messages) != 2 {
+		t.Errorf("escalated message escalated again: %+v", messages)
+	}
+}
+
+func TestStatusCallbackEscalationSubaccount(t *testing.T) {
+	office := &Route{Hours: "00:00-24:00", Receivers: []string{"+300"}, AccountSid: "AC-team"}
+	if err := office.init(); err != nil {
+		t.Fatal(err)
+	}
+	client, subaccount := &fakeTwilioClient{}, &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:     &Config{AuthToken: "secret", StatusCallbackURL: statusCallbackURL, Sender: "+100", EscalationSender: "+101", Annotations: []string{"summary"}, Routes: []*Route{office}},
+		Client:      client,
+		Subaccounts: map[string]TwilioClient{"AC-team": subaccount},
+		Deliveries:  newDeliveryTracker(),
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+
+	m.HandleFastHTTP(newStatusCallbackCtx("secret", "SM1", "+300", "undelivered"))
+	messages := waitMessages(t, subaccount, 2)
+	if len(messages) != 2 || messages[1].From != "+101" || messages[1].To != "+300" {
+		t.Errorf("escalated messages == %+v, want Disk full to +300 from +101", messages)
+	}
+	if len(client.messages) != 0 {
+		t.Errorf("escalated from the default account: %+v", client.messages)
+	}
+}
+
+func TestStatusCallbackSignature(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:    &Config{AuthToken: "secret", StatusCallbackURL: statusCallbackURL},
+		Client:     client,
+		Senders:    newSenderPool([]string{"+100", "+101"}),
+		Deliveries: newDeliveryTracker(),
+	}
+	m.SendMessage("+200", "Disk full")
+
+	ctx := newStatusCallbackCtx("other", "SM1", "+200", "undelivered")
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusForbidden)
+	}
+	if _, tracked := m.Deliveries.take("SM1"); !tracked {
+		t.Errorf("message forgotten on a forged callback")
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	tests := []struct {
+		escalationSender string
+		senders          []string
+		message          Message
+		want             Message
+		ok               bool
+	}{
+		{"", []string{"+100"}, Message{From: "whatsapp:+100", To: "whatsapp:+200"}, Message{From: "+100", To: "+200"}, true},
+		{"+199", []string{"+100"}, Message{From: "+100", To: "+200"}, Message{From: "+199", To: "+200"}, true},
+		{"", []string{"+100", "+101"}, Message{From: "+100", To: "+200"}, Message{From: "+101", To: "+200"}, true},
+		{"", []string{"+100"}, Message{From: "+100", To: "+200"}, Message{From: "+100", To: "+200"}, false},
+	}
+	for _, tt := range tests {
+		m := OptionsWithHandler{
+			Options: &Config{EscalationSender: tt.escalationSender},
+			Senders: newSenderPool(tt.senders),
+		}
+		got, ok := m.escalation(tt.message)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("escalation(%+v) == %+v, %v, want %+v, %v", tt.message, got, ok, tt.want, tt.ok)
+		}
+	}
+}
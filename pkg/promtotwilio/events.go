@@ -0,0 +1,128 @@
+package promtotwilio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// eventKeepAlive is how often a comment is sent on idle event streams, so
+// proxies don't close them
+const eventKeepAlive = 15 * time.Second
+
+// Event is an activity of promtotwilio streamed on /events
+type Event struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// eventBroker fans out the events to the /events subscribers
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+	closed      bool
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan Event]bool)}
+}
+
+// subscribe returns a channel receiving the next events, closed on Close
+func (b *eventBroker) subscribe() chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Event, 64)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[ch] = true
+	return ch
+}
+
+// unsubscribe stops sending events to the channel
+func (b *eventBroker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends an event to the subscribers, dropping it for the ones too
+// slow to keep up rather than blocking the sends
+func (b *eventBroker) publish(eventType string, data interface{}) {
+	event := Event{Type: eventType, Time: time.Now().UTC(), Data: data}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close ends the streams of the subscribers
+func (b *eventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		close(ch)
+	}
+	b.subscribers = make(map[chan Event]bool)
+	b.closed = true
+}
+
+// writeEvent writes an event in the Server-Sent Events format
+func writeEvent(w *bufio.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return w.Flush()
+}
+
+// streamEvents streams the events as Server-Sent Events until the client
+// goes away or the broker is closed
+func (m OptionsWithHandler) streamEvents(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	events := m.Events.subscribe()
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer m.Events.unsubscribe(events)
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		// lets the client know the stream is open
+		fmt.Fprint(w, ": connected\n\n")
+		if w.Flush() != nil {
+			return
+		}
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok || writeEvent(w, event) != nil {
+					return
+				}
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package promtotwilio
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEventBroker(t *testing.T) {
+	b := newEventBroker()
+	first, second := b.subscribe(), b.subscribe()
+	b.unsubscribe(second)
+
+	b.publish("sent", SendResult{Receiver: "+****0001", Status: "queued"})
+	event := <-first
+	if event.Type != "sent" {
+		t.Errorf("event type == %q, want %q", event.Type, "sent")
+	}
+	if _, ok := <-second; ok {
+		t.Errorf("unsubscribed channel received an event")
+	}
+
+	b.Close()
+	if _, ok := <-first; ok {
+		t.Errorf("channel not closed on Close")
+	}
+	if _, ok := <-b.subscribe(); ok {
+		t.Errorf("subscribing to a closed broker returned an open channel")
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := writeEvent(w, Event{Type: "failed", Data: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "event: failed\ndata: {\"type\":\"failed\"") || !strings.HasSuffix(got, "\n\n") {
+		t.Errorf("writeEvent() wrote %q", got)
+	}
+}
@@ -0,0 +1,113 @@
+package promtotwilio
+
+import (
+	"sync"
+	"time"
+)
+
+// flapStatus is the outcome of the flap detection for a notification
+type flapStatus struct {
+	flapping bool
+	// started is true for the first notification of an alert once flapping
+	started     bool
+	transitions int
+}
+
+// flapState tracks the status changes of an alert
+type flapState struct {
+	status      string
+	transitions []time.Time
+	flapping    bool
+	seen        time.Time
+}
+
+// flapDetector detects the alerts changing status at least threshold times
+// within window, whose notifications are collapsed into a single message
+// until they settle
+type flapDetector struct {
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	alerts map[string]*flapState
+}
+
+func newFlapDetector(threshold int, window time.Duration) *flapDetector {
+	return &flapDetector{
+		threshold: threshold,
+		window:    window,
+		now:       time.Now,
+		alerts:    make(map[string]*flapState),
+	}
+}
+
+// observe records the status of a notification of the alert
+func (d *flapDetector) observe(key, status string) flapStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	// the transitions of the alerts not seen within the window expired, so
+	// they are removed whether they were flapping or not
+	for k, state := range d.alerts {
+		if now.Sub(state.seen) > d.window {
+			delete(d.alerts, k)
+		}
+	}
+
+	state, ok := d.alerts[key]
+	if !ok {
+		state = &flapState{}
+		d.alerts[key] = state
+	}
+	if state.status != "" && state.status != status {
+		state.transitions = append(state.transitions, now)
+	}
+	state.status = status
+	state.seen = now
+
+	i := 0
+	for i < len(state.transitions) && now.Sub(state.transitions[i]) > d.window {
+		i++
+	}
+	state.transitions = state.transitions[i:]
+
+	if len(state.transitions) < d.threshold {
+		state.flapping = false
+		return flapStatus{transitions: len(state.transitions)}
+	}
+	started := !state.flapping
+	state.flapping = true
+	return flapStatus{flapping: true, started: started, transitions: len(state.transitions)}
+}
+
+// snapshot returns the state of the alerts
+func (d *flapDetector) snapshot() map[string]persistedFlap {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	flaps := make(map[string]persistedFlap, len(d.alerts))
+	for key, state := range d.alerts {
+		flaps[key] = persistedFlap{
+			Status:      state.status,
+			Transitions: append([]time.Time(nil), state.transitions...),
+			Flapping:    state.flapping,
+			Seen:        state.seen,
+		}
+	}
+	return flaps
+}
+
+// restore adds the saved state of alerts
+func (d *flapDetector) restore(flaps map[string]persistedFlap) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, flap := range flaps {
+		d.alerts[key] = &flapState{
+			status:      flap.Status,
+			transitions: flap.Transitions,
+			flapping:    flap.Flapping,
+			seen:        flap.Seen,
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package promtotwilio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlapDetector(t *testing.T) {
+	now := time.Now()
+	d := newFlapDetector(3, 10*time.Minute)
+	d.now = func() time.Time { return now }
+
+	tests := []struct {
+		status   string
+		expected flapStatus
+	}{
+		{"firing", flapStatus{}},
+		{"resolved", flapStatus{transitions: 1}},
+		{"firing", flapStatus{transitions: 2}},
+		{"firing", flapStatus{transitions: 2}},
+		{"resolved", flapStatus{flapping: true, started: true, transitions: 3}},
+		{"firing", flapStatus{flapping: true, transitions: 4}},
+	}
+	for i, test := range tests {
+		now = now.Add(time.Minute)
+		if got := d.observe("a", test.status); got != test.expected {
+			t.Errorf("observe() #%d == %+v, want %+v", i+1, got, test.expected)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if got := d.observe("a", "firing"); got.flapping {
+		t.Errorf("alert still flapping after the window")
+	}
+}
+
+func TestFlapDetectorPrune(t *testing.T) {
+	now := time.Now()
+	d := newFlapDetector(2, 10*time.Minute)
+	d.now = func() time.Time { return now }
+
+	for _, status := range []string{"firing", "resolved", "firing"} {
+		now = now.Add(time.Minute)
+		d.observe("a", status)
+	}
+	if !d.alerts["a"].flapping {
+		t.Fatalf("alert not flapping")
+	}
+
+	now = now.Add(11 * time.Minute)
+	d.observe("b", "firing")
+	if _, ok := d.alerts["a"]; ok || len(d.alerts) != 1 {
+		t.Errorf("alerts == %v, want the flapping alert removed", d.alerts)
+	}
+}
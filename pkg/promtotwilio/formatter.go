@@ -0,0 +1,114 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Alert is an alert of a webhook payload
+type Alert struct {
+	Status       string
+	Labels       map[string]string
+	Annotations  map[string]string
+	StartsAt     time.Time
+	EndsAt       time.Time
+	GeneratorURL string
+	Fingerprint  string
+
+	// raw is the JSON object of the alert in the payload
+	raw []byte
+}
+
+// parseAlert decodes an alert of the payload, whose status falls back to
+// the one of the payload. It is lenient as Alertmanager is not the only
+// sender: the timestamps which aren't RFC 3339 are left zero and the labels
+// and annotations which aren't strings keep their JSON text
+func parseAlert(meta *PayloadMeta, raw []byte) (*Alert, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	alert := &Alert{
+		Status:       jsonString(fields["status"]),
+		Labels:       jsonStringMap(fields["labels"]),
+		Annotations:  jsonStringMap(fields["annotations"]),
+		StartsAt:     jsonTime(fields["startsAt"]),
+		EndsAt:       jsonTime(fields["endsAt"]),
+		GeneratorURL: jsonString(fields["generatorURL"]),
+		Fingerprint:  jsonString(fields["fingerprint"]),
+		raw:          raw,
+	}
+	if alert.Status == "" {
+		alert.Status = meta.Status
+	}
+	return alert, nil
+}
+
+// jsonString returns a JSON string, or the JSON text of other values, null
+// being empty
+func jsonString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if text := string(bytes.TrimSpace(raw)); text != "null" {
+		return text
+	}
+	return ""
+}
+
+// jsonStringMap returns the members of a JSON object as strings, nil when
+// it isn't one
+func jsonStringMap(raw json.RawMessage) map[string]string {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil || members == nil {
+		return nil
+	}
+	m := make(map[string]string, len(members))
+	for name, value := range members {
+		m[name] = jsonString(value)
+	}
+	return m
+}
+
+// jsonTime returns an RFC 3339 JSON string as a time, the zero time when it
+// isn't one
+func jsonTime(raw json.RawMessage) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, jsonString(raw))
+	return t
+}
+
+// Formatter builds the text message of an alert of a payload, to replace
+// the default format of the messages
+type Formatter interface {
+	Format(alert *Alert, meta PayloadMeta) (string, error)
+}
+
+// defaultFormatter formats the messages with the templates, or else the
+// annotations, of the configuration
+type defaultFormatter struct {
+	o *Config
+}
+
+func (f defaultFormatter) Format(alert *Alert, meta PayloadMeta) (string, error) {
+	return formatMessage(f.o, &meta, alert.raw), nil
+}
+
+// format builds the text message of an alert with the formatter of the
+// configuration, or else the default one
+func (m OptionsWithHandler) format(meta *PayloadMeta, raw []byte) (string, error) {
+	var formatter Formatter = defaultFormatter{m.Options}
+	if m.Options.Formatter != nil {
+		formatter = m.Options.Formatter
+	}
+	alert, err := parseAlert(meta, raw)
+	if err != nil {
+		if m.Options.Formatter != nil {
+			return "", err
+		}
+		// the default format reads the raw alert
+		alert = &Alert{Status: meta.Status, raw: raw}
+	}
+	return formatter.Format(alert, *meta)
+}
@@ -0,0 +1,63 @@
+package promtotwilio
+
+import (
+	"testing"
+)
+
+type alertnameFormatter struct{}
+
+func (alertnameFormatter) Format(alert *Alert, meta PayloadMeta) (string, error) {
+	return alert.Status + " " + alert.Labels["alertname"] + " for " + meta.Receiver, nil
+}
+
+func TestSendRequestFormatter(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, Formatter: alertnameFormatter{}},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "receiver": "sms", "alerts": [{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+
+	if len(client.messages) != 1 || client.messages[0].Body != "firing DiskFull for sms" {
+		t.Errorf("unexpected messages sent: %+v", client.messages)
+	}
+}
+
+func TestSendRequestLenientAlerts(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert string
+	}{
+		{"empty startsAt", `{"startsAt": "", "labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}`},
+		{"invalid startsAt", `{"startsAt": "yesterday", "labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}`},
+		{"non-string label", `{"labels": {"alertname": "DiskFull", "priority": 1, "paged": true}, "annotations": {"summary": "Disk full"}}`},
+	}
+	for _, test := range tests {
+		for _, formatter := range []Formatter{nil, alertnameFormatter{}} {
+			client := &fakeTwilioClient{}
+			m := OptionsWithHandler{
+				Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, Formatter: formatter},
+				Client:  client,
+			}
+
+			ctx := newSendRequestCtx("/send", `{"status": "firing", "receiver": "sms", "alerts": [`+test.alert+`]}`)
+			m.HandleFastHTTP(ctx)
+
+			if len(client.messages) != 1 {
+				t.Errorf("%s: %d messages sent with formatter %T, want 1: %s", test.name, len(client.messages), formatter, ctx.Response.Body())
+			}
+		}
+	}
+}
+
+func TestParseAlert(t *testing.T) {
+	alert, err := parseAlert(&PayloadMeta{Status: "firing"}, []byte(`{"startsAt": "2019-01-02T03:04:05Z", "endsAt": "0001-01-01T00:00:00Z", "labels": {"alertname": "DiskFull", "priority": 1, "team": null}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.Status != "firing" || alert.Labels["priority"] != "1" || alert.Labels["team"] != "" || alert.StartsAt.Year() != 2019 || !alert.EndsAt.IsZero() {
+		t.Errorf("parseAlert() == %+v", alert)
+	}
+}
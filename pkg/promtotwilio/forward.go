@@ -0,0 +1,130 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// forwardMaxRetries is the number of times a failed forward is retried
+	forwardMaxRetries = 3
+	// forwardRetryBase is the delay before the first retry, doubled after
+	// every retry
+	forwardRetryBase = time.Second
+	// forwardWorkers is the number of payloads posted at once
+	forwardWorkers = 4
+	// forwardQueueSize is the number of payloads waiting for a worker
+	// beyond which they are dropped
+	forwardQueueSize = 100
+)
+
+// forwarder re-posts the webhook payloads to other URLs in the background,
+// so that promtotwilio can sit in front of another consumer. The payloads
+// wait in a bounded queue for one of the workers posting them.
+type forwarder struct {
+	urls    []string
+	client  *http.Client
+	sleep   func(time.Duration)
+	workers int
+
+	queue chan forwardJob
+	wg    sync.WaitGroup
+}
+
+// forwardJob is a payload to post to a URL
+type forwardJob struct {
+	url         string
+	requestID   string
+	contentType string
+	payload     []byte
+}
+
+func newForwarder(urls []string, workers, queueSize int) *forwarder {
+	return &forwarder{
+		urls:    urls,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		sleep:   time.Sleep,
+		workers: workers,
+		queue:   make(chan forwardJob, queueSize),
+	}
+}
+
+// start starts the workers posting the payloads until Stop is called
+func (f *forwarder) start() {
+	for i := 0; i < f.workers; i++ {
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			for job := range f.queue {
+				f.deliver(job)
+			}
+		}()
+	}
+}
+
+// forward queues the payload for every URL, dropping it for the URLs it
+// doesn't fit in the queue for
+func (f *forwarder) forward(requestID, contentType string, payload []byte) {
+	// the request buffers are reused once the handler returns
+	payload = append([]byte(nil), payload...)
+	for _, url := range f.urls {
+		select {
+		case f.queue <- forwardJob{url: url, requestID: requestID, contentType: contentType, payload: payload}:
+		default:
+			queueDroppedTotal.WithLabelValues("forward").Inc()
+			log.WithField("request_id", requestID).Errorf("Forward queue full, dropping the payload for %s", url)
+		}
+	}
+}
+
+// deliver posts a payload, retrying the failed posts
+func (f *forwarder) deliver(job forwardJob) {
+	logger := log.WithField("request_id", job.requestID)
+	delay := forwardRetryBase
+	for attempt := 0; ; attempt++ {
+		err := f.post(job.url, job.requestID, job.contentType, job.payload)
+		if err == nil {
+			return
+		}
+		if attempt == forwardMaxRetries {
+			logger.Errorf("Error forwarding the payload to %s, giving up: %v", job.url, err)
+			return
+		}
+		logger.Warnf("Error forwarding the payload to %s, retrying in %s: %v", job.url, delay, err)
+		f.sleep(delay)
+		delay *= 2
+	}
+}
+
+func (f *forwarder) post(url, requestID, contentType string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if requestID != "" {
+		req.Header.Set(requestIDHeader, requestID)
+	}
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Stop waits for the queued payloads to be forwarded, once the handler
+// doesn't forward anymore
+func (f *forwarder) Stop() {
+	close(f.queue)
+	f.wg.Wait()
+}
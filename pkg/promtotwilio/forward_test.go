@@ -0,0 +1,72 @@
+package promtotwilio
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForwarder(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"status": "firing"}` || r.Header.Get("Content-Type") != "application/json" || r.Header.Get(requestIDHeader) != "abc" {
+			t.Errorf("unexpected forward %q with headers %v", body, r.Header)
+		}
+	}))
+	defer server.Close()
+
+	f := newForwarder([]string{server.URL}, 1, 1)
+	f.sleep = func(time.Duration) {}
+	f.start()
+	payload := []byte(`{"status": "firing"}`)
+	f.forward("abc", "application/json", payload)
+	copy(payload, "XXXX")
+	f.Stop()
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("%d forwards, want 2", got)
+	}
+}
+
+func TestForwarderQueueFull(t *testing.T) {
+	f := newForwarder([]string{"http://a", "http://b"}, 1, 1)
+	// not started, the queue isn't drained
+	f.forward("abc", "application/json", []byte(`{}`))
+	if len(f.queue) != 1 {
+		t.Errorf("%d payloads queued, want 1", len(f.queue))
+	}
+}
+
+func TestForwardSkipped(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	f := newForwarder([]string{server.URL}, 1, 10)
+	f.start()
+	m := OptionsWithHandler{
+		Options:     &Config{Receiver: "+15550001", Sender: "+100", Annotations: []string{"summary"}},
+		Client:      &fakeTwilioClient{},
+		Idempotency: newIdempotencyCache(time.Minute),
+		Forwarder:   f,
+	}
+	payload := `{"groupKey": "{}:{alertname=\"DiskFull\"}", "status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`
+	m.HandleFastHTTP(newSendRequestCtx("/send?dry_run=true", payload))
+	m.HandleFastHTTP(newSendRequestCtx("/send", payload))
+	m.HandleFastHTTP(newSendRequestCtx("/send", payload))
+	f.Stop()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("%d forwards of a dry run, a request and its duplicate, want 1", got)
+	}
+}
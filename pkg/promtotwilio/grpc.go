@@ -0,0 +1,195 @@
+package promtotwilio
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/swatto/promtotwilio/pkg/promtotwilio/alertspb"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMaxMessageSize bounds the size of the requests
+const grpcMaxMessageSize = 1 << 20
+
+// grpcServer serves the Alerts service of alertspb/alerts.proto
+type grpcServer struct {
+	alertspb.UnimplementedAlertsServer
+
+	listener net.Listener
+	server   *grpc.Server
+	send     func(ctx context.Context, request *alertspb.SendAlertRequest) (*alertspb.SendAlertResponse, error)
+	done     chan struct{}
+}
+
+// newGRPCServer listens on addr, the RPCs being served with send once
+// started
+func newGRPCServer(addr string, creds credentials.TransportCredentials, send func(ctx context.Context, request *alertspb.SendAlertRequest) (*alertspb.SendAlertResponse, error)) (*grpcServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &grpcServer{
+		listener: listener,
+		server:   grpc.NewServer(grpc.Creds(creds), grpc.MaxRecvMsgSize(grpcMaxMessageSize)),
+		send:     send,
+		done:     make(chan struct{}),
+	}
+	alertspb.RegisterAlertsServer(s.server, s)
+	return s, nil
+}
+
+// SendAlert implements alertspb.AlertsServer
+func (s *grpcServer) SendAlert(ctx context.Context, request *alertspb.SendAlertRequest) (*alertspb.SendAlertResponse, error) {
+	return s.send(ctx, request)
+}
+
+// start serves the RPCs until Stop is called
+func (s *grpcServer) start() {
+	go func() {
+		defer close(s.done)
+		if err := s.server.Serve(s.listener); err != nil {
+			log.Errorf("Error serving gRPC on %s: %v", s.listener.Addr(), err)
+		}
+	}()
+}
+
+// Stop stops accepting RPCs and waits for the in-flight ones to complete
+func (s *grpcServer) Stop() {
+	s.server.GracefulStop()
+	<-s.done
+}
+
+// grpcPayload returns the Alertmanager payload of the alert of a request
+func grpcPayload(r *alertspb.SendAlertRequest, now time.Time) ([]byte, error) {
+	type alert struct {
+		Status      string            `json:"status"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		StartsAt    time.Time         `json:"startsAt"`
+		EndsAt      *time.Time        `json:"endsAt,omitempty"`
+	}
+	a := alert{Status: "firing", Labels: map[string]string{}, Annotations: map[string]string{}, StartsAt: now}
+	if r.GetResolved() {
+		a.Status = "resolved"
+		a.EndsAt = &now
+	}
+	for name, value := range r.GetLabels() {
+		a.Labels[name] = value
+	}
+	for name, value := range r.GetAnnotations() {
+		a.Annotations[name] = value
+	}
+	if r.GetAlertname() != "" {
+		a.Labels["alertname"] = r.GetAlertname()
+	}
+	if r.GetSeverity() != "" {
+		a.Labels["severity"] = r.GetSeverity()
+	}
+	if r.GetSummary() != "" {
+		a.Annotations["summary"] = r.GetSummary()
+	}
+	return json.Marshal(map[string]interface{}{
+		"version":           "4",
+		"status":            a.Status,
+		"alerts":            []alert{a},
+		"commonLabels":      a.Labels,
+		"commonAnnotations": a.Annotations,
+	})
+}
+
+// grpcQuery returns the query string of /send for a request
+func grpcQuery(r *alertspb.SendAlertRequest) string {
+	query := url.Values{}
+	for _, receiver := range r.GetReceivers() {
+		query.Add("receiver", receiver)
+	}
+	if r.GetDryRun() {
+		query.Set("dry_run", "true")
+	}
+	return query.Encode()
+}
+
+// grpcCode returns the gRPC status code of a /send response status code
+func grpcCode(status int) codes.Code {
+	switch {
+	case status < fasthttp.StatusBadRequest:
+		return codes.OK
+	case status == fasthttp.StatusUnauthorized:
+		return codes.Unauthenticated
+	case status == fasthttp.StatusForbidden:
+		return codes.PermissionDenied
+	case status == fasthttp.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case status < fasthttp.StatusInternalServerError:
+		return codes.InvalidArgument
+	}
+	// the alerts which couldn't be sent can be sent again
+	return codes.Unavailable
+}
+
+// sendAlert serves the SendAlert RPC, sending the alert of the request
+// through /send as the webhooks are
+func (m OptionsWithHandler) sendAlert(ctx context.Context, request *alertspb.SendAlertRequest) (*alertspb.SendAlertResponse, error) {
+	payload, err := grpcPayload(request, time.Now())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reqCtx := newPayloadCtx(payload, grpcQuery(request))
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, name := range []string{"Authorization", requestIDHeader} {
+		if values := md.Get(strings.ToLower(name)); len(values) > 0 {
+			reqCtx.Request.Header.Set(name, values[0])
+		}
+	}
+	WithRequestID(RecoverPanics(func(ctx *fasthttp.RequestCtx) {
+		if !m.authorized(ctx) {
+			rejectUnauthorized(ctx)
+			return
+		}
+		m.send(ctx)
+	}))(reqCtx)
+
+	logger := requestLogger(reqCtx).WithField("source", "grpc")
+	code := reqCtx.Response.StatusCode()
+	var response SendResponse
+	if code >= fasthttp.StatusBadRequest || json.Unmarshal(reqCtx.Response.Body(), &response) != nil {
+		var problem Problem
+		json.Unmarshal(reqCtx.Response.Body(), &problem)
+		logger.Errorf("Error processing alert: %d %s", code, reqCtx.Response.Body())
+		if problem.Detail == "" {
+			problem.Detail = fasthttp.StatusMessage(code)
+		}
+		return nil, status.Error(grpcCode(code), problem.Detail)
+	}
+	logger.Infof("Processed alert")
+
+	reply := &alertspb.SendAlertResponse{
+		RequestId:  response.RequestID,
+		Sent:       int32(response.Sent),
+		Failed:     int32(response.Failed),
+		Batched:    int32(response.Batched),
+		Delayed:    int32(response.Delayed),
+		Suppressed: int32(response.Suppressed),
+		Filtered:   int32(response.Filtered),
+	}
+	for _, result := range response.Results {
+		reply.Results = append(reply.Results, &alertspb.Result{
+			Receiver: result.Receiver,
+			Status:   result.Status,
+			Sid:      result.Sid,
+			Error:    result.Error,
+		})
+	}
+	return reply, nil
+}
@@ -0,0 +1,108 @@
+package promtotwilio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/swatto/promtotwilio/pkg/promtotwilio/alertspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newGRPCClient serves the SendAlert RPC of m and returns a client of it,
+// and a function stopping both
+func newGRPCClient(t *testing.T, m OptionsWithHandler) (alertspb.AlertsClient, func()) {
+	s, err := newGRPCServer("127.0.0.1:0", insecure.NewCredentials(), m.sendAlert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.start()
+	conn, err := grpc.NewClient(s.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alertspb.NewAlertsClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestSendAlertRPC(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+	alerts, stop := newGRPCClient(t, m)
+	defer stop()
+
+	response, err := alerts.SendAlert(context.Background(), &alertspb.SendAlertRequest{
+		Alertname: "DiskFull",
+		Summary:   "Disk full on db1",
+		Labels:    map[string]string{"instance": "db1"},
+		Receivers: []string{"+200"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.messages) != 1 || client.messages[0].To != "+200" || !strings.Contains(client.messages[0].Body, "Disk full on db1") {
+		t.Errorf("messages == %+v", client.messages)
+	}
+	if response.GetSent() != 1 || len(response.GetResults()) != 1 || response.GetResults()[0].GetReceiver() != maskNumber("+200") {
+		t.Errorf("response == %v, want 1 sent to %s", response, maskNumber("+200"))
+	}
+}
+
+func TestSendAlertRPCErrors(t *testing.T) {
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", WebhookSecrets: []string{"secret"}},
+		Client:  &fakeTwilioClient{},
+	}
+	alerts, stop := newGRPCClient(t, m)
+	defer stop()
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          codes.Code
+	}{
+		{"unauthenticated", "", codes.Unauthenticated},
+		{"wrong secret", "Bearer other", codes.Unauthenticated},
+		{"authorized", "Bearer secret", codes.OK},
+	}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.authorization != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", test.authorization)
+		}
+		_, err := alerts.SendAlert(ctx, &alertspb.SendAlertRequest{Alertname: "DiskFull"})
+		if code := status.Code(err); code != test.code {
+			t.Errorf("%s: SendAlert() code == %v, want %v: %v", test.name, code, test.code, err)
+		}
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		status int
+		code   codes.Code
+	}{
+		{200, codes.OK},
+		{207, codes.OK},
+		{400, codes.InvalidArgument},
+		{401, codes.Unauthenticated},
+		{403, codes.PermissionDenied},
+		{413, codes.InvalidArgument},
+		{429, codes.ResourceExhausted},
+		{502, codes.Unavailable},
+	}
+	for _, test := range tests {
+		if code := grpcCode(test.status); code != test.code {
+			t.Errorf("grpcCode(%d) == %v, want %v", test.status, code, test.code)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// heartbeatText is the body of the heartbeat messages
+const heartbeatText = "promtotwilio heartbeat OK"
+
+// heartbeat sends a message every interval, proving the whole path to the
+// phones works before it is needed
+type heartbeat struct {
+	interval time.Duration
+	send     func() error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newHeartbeat(interval time.Duration, send func() error) *heartbeat {
+	return &heartbeat{
+		interval: interval,
+		send:     send,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start sends the heartbeats every interval until Stop is called
+func (h *heartbeat) start() {
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := h.send(); err != nil {
+					log.Errorf("Error sending heartbeat: %v", err)
+				}
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sending heartbeats
+func (h *heartbeat) Stop() {
+	close(h.stop)
+	<-h.done
+}
+
+// sendHeartbeat sends a heartbeat to HEARTBEAT_RECEIVER on demand
+func (m OptionsWithHandler) sendHeartbeat(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	result, err := m.sendMessage(m.Client, requestLogger(ctx).WithField("heartbeat", true), requestID(ctx), m.Options.HeartbeatReceiver, heartbeatText)
+	if err != nil {
+		m.audit(ctx, "heartbeat.send", nil, map[string]interface{}{"status": "failed", "error": redact(err.Error())})
+		writeProblem(ctx, fasthttp.StatusBadGateway, "error sending heartbeat: "+err.Error())
+		return
+	}
+	result.Receiver = maskNumber(result.Receiver)
+	m.audit(ctx, "heartbeat.send", nil, map[string]interface{}{"status": result.Status, "receiver": result.Receiver, "sid": result.Sid})
+
+	ctx.SetContentType("application/json")
+	if err := json.NewEncoder(ctx).Encode(result); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package promtotwilio
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var sent int32
+	h := newHeartbeat(10*time.Millisecond, func() error {
+		atomic.AddInt32(&sent, 1)
+		return nil
+	})
+	h.start()
+	time.Sleep(35 * time.Millisecond)
+	h.Stop()
+	if got := atomic.LoadInt32(&sent); got < 2 {
+		t.Errorf("%d heartbeats sent, want at least 2", got)
+	}
+}
+
+func TestSendHeartbeat(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", HeartbeatReceiver: "+15550001"},
+		Client:  client,
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/admin/heartbeat")
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusOK)
+	}
+	if len(client.messages) != 1 || client.messages[0].To != "+15550001" || client.messages[0].Body != heartbeatText {
+		t.Errorf("unexpected messages %+v", client.messages)
+	}
+}
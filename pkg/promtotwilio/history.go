@@ -0,0 +1,135 @@
+package promtotwilio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HistoryEntry is the outcome of a message processed by /send
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	SendResult
+}
+
+// messageHistory keeps the outcome of the last messages
+type messageHistory struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	size    int
+}
+
+func newMessageHistory(size int) *messageHistory {
+	return &messageHistory{size: size}
+}
+
+// add records an outcome, dropping the oldest one when the history is full
+func (h *messageHistory) add(requestID string, result SendResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, HistoryEntry{
+		Time:       time.Now().UTC(),
+		RequestID:  requestID,
+		SendResult: result,
+	})
+	if len(h.entries) > h.size {
+		h.entries = h.entries[1:]
+	}
+}
+
+// list returns the outcomes, most recent first
+func (h *messageHistory) list() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := make([]HistoryEntry, len(h.entries))
+	for i, e := range h.entries {
+		entries[len(entries)-1-i] = e
+	}
+	return entries
+}
+
+// listMessages returns the message history as JSON
+func (m OptionsWithHandler) listMessages(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	ctx.SetContentType("application/json")
+	if err := json.NewEncoder(ctx).Encode(m.History.list()); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
+
+// exportMessages returns the messages between the from and to query
+// parameters, oldest first, as CSV or JSON according to the format one
+func (m OptionsWithHandler) exportMessages(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	args := ctx.QueryArgs()
+	var from, to time.Time
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if value := string(args.Peek(bound.name)); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeProblem(ctx, fasthttp.StatusBadRequest, bound.name+" must be a RFC 3339 time")
+				return
+			}
+			*bound.t = t
+		}
+	}
+
+	entries := m.History.list()
+	exported := make([]HistoryEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if (from.IsZero() || !e.Time.Before(from)) && (to.IsZero() || e.Time.Before(to)) {
+			exported = append(exported, e)
+		}
+	}
+
+	switch format := string(args.Peek("format")); format {
+	case "", "csv":
+		ctx.SetContentType("text/csv")
+		ctx.Response.Header.Set("Content-Disposition", `attachment; filename="messages.csv"`)
+		w := csv.NewWriter(ctx)
+		w.Write([]string{"time", "request_id", "receiver", "alertname", "status", "sid", "segments", "error", "fallback"})
+		for _, e := range exported {
+			w.Write([]string{
+				e.Time.Format(time.RFC3339),
+				e.RequestID,
+				e.Receiver,
+				e.Alertname,
+				e.Status,
+				e.Sid,
+				strconv.Itoa(e.Segments),
+				e.Error,
+				strconv.FormatBool(e.Fallback),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			requestLogger(ctx).Errorf("Error writing response: %v", err)
+		}
+	case "json":
+		ctx.SetContentType("application/json")
+		if err := json.NewEncoder(ctx).Encode(exported); err != nil {
+			requestLogger(ctx).Errorf("Error writing response: %v", err)
+		}
+	default:
+		writeProblem(ctx, fasthttp.StatusBadRequest, "format must be csv or json")
+	}
+}
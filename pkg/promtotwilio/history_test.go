@@ -0,0 +1,45 @@
+package promtotwilio
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMessageHistory(t *testing.T) {
+	h := newMessageHistory(2)
+	h.add("a", SendResult{Receiver: "+200", Status: "queued"})
+	h.add("b", SendResult{Receiver: "+201", Status: "failed"})
+	h.add("c", SendResult{Receiver: "+202", Status: "queued"})
+
+	entries := h.list()
+	if len(entries) != 2 || entries[0].RequestID != "c" || entries[1].RequestID != "b" {
+		t.Errorf("list() == %+v, want requests c and b", entries)
+	}
+}
+
+func TestExportMessages(t *testing.T) {
+	m := OptionsWithHandler{History: newMessageHistory(10)}
+	m.History.add("a", SendResult{Receiver: "+****0001", Alertname: "DiskFull", Status: "queued", Sid: "SM1", Segments: 2})
+	m.History.add("b", SendResult{Receiver: "+****0002", Status: "failed", Error: "twilio error"})
+	m.History.entries[0].Time = time.Date(2019, time.March, 1, 10, 0, 0, 0, time.UTC)
+	m.History.entries[1].Time = time.Date(2019, time.March, 2, 10, 0, 0, 0, time.UTC)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/admin/messages/export?to=2019-03-02T00:00:00Z")
+	m.exportMessages(ctx)
+	expected := "time,request_id,receiver,alertname,status,sid,segments,error,fallback\n" +
+		"2019-03-01T10:00:00Z,a,+****0001,DiskFull,queued,SM1,2,,false\n"
+	if got := string(ctx.Response.Body()); got != expected {
+		t.Errorf("export == %q, want %q", got, expected)
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/admin/messages/export?format=json&from=2019-03-02T00:00:00Z")
+	m.exportMessages(ctx)
+	if got := string(ctx.Response.Body()); !strings.Contains(got, `"request_id":"b"`) || strings.Contains(got, `"request_id":"a"`) {
+		t.Errorf("JSON export == %q, want request b only", got)
+	}
+}
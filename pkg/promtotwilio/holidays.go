@@ -0,0 +1,79 @@
+package promtotwilio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const holidayDateFormat = "2006-01-02"
+
+// Holidays is a set of days, as YYYY-MM-DD, treated like weekends
+type Holidays map[string]bool
+
+// contains reports whether the day of t is a holiday
+func (h Holidays) contains(t time.Time) bool {
+	return h[t.Format(holidayDateFormat)]
+}
+
+// LoadHolidays reads holidays from an iCalendar file, where each event is a
+// holiday, or from a file listing a YYYY-MM-DD date per line
+func LoadHolidays(path string) (Holidays, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := make(Holidays)
+	var start, end time.Time
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "BEGIN:VEVENT"):
+			start, end = time.Time{}, time.Time{}
+		case strings.HasPrefix(line, "DTSTART"):
+			if start, err = parseICalDate(line); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+		case strings.HasPrefix(line, "DTEND"):
+			if end, err = parseICalDate(line); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+		case strings.HasPrefix(line, "END:VEVENT"):
+			if start.IsZero() {
+				return nil, fmt.Errorf("line %d: event without DTSTART", n)
+			}
+			// DTEND is exclusive, and single day events may not have one
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				h[d.Format(holidayDateFormat)] = true
+			}
+		case strings.Contains(line, ":"):
+			// other iCalendar properties
+		default:
+			d, err := time.Parse(holidayDateFormat, line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid date %q", n, line)
+			}
+			h[d.Format(holidayDateFormat)] = true
+		}
+	}
+	return h, scanner.Err()
+}
+
+// parseICalDate returns the day of a DTSTART or DTEND property, such as
+// DTSTART;VALUE=DATE:20190101 or DTSTART:20190101T000000Z
+func parseICalDate(line string) (time.Time, error) {
+	value := line[strings.LastIndex(line, ":")+1:]
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", line)
+	}
+	return time.Parse("20060102", value[:8])
+}
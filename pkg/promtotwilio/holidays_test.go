@@ -0,0 +1,80 @@
+package promtotwilio
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "promtotwilio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestLoadHolidays(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected []string
+	}{
+		{"# public holidays\n2019-01-01\n\n2019-05-01\n", []string{"2019-01-01", "2019-05-01"}},
+		{`BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+SUMMARY:New Year
+DTSTART;VALUE=DATE:20190101
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Christmas
+DTSTART;VALUE=DATE:20191224
+DTEND;VALUE=DATE:20191226
+END:VEVENT
+END:VCALENDAR
+`, []string{"2019-01-01", "2019-12-24", "2019-12-25"}},
+	}
+
+	for _, test := range tests {
+		path := writeTempFile(t, test.content)
+		defer os.Remove(path)
+
+		h, err := LoadHolidays(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h) != len(test.expected) {
+			t.Errorf("LoadHolidays() == %v, want %v", h, test.expected)
+		}
+		for _, day := range test.expected {
+			d, _ := time.Parse(holidayDateFormat, day)
+			if !h.contains(d) {
+				t.Errorf("LoadHolidays() == %v, missing %s", h, day)
+			}
+		}
+	}
+}
+
+func TestRouteHolidays(t *testing.T) {
+	office := &Route{Days: "mon-fri", Hours: "09:00-18:00", Receivers: []string{"+100"}}
+	weekend := &Route{Days: "sat-sun", Receivers: []string{"+200"}}
+	for _, r := range []*Route{office, weekend} {
+		if err := r.init(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := Holidays{"2019-01-01": true}
+	at, _ := time.Parse(time.RFC3339, "2019-01-01T10:00:00Z") // tuesday
+	if r := matchRoute([]*Route{office, weekend}, at, h); r != weekend {
+		t.Errorf("matchRoute() on a holiday == %+v, want the weekend route", r)
+	}
+	if r := matchRoute([]*Route{office, weekend}, at.AddDate(0, 0, 1), h); r != office {
+		t.Errorf("matchRoute() on a working day == %+v, want the office route", r)
+	}
+}
@@ -0,0 +1,73 @@
+package promtotwilio
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ServeHTTP serves the endpoints of the bridge to net/http servers
+func (m OptionsWithHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// bounded as by the fasthttp servers
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, fasthttp.DefaultMaxRequestBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Body larger than "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "Error reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req fasthttp.Request
+	req.Header.SetMethod(r.Method)
+	req.SetRequestURI(r.URL.RequestURI())
+	for key, values := range r.Header {
+		// Set handles the headers fasthttp keeps apart, like Content-Type
+		for i, value := range values {
+			if i == 0 {
+				req.Header.Set(key, value)
+			} else {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+	req.Header.SetHost(r.Host)
+	req.SetBody(body)
+
+	var remoteAddr net.Addr
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		remoteAddr = addr
+	}
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&req, remoteAddr, nil)
+	WithRequestID(RecoverPanics(m.HandleFastHTTP))(&ctx)
+
+	ctx.Response.Header.VisitAll(func(key, value []byte) {
+		if k := string(key); k != "Content-Length" && k != "Server" {
+			w.Header().Add(k, string(value))
+		}
+	})
+	w.WriteHeader(ctx.Response.StatusCode())
+	// streams the event stream as it is written
+	if err := ctx.Response.BodyWriteTo(flushWriter{w}); err != nil {
+		requestLogger(&ctx).Debugf("Error writing response: %v", err)
+	}
+}
+
+// flushWriter flushes every write to the client
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
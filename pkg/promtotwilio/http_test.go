@@ -0,0 +1,48 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestServeHTTP(t *testing.T) {
+	twilio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("To") != "+200" || !strings.Contains(r.FormValue("Body"), "Server down") {
+			t.Errorf("unexpected form %v", r.Form)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM1", "status": "queued", "num_segments": "1"}`))
+	}))
+	defer twilio.Close()
+
+	bridge := New("AC123", "secret", "+100", WithReceivers("+200"), WithTwilioAPIURL(twilio.URL), WithRetry(RetryPolicy{}))
+	defer bridge.Stop()
+
+	body := `{"version":"2","status":"firing","alerts":[{"status":"firing","labels":{"alertname":"Test"},"annotations":{"summary":"Server down"},"startsAt":"2016-03-19T05:54:01Z"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	bridge.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("StatusCode == %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get(requestIDHeader) == "" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+}
+
+func TestServeHTTPBodyTooLarge(t *testing.T) {
+	bridge := New("AC123", "secret", "+100", WithReceivers("+200"))
+	defer bridge.Stop()
+
+	body := strings.Repeat(" ", fasthttp.DefaultMaxRequestBodySize+1)
+	rec := httptest.NewRecorder()
+	bridge.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("StatusCode == %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,176 @@
+package promtotwilio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/valyala/fasthttp"
+)
+
+// idempotencyHeader is the header identifying webhook deliveries, so that
+// retried ones get the response of the first one instead of sending again
+const idempotencyHeader = "Idempotency-Key"
+
+// notificationKey identifies an Alertmanager notification by its group key
+// and its alerts, so that the deliveries Alertmanager retries after webhook
+// timeouts are detected. It returns an empty string for payloads without a
+// group key.
+func notificationKey(payload []byte) string {
+	groupKey, _ := jsonparser.GetString(payload, "groupKey")
+	if groupKey == "" {
+		return ""
+	}
+	alerts, _, _, _ := jsonparser.Get(payload, "alerts")
+	sum := sha256.Sum256(append([]byte(groupKey+"\n"), alerts...))
+	return "notification:" + hex.EncodeToString(sum[:])
+}
+
+// requestKey scopes the key of a request to the hash of its query
+// parameters, sorted, so that the same payload sent to other receivers or
+// groups isn't taken for a retry, without the receivers' numbers showing in
+// the events and the state file
+func requestKey(key string, args *fasthttp.Args) string {
+	var params []string
+	args.VisitAll(func(name, value []byte) {
+		params = append(params, string(name)+"="+string(value))
+	})
+	if len(params) == 0 {
+		return key
+	}
+	sort.Strings(params)
+	sum := sha256.Sum256([]byte(strings.Join(params, "&")))
+	return key + "?" + hex.EncodeToString(sum[:])
+}
+
+// idempotencyCache keeps the responses of the /send requests by key
+type idempotencyCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+// cachedResponse is the response of a request, available once done is closed
+type cachedResponse struct {
+	done    chan struct{}
+	expires time.Time
+
+	status      int
+	contentType []byte
+	body        []byte
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{ttl: ttl, now: time.Now, entries: make(map[string]*cachedResponse)}
+}
+
+// do handles the request with handle unless a request with the same key was
+// handled within the TTL, or is being handled, in which case its response is
+// replayed. It reports whether the response was replayed.
+func (c *idempotencyCache) do(key string, ctx *fasthttp.RequestCtx, handle func(ctx *fasthttp.RequestCtx)) bool {
+	c.mu.Lock()
+	c.prune()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cachedResponse{done: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		<-entry.done
+		ctx.SetStatusCode(entry.status)
+		ctx.Response.Header.SetContentTypeBytes(entry.contentType)
+		ctx.SetBody(entry.body)
+		return true
+	}
+
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		// handle panicked: the requests waiting for this one get an error
+		// and the retries which follow send again
+		c.mu.Lock()
+		entry.status = fasthttp.StatusInternalServerError
+		delete(c.entries, key)
+		c.mu.Unlock()
+		close(entry.done)
+	}()
+	handle(ctx)
+	completed = true
+	c.mu.Lock()
+	entry.status = ctx.Response.StatusCode()
+	entry.contentType = append([]byte(nil), ctx.Response.Header.ContentType()...)
+	entry.body = append([]byte(nil), ctx.Response.Body()...)
+	entry.expires = c.now().Add(c.ttl)
+	if !cacheable(entry.status) {
+		// the requests waiting for this one get its response, but the
+		// retries which follow send again
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+	return false
+}
+
+// cacheable reports whether a response is replayed to the retries of its
+// request: only when every message was sent, so that the retries of the
+// requests which failed, even partly, send again
+func cacheable(status int) bool {
+	return status >= fasthttp.StatusOK && status < fasthttp.StatusMultipleChoices && status != fasthttp.StatusMultiStatus
+}
+
+// prune forgets the expired responses, c.mu must be held
+func (c *idempotencyCache) prune() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// snapshot returns the responses which didn't expire
+func (c *idempotencyCache) snapshot() map[string]persistedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+	responses := make(map[string]persistedResponse, len(c.entries))
+	for key, entry := range c.entries {
+		if !entry.expires.IsZero() {
+			responses[key] = persistedResponse{
+				Status:      entry.status,
+				ContentType: string(entry.contentType),
+				Body:        string(entry.body),
+				Expires:     entry.expires,
+			}
+		}
+	}
+	return responses
+}
+
+// restore adds saved responses to the cache
+func (c *idempotencyCache) restore(responses map[string]persistedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, response := range responses {
+		entry := &cachedResponse{
+			done:        make(chan struct{}),
+			expires:     response.Expires,
+			status:      response.Status,
+			contentType: []byte(response.ContentType),
+			body:        []byte(response.Body),
+		}
+		close(entry.done)
+		c.entries[key] = entry
+	}
+	c.prune()
+}
@@ -0,0 +1,184 @@
+package promtotwilio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Now()
+	c := newIdempotencyCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	handle := func(ctx *fasthttp.RequestCtx) {
+		calls++
+		ctx.SetStatusCode(fasthttp.StatusAccepted)
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"sent":1}`)
+	}
+
+	if c.do("a", &fasthttp.RequestCtx{}, handle) {
+		t.Errorf("first request replayed")
+	}
+	ctx := &fasthttp.RequestCtx{}
+	if !c.do("a", ctx, handle) {
+		t.Errorf("retried request not replayed")
+	}
+	if calls != 1 || ctx.Response.StatusCode() != fasthttp.StatusAccepted || string(ctx.Response.Body()) != `{"sent":1}` {
+		t.Errorf("unexpected replay: %d calls, status %d, body %q", calls, ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	now = now.Add(2 * time.Minute)
+	if c.do("a", &fasthttp.RequestCtx{}, handle) || calls != 2 {
+		t.Errorf("request replayed after the TTL")
+	}
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	func() {
+		defer func() { recover() }()
+		c.do("a", &fasthttp.RequestCtx{}, func(ctx *fasthttp.RequestCtx) { panic("boom") })
+	}()
+
+	// the retry sends again instead of waiting forever
+	done := make(chan bool)
+	go func() {
+		done <- c.do("a", &fasthttp.RequestCtx{}, func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(fasthttp.StatusOK) })
+	}()
+	select {
+	case replayed := <-done:
+		if replayed {
+			t.Errorf("retry of a request which panicked replayed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("retry of a request which panicked blocked")
+	}
+}
+
+func TestNotificationKey(t *testing.T) {
+	first := notificationKey([]byte(`{"groupKey": "{}:{alertname=\"DiskFull\"}", "alerts": [{"fingerprint": "a1", "status": "firing"}]}`))
+	if first == "" {
+		t.Fatal("notificationKey() is empty")
+	}
+	if retry := notificationKey([]byte(`{"groupKey": "{}:{alertname=\"DiskFull\"}", "alerts": [{"fingerprint": "a1", "status": "firing"}]}`)); retry != first {
+		t.Errorf("notificationKey() of a retry == %q, want %q", retry, first)
+	}
+	if next := notificationKey([]byte(`{"groupKey": "{}:{alertname=\"DiskFull\"}", "alerts": [{"fingerprint": "a1", "status": "resolved"}]}`)); next == first {
+		t.Errorf("notificationKey() of the next notification == %q", next)
+	}
+	if key := notificationKey([]byte(`{"alerts": []}`)); key != "" {
+		t.Errorf("notificationKey() without group key == %q, want \"\"", key)
+	}
+}
+
+func TestIdempotencyRetryAfterFailure(t *testing.T) {
+	client := &fakeTwilioClient{err: errors.New("twilio down")}
+	m := OptionsWithHandler{
+		Options:     &Config{Receiver: "+15550001", Sender: "+100", Annotations: []string{"summary"}},
+		Client:      client,
+		Idempotency: newIdempotencyCache(time.Minute),
+	}
+	payload := `{"groupKey": "{}:{alertname=\"DiskFull\"}", "status": "firing", "alerts": [{"fingerprint": "a1", "annotations": {"summary": "Disk full"}}]}`
+
+	ctx := newSendRequestCtx("/send", payload)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Fatalf("first delivery answered %d, want 500", ctx.Response.StatusCode())
+	}
+
+	client.err = nil
+	ctx = newSendRequestCtx("/send", payload)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || len(client.messages) != 1 {
+		t.Errorf("retry answered %d %s with %d messages sent, want it sent", ctx.Response.StatusCode(), ctx.Response.Body(), len(client.messages))
+	}
+
+	// once sent, the retries are replayed
+	ctx = newSendRequestCtx("/send", payload)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || len(client.messages) != 1 {
+		t.Errorf("second retry answered %d with %d messages sent, want a replay", ctx.Response.StatusCode(), len(client.messages))
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected bool
+	}{
+		{fasthttp.StatusOK, true},
+		{fasthttp.StatusAccepted, true},
+		{fasthttp.StatusMultiStatus, false},
+		{fasthttp.StatusBadRequest, false},
+		{fasthttp.StatusInternalServerError, false},
+		{fasthttp.StatusServiceUnavailable, false},
+	}
+	for _, test := range tests {
+		if cacheable(test.status) != test.expected {
+			t.Errorf("cacheable(%d) == %v, want %v", test.status, !test.expected, test.expected)
+		}
+	}
+}
+
+func TestIdempotencyQuery(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:     &Config{Receiver: "+15550001", Sender: "+100", Annotations: []string{"summary"}},
+		Client:      client,
+		Idempotency: newIdempotencyCache(time.Minute),
+	}
+	payload := `{"groupKey": "{}:{alertname=\"DiskFull\"}", "status": "firing", "alerts": [{"fingerprint": "a1", "annotations": {"summary": "Disk full"}}]}`
+
+	// the self-test dry run, through SendPayload, isn't replayed to the
+	// real notification
+	if code, body := m.SendPayload([]byte(payload), "dry_run=true&receiver=%2B15550009"); code != fasthttp.StatusOK || !strings.Contains(string(body), `"dry_run":true`) {
+		t.Fatalf("dry run answered %d %s", code, body)
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send?dry_run=true", payload))
+	ctx := newSendRequestCtx("/send", payload)
+	m.HandleFastHTTP(ctx)
+	if len(client.messages) != 1 || strings.Contains(string(ctx.Response.Body()), "dry_run") {
+		t.Fatalf("real send after dry run answered %s with %d messages", ctx.Response.Body(), len(client.messages))
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B15550002", payload))
+	m.HandleFastHTTP(newSendRequestCtx("/send?group=ops&receiver=%2B15550002", payload))
+	if len(client.messages) != 2 || client.messages[1].To != "+15550002" {
+		t.Errorf("%d messages sent to other receivers, want 1", len(client.messages)-1)
+	}
+
+	// the order of the parameters doesn't matter
+	before := len(client.messages)
+	m.Options.Groups = map[string][]string{"ops": {"+15550003"}}
+	m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B15550004&group=ops", payload))
+	m.HandleFastHTTP(newSendRequestCtx("/send?group=ops&receiver=%2B15550004", payload))
+	if sent := len(client.messages) - before; sent != 2 {
+		t.Errorf("%d messages sent for a request and its reordered retry, want 2", sent)
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	key := func(query string) string {
+		args := &fasthttp.Args{}
+		args.Parse(query)
+		return requestKey("k", args)
+	}
+	if k := key(""); k != "k" {
+		t.Errorf("requestKey() without parameters == %q, want k", k)
+	}
+	if k := key("receiver=%2B15550001"); !strings.HasPrefix(k, "k?") || strings.Contains(k, "15550001") {
+		t.Errorf("requestKey() == %q, want the hash of the parameters", k)
+	}
+	if key("receiver=b&group=a&receiver=a") != key("group=a&receiver=a&receiver=b") {
+		t.Errorf("requestKey() depends on the order of the parameters")
+	}
+	if key("receiver=a") == key("receiver=b") {
+		t.Errorf("requestKey() is the same for other receivers")
+	}
+}
@@ -0,0 +1,351 @@
+package promtotwilio
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// jwksRefreshInterval is how often the JWKS is fetched again, to pick up
+	// rotated keys
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval bounds how often tokens signed with an unknown
+	// key make the JWKS be fetched again
+	jwksMinRefreshInterval = time.Minute
+	// jwtLeeway is the clock skew tolerated on the expiry and not before
+	// times of the tokens
+	jwtLeeway = time.Minute
+)
+
+// jwtVerifier validates the JWTs of the webhooks against the keys of a JWKS
+// URL, their issuer and their audience
+type jwtVerifier struct {
+	jwksURL  string
+	issuer   string
+	audience string
+	client   *http.Client
+	now      func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	// fetchErr is the error of the last fetch, and refreshing is closed once
+	// the fetch in progress, if any, is done
+	fetchErr   error
+	refreshing chan struct{}
+}
+
+func newJWTVerifier(jwksURL, issuer, audience string) *jwtVerifier {
+	return &jwtVerifier{
+		jwksURL:  jwksURL,
+		issuer:   issuer,
+		audience: audience,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// jwtHeader is the header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the registered claims of a JWT checked by the verifier
+type jwtClaims struct {
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+}
+
+// jwtAudience is the aud claim, a string or an array of strings
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// looksLikeJWT reports whether a bearer token is a JWT rather than a secret
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// verify checks the signature, issuer, audience and validity period of a
+// token
+func (v *jwtVerifier) verify(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return fmt.Errorf("malformed header: %v", err)
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed signature: %v", err)
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, hash, h.Sum(nil), signature); err != nil {
+		return err
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return fmt.Errorf("malformed claims: %v", err)
+	}
+	now := v.now()
+	switch {
+	case claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtLeeway)):
+		return errors.New("token expired")
+	case claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return errors.New("token not valid yet")
+	case v.issuer != "" && claims.Issuer != v.issuer:
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case v.audience != "" && !contains(claims.Audience, v.audience):
+		return fmt.Errorf("unexpected audience %q", []string(claims.Audience))
+	}
+	return nil
+}
+
+// jwtHashes are the hashes of the supported signature algorithms
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifySignature checks the signature of a digest with the key, which must
+// be of the type of the algorithm
+func verifySignature(alg string, key crypto.PublicKey, hash crypto.Hash, digest, signature []byte) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			break
+		}
+		if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			break
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key not usable with algorithm %q", alg)
+}
+
+// key returns the key with the given ID, fetching the JWKS again when it is
+// stale or doesn't have the key. The JWKS is fetched without holding the
+// lock and at most once at a time: a stale known key is served while the
+// JWKS is refreshed in the background, and only the tokens signed with an
+// unknown key wait for it.
+func (v *jwtVerifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	now := v.now()
+	due := (!ok || now.Sub(v.fetched) > jwksRefreshInterval) && now.Sub(v.fetched) > jwksMinRefreshInterval
+	if !due {
+		v.mu.Unlock()
+		return v.found(kid, key, ok)
+	}
+	refreshing := v.refreshing
+	if refreshing == nil {
+		refreshing = make(chan struct{})
+		v.refreshing = refreshing
+		if ok {
+			v.mu.Unlock()
+			go v.refresh(refreshing)
+			return key, nil
+		}
+		v.mu.Unlock()
+		v.refresh(refreshing)
+	} else {
+		v.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+		<-refreshing
+	}
+
+	v.mu.Lock()
+	key, ok = v.keys[kid]
+	keys, err := v.keys, v.fetchErr
+	v.mu.Unlock()
+	if keys == nil && err != nil {
+		return nil, fmt.Errorf("error fetching the JWKS: %v", err)
+	}
+	return v.found(kid, key, ok)
+}
+
+// refresh fetches the JWKS, keeping the previous keys when it's
+// unavailable, and closes done
+func (v *jwtVerifier) refresh(done chan struct{}) {
+	keys, err := v.fetch()
+	v.mu.Lock()
+	if err == nil {
+		v.keys = keys
+	} else {
+		log.Warnf("Error fetching the JWKS: %v", err)
+	}
+	v.fetchErr = err
+	v.fetched = v.now()
+	v.refreshing = nil
+	v.mu.Unlock()
+	close(done)
+}
+
+func (v *jwtVerifier) found(kid string, key crypto.PublicKey, ok bool) (crypto.PublicKey, error) {
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// jsonWebKey is a key of a JWKS
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// N and E are the modulus and exponent of RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// Crv, X and Y are the curve and coordinates of EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch gets the signing keys of the JWKS by ID, skipping the ones of an
+// unsupported type or curve. It fails when no key is usable.
+func (v *jwtVerifier) fetch() (map[string]crypto.PublicKey, error) {
+	res, err := v.client.Get(v.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Debugf("Skipping key %q of the JWKS: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing key")
+	}
+	return keys, nil
+}
+
+// publicKey decodes an RSA or EC key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("malformed key")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("malformed key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,255 @@
+package promtotwilio
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// signJWT returns a token of the claims signed with the key
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+
+	h := jwtHashes[alg].New()
+	h.Write([]byte(signed))
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, jwtHashes[alg], h.Sum(nil)); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(padBytes(r, size), padBytes(s, size)...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func padBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// serveJWKS serves the public keys of the signers by ID, counting the
+// requests
+func serveJWKS(t *testing.T, signers map[string]crypto.Signer, fetches *int) *httptest.Server {
+	var keys []jsonWebKey
+	for kid, signer := range signers {
+		switch key := signer.Public().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig",
+				N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())})
+		case *ecdsa.PublicKey:
+			keys = append(keys, jsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256",
+				X: base64.RawURLEncoding.EncodeToString(padBytes(key.X, 32)),
+				Y: base64.RawURLEncoding.EncodeToString(padBytes(key.Y, 32))})
+		}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	jwks := serveJWKS(t, map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey}, &fetches)
+	defer jwks.Close()
+
+	now := time.Unix(1600000000, 0)
+	v := newJWTVerifier(jwks.URL, "https://issuer", "promtotwilio")
+	v.now = func() time.Time { return now }
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": "https://issuer", "aud": "promtotwilio", "exp": now.Add(time.Minute).Unix()}
+		for k, value := range overrides {
+			c[k] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", signJWT(t, "RS256", "rsa", rsaKey, claims(nil)), true},
+		{"ES256", signJWT(t, "ES256", "ec", ecKey, claims(nil)), true},
+		{"audience list", signJWT(t, "ES256", "ec", ecKey, claims(map[string]interface{}{"aud": []string{"other", "promtotwilio"}})), true},
+		{"wrong key", signJWT(t, "ES256", "ec", otherKey, claims(nil)), false},
+		{"unknown key", signJWT(t, "ES256", "other", otherKey, claims(nil)), false},
+		{"algorithm mismatch", signJWT(t, "ES256", "rsa", ecKey, claims(nil)), false},
+		{"expired", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), false},
+		{"not yet valid", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()})), false},
+		{"wrong issuer", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"iss": "https://evil"})), false},
+		{"wrong audience", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"aud": "other"})), false},
+		{"unsigned", "eyJhbGciOiJub25lIn0.eyJpc3MiOiJodHRwczovL2lzc3VlciJ9.", false},
+	}
+	for _, tt := range tests {
+		if err := v.verify(tt.token); (err == nil) != tt.valid {
+			t.Errorf("verify(%s) == %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", fetches)
+	}
+
+	now = now.Add(2 * jwksMinRefreshInterval)
+	v.verify(signJWT(t, "ES256", "other", otherKey, claims(nil)))
+	if fetches != 2 {
+		t.Errorf("JWKS fetched %d times for an unknown key, want 2", fetches)
+	}
+}
+
+func TestAuthorizedJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	jwks := serveJWKS(t, map[string]crypto.Signer{"ec": key}, &fetches)
+	defer jwks.Close()
+
+	m := OptionsWithHandler{
+		Options: &Config{WebhookSecrets: []string{"secret"}},
+		JWT:     newJWTVerifier(jwks.URL, "https://issuer", "promtotwilio"),
+	}
+	token := signJWT(t, "ES256", "ec", key, map[string]interface{}{
+		"iss": "https://issuer", "aud": "promtotwilio", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+
+	for _, tt := range []struct {
+		authorization string
+		authorized    bool
+	}{
+		{"Bearer " + token, true},
+		{"Bearer secret", true},
+		{"Bearer " + token + "x", false},
+		{"", false},
+	} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.Set("Authorization", tt.authorization)
+		if got := m.authorized(ctx); got != tt.authorized {
+			t.Errorf("authorized(%q) == %v, want %v", tt.authorization, got, tt.authorized)
+		}
+	}
+}
+
+func TestJWKSUnsupportedKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := jsonWebKey{Kty: "EC", Kid: "ec", Crv: "P-256",
+		X: base64.RawURLEncoding.EncodeToString(padBytes(key.X, 32)),
+		Y: base64.RawURLEncoding.EncodeToString(padBytes(key.Y, 32))}
+	unsupported := []jsonWebKey{
+		{Kty: "OKP", Kid: "ed", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		{Kty: "oct", Kid: "hmac"},
+		{Kty: "EC", Kid: "p192", Crv: "P-192", X: "AA", Y: "AA"},
+	}
+	served := append(unsupported, ec)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": served})
+	}))
+	defer jwks.Close()
+
+	now := time.Unix(1600000000, 0)
+	v := newJWTVerifier(jwks.URL, "", "")
+	v.now = func() time.Time { return now }
+	token := signJWT(t, "ES256", "ec", key, map[string]interface{}{"exp": now.Add(time.Minute).Unix()})
+	if err := v.verify(token); err != nil {
+		t.Errorf("verify() == %v with unsupported keys in the JWKS", err)
+	}
+
+	served = unsupported
+	if _, err := newJWTVerifier(jwks.URL, "", "").fetch(); err == nil {
+		t.Errorf("fetch() of a JWKS without usable key succeeded")
+	}
+}
+
+func TestJWKSRefreshInBackground(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	jwks := serveJWKS(t, map[string]crypto.Signer{"ec": key}, &fetches)
+	defer jwks.Close()
+
+	var mu sync.Mutex
+	now := time.Unix(1600000000, 0)
+	v := newJWTVerifier(jwks.URL, "", "")
+	v.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	token := func() string {
+		return signJWT(t, "ES256", "ec", key, map[string]interface{}{"exp": v.now().Add(time.Minute).Unix()})
+	}
+	if err := v.verify(token()); err != nil {
+		t.Fatal(err)
+	}
+
+	// once stale, the known key is served while a hanging JWKS is fetched
+	release := make(chan struct{})
+	jwks.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mu.Lock()
+	now = now.Add(2 * jwksRefreshInterval)
+	mu.Unlock()
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- v.verify(token()) }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("verify() during the refresh == %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("verify() waited for the JWKS refresh")
+		}
+	}
+	close(release)
+}
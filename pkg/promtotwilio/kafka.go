@@ -0,0 +1,141 @@
+package promtotwilio
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	kafkaClientID    = "promtotwilio"
+	kafkaDialTimeout = 10 * time.Second
+	// kafkaMaxWait is how long the brokers wait for new records
+	kafkaMaxWait = 500 * time.Millisecond
+	// kafkaMaxBackoff bounds the delay between the attempts to send a payload
+	kafkaMaxBackoff = 30 * time.Second
+)
+
+// kafkaReader is the part of kafka.Reader the consumer uses
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// kafkaConsumer receives the payloads of the records of a Kafka topic as a
+// member of a consumer group, committing their offsets once sent, so that
+// the partitions are shared between the instances and the payloads which
+// couldn't be sent are received again
+type kafkaConsumer struct {
+	topic  string
+	reader kafkaReader
+	handle func(payload []byte) int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newKafkaConsumer(brokers []string, topic, group string, useTLS bool, username, password string, handle func(payload []byte) int) *kafkaConsumer {
+	dialer := &kafka.Dialer{ClientID: kafkaClientID, Timeout: kafkaDialTimeout, DualStack: true}
+	if useTLS {
+		dialer.TLS = &tls.Config{}
+	}
+	if username != "" {
+		dialer.SASLMechanism = plain.Mechanism{Username: username, Password: password}
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		GroupID: group,
+		Topic:   topic,
+		Dialer:  dialer,
+		MaxWait: kafkaMaxWait,
+		// a group consuming the topic for the first time starts with the
+		// payloads published next
+		StartOffset: kafka.LastOffset,
+		ErrorLogger: kafka.LoggerFunc(log.Errorf),
+	})
+	return newKafkaReaderConsumer(topic, reader, handle)
+}
+
+func newKafkaReaderConsumer(topic string, reader kafkaReader, handle func(payload []byte) int) *kafkaConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &kafkaConsumer{
+		topic:  topic,
+		reader: reader,
+		handle: handle,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+// start consumes the topic until Stop is called
+func (c *kafkaConsumer) start() {
+	go func() {
+		defer close(c.done)
+		defer c.reader.Close()
+		for {
+			message, err := c.reader.FetchMessage(c.ctx)
+			if c.ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Errorf("Error consuming Kafka topic %s: %v", c.topic, err)
+				if !c.wait(time.Second) {
+					return
+				}
+				continue
+			}
+			if !c.send(message) {
+				return
+			}
+			// committed even when stopping, the payload being sent
+			if err := c.reader.CommitMessages(context.Background(), message); err != nil {
+				log.Errorf("Error committing offset %d of partition %d of Kafka topic %s: %v", message.Offset, message.Partition, c.topic, err)
+			}
+		}
+	}()
+}
+
+// send handles the payload of a message until it's sent or couldn't be for
+// a reason other than a server error, backing off between the attempts. It
+// returns false when the consumer stopped before.
+func (c *kafkaConsumer) send(message kafka.Message) bool {
+	backoff := time.Second
+	for {
+		code := c.handle(message.Value)
+		if code < http.StatusInternalServerError {
+			return true
+		}
+		log.Errorf("Record %d of partition %d of Kafka topic %s not sent, sending again in %s", message.Offset, message.Partition, c.topic, backoff)
+		if !c.wait(backoff) {
+			return false
+		}
+		if backoff *= 2; backoff > kafkaMaxBackoff {
+			backoff = kafkaMaxBackoff
+		}
+	}
+}
+
+// wait waits for d, returning false when the consumer stopped before
+func (c *kafkaConsumer) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// Stop leaves the group once the record being sent is, for the other
+// members to take over the partitions
+func (c *kafkaConsumer) Stop() {
+	c.cancel()
+	<-c.done
+}
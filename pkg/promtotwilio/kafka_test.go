@@ -0,0 +1,115 @@
+package promtotwilio
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafkaReader delivers the messages published to it, as a Reader of a
+// consumer group does
+type fakeKafkaReader struct {
+	messages chan kafka.Message
+
+	mu        sync.Mutex
+	committed []int64
+	closed    bool
+}
+
+func (r *fakeKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case message := <-r.messages:
+		return message, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeKafkaReader) CommitMessages(ctx context.Context, messages ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, message := range messages {
+		r.committed = append(r.committed, message.Offset)
+	}
+	return nil
+}
+
+func (r *fakeKafkaReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func TestKafkaConsumer(t *testing.T) {
+	reader := &fakeKafkaReader{messages: make(chan kafka.Message, 3)}
+	reader.messages <- kafka.Message{Offset: 1, Value: []byte("a")}
+	reader.messages <- kafka.Message{Offset: 2, Value: []byte("fail")}
+	reader.messages <- kafka.Message{Offset: 3, Value: []byte("invalid")}
+
+	var mu sync.Mutex
+	var handled []string
+	failed := false
+	c := newKafkaReaderConsumer("alerts", reader, func(payload []byte) int {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, string(payload))
+		switch {
+		case string(payload) == "fail" && !failed:
+			failed = true
+			return http.StatusInternalServerError
+		case string(payload) == "invalid":
+			// committed, sending it again would fail again
+			return http.StatusBadRequest
+		}
+		return http.StatusOK
+	})
+	c.start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reader.mu.Lock()
+		n := len(reader.committed)
+		reader.mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Stop()
+
+	mu.Lock()
+	if expected := []string{"a", "fail", "fail", "invalid"}; len(handled) != len(expected) {
+		t.Errorf("handled %v, want %v", handled, expected)
+	}
+	mu.Unlock()
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	if len(reader.committed) != 3 || reader.committed[0] != 1 || reader.committed[2] != 3 || !reader.closed {
+		t.Errorf("committed offsets %v, closed %v", reader.committed, reader.closed)
+	}
+}
+
+func TestKafkaConsumerStop(t *testing.T) {
+	reader := &fakeKafkaReader{messages: make(chan kafka.Message, 1)}
+	reader.messages <- kafka.Message{Offset: 1, Value: []byte("fail")}
+	handled := make(chan struct{}, 1)
+	c := newKafkaReaderConsumer("alerts", reader, func(payload []byte) int {
+		handled <- struct{}{}
+		return http.StatusServiceUnavailable
+	})
+	c.start()
+	<-handled
+	// stops while backing off, without committing the record not sent
+	c.Stop()
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	if len(reader.committed) != 0 || !reader.closed {
+		t.Errorf("committed offsets %v, closed %v", reader.committed, reader.closed)
+	}
+}
@@ -0,0 +1,129 @@
+package promtotwilio
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// leaderRenewScript extends the lease when this instance holds it
+	leaderRenewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	// leaderReleaseScript deletes the lease when this instance holds it
+	leaderReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+	// leaderMaxTimeout bounds the time a command on the lease may take
+	leaderMaxTimeout = 5 * time.Second
+)
+
+// leaderElection elects, through a lease in Redis set with SET NX PX, the
+// only one of an active/standby pair sending messages
+type leaderElection struct {
+	redis    *redisClient
+	key      string
+	id       string
+	duration time.Duration
+	now      func() time.Time
+
+	leader int32
+	// expires is the UnixNano time the lease held by this instance expires
+	// at, after which it stops sending even when Redis can't be reached
+	expires int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newLeaderElection(redisURL, key, id string, duration time.Duration) (*leaderElection, error) {
+	timeout := duration / 3
+	if timeout > leaderMaxTimeout {
+		timeout = leaderMaxTimeout
+	}
+	client, err := newRedisClient(redisURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &leaderElection{
+		redis:    client,
+		key:      key,
+		id:       id,
+		duration: duration,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// start renews or takes over the lease periodically until Stop is called
+func (l *leaderElection) start() {
+	l.renew()
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(l.duration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.renew()
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops renewing the lease, releasing it for the standby to take over
+func (l *leaderElection) Stop() {
+	close(l.stop)
+	<-l.done
+	if atomic.SwapInt32(&l.leader, 0) == 1 {
+		if _, err := l.redis.do("EVAL", leaderReleaseScript, "1", l.key, l.id); err != nil {
+			log.Errorf("Error releasing leader lease: %v", err)
+		}
+	}
+}
+
+// leading reports whether this instance holds a lease not expired yet
+func (l *leaderElection) leading() bool {
+	return atomic.LoadInt32(&l.leader) == 1 && l.now().UnixNano() < atomic.LoadInt64(&l.expires)
+}
+
+// renew extends the lease held by this instance, or takes it over once the
+// one of the leader expired
+func (l *leaderElection) renew() {
+	// the lease expires at the latest a duration after the command is sent
+	expires := l.now().Add(l.duration)
+	leading, err := l.acquire()
+	if err != nil {
+		log.Errorf("Error renewing leader lease: %v", err)
+	}
+	var value int32
+	if leading {
+		value = 1
+		atomic.StoreInt64(&l.expires, expires.UnixNano())
+	}
+	if old := atomic.SwapInt32(&l.leader, value); old != value {
+		if leading {
+			log.Infof("Became the leader, sending messages")
+		} else {
+			log.Infof("Became a standby, not sending messages")
+		}
+	}
+}
+
+func (l *leaderElection) acquire() (bool, error) {
+	ttl := strconv.FormatInt(int64(l.duration/time.Millisecond), 10)
+	renewed, err := l.redis.do("EVAL", leaderRenewScript, "1", l.key, l.id, ttl)
+	if err != nil {
+		return false, err
+	}
+	if renewed == int64(1) {
+		return true, nil
+	}
+	acquired, err := l.redis.do("SET", l.key, l.id, "NX", "PX", ttl)
+	if err != nil {
+		return false, err
+	}
+	return acquired == "OK", nil
+}
@@ -0,0 +1,140 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// saturatedRetryAfter is the Retry-After, in seconds, of the webhooks
+// rejected because too many are being served
+const saturatedRetryAfter = "1"
+
+// sendLimiter caps the number of webhooks served, or of requests to the
+// Twilio API, at once
+type sendLimiter struct {
+	slots chan struct{}
+}
+
+func newSendLimiter(max int) *sendLimiter {
+	return &sendLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire takes a slot, false when they are all taken
+func (l *sendLimiter) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait takes a slot, waiting for one to be released when they are all taken
+func (l *sendLimiter) wait() {
+	select {
+	case l.slots <- struct{}{}:
+		return
+	default:
+	}
+	atomic.AddInt64(&twilioRequestsWaiting, 1)
+	l.slots <- struct{}{}
+	atomic.AddInt64(&twilioRequestsWaiting, -1)
+}
+
+func (l *sendLimiter) release() {
+	<-l.slots
+}
+
+// limitedTwilioClient is a TwilioClient making at most TWILIO_MAX_CONCURRENT
+// requests at once, the messages beyond waiting for their turn
+type limitedTwilioClient struct {
+	client  TwilioClient
+	limiter *sendLimiter
+}
+
+func (c limitedTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	c.limiter.wait()
+	defer c.limiter.release()
+	return c.client.SendMessage(m)
+}
+
+// rejectSaturated answers 503 to a webhook beyond MAX_CONCURRENT_SENDS
+func rejectSaturated(ctx *fasthttp.RequestCtx) {
+	requestsRejectedTotal.WithLabelValues("saturated").Inc()
+	ctx.Response.Header.Set("Retry-After", saturatedRetryAfter)
+	writeProblem(ctx, fasthttp.StatusServiceUnavailable, "too many webhooks being served, retry later")
+}
+
+// errQueueFull is the error of the messages dropped because the batch and
+// delay queues hold QUEUE_MAX_DEPTH messages
+var errQueueFull = errors.New("queue full")
+
+// queueDepth returns the number of messages waiting in the batch and delay
+// queues
+func (m OptionsWithHandler) queueDepth() int {
+	depth := 0
+	if m.Batcher != nil {
+		depth += m.Batcher.depth()
+	}
+	if m.Delayer != nil {
+		depth += m.Delayer.depth()
+	}
+	return depth
+}
+
+// queueFull reports whether the batch and delay queues hold QUEUE_MAX_DEPTH
+// messages
+func (m OptionsWithHandler) queueFull() bool {
+	return m.Options.QueueMaxDepth > 0 && m.queueDepth() >= m.Options.QueueMaxDepth
+}
+
+// rejectQueueFull answers 503 to a webhook received while the queues are
+// full, with their depth and capacity
+func (m OptionsWithHandler) rejectQueueFull(ctx *fasthttp.RequestCtx) {
+	requestsRejectedTotal.WithLabelValues("queue_full").Inc()
+	ctx.Response.Header.Set("Retry-After", strconv.Itoa(int(m.Options.BatchInterval.Seconds())+1))
+	ctx.Response.Header.Set("X-Queue-Depth", strconv.Itoa(m.queueDepth()))
+	ctx.Response.Header.Set("X-Queue-Capacity", strconv.Itoa(m.Options.QueueMaxDepth))
+	writeProblem(ctx, fasthttp.StatusServiceUnavailable, "queues full, retry later")
+}
+
+// TimeoutRequests wraps a handler to answer 503 to the requests it doesn't
+// serve within timeout. The handler keeps running in the background, its
+// response being discarded.
+func TimeoutRequests(h fasthttp.RequestHandler, timeout time.Duration) fasthttp.RequestHandler {
+	if timeout <= 0 {
+		return h
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		// what the timeout needs of ctx is read upfront since it is shared
+		// with h
+		logger := requestLogger(ctx).WithField("path", string(ctx.Path()))
+		var resp fasthttp.Response
+		resp.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		resp.Header.SetContentType("application/problem+json")
+		resp.Header.Set(requestIDHeader, requestID(ctx))
+		body, _ := json.Marshal(newProblem(ctx, fasthttp.StatusServiceUnavailable, "request timed out"))
+		resp.SetBody(body)
+
+		done := make(chan struct{})
+		go func() {
+			h(ctx)
+			close(done)
+		}()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+			requestsRejectedTotal.WithLabelValues("timeout").Inc()
+			logger.Warnf("Timed out serving the request after %s", timeout)
+			ctx.TimeoutErrorWithResponse(&resp)
+		}
+	}
+}
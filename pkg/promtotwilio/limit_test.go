@@ -0,0 +1,146 @@
+package promtotwilio
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestSendLimiter(t *testing.T) {
+	m := OptionsWithHandler{Options: &Config{}, Limiter: newSendLimiter(1)}
+	m.Limiter.acquire()
+
+	before := testutil.ToFloat64(requestsRejectedTotal.WithLabelValues("saturated"))
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/send")
+	m.HandleFastHTTP(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusServiceUnavailable)
+	}
+	if got := string(ctx.Response.Header.Peek("Retry-After")); got != saturatedRetryAfter {
+		t.Errorf("Retry-After == %q, want %q", got, saturatedRetryAfter)
+	}
+	if got := testutil.ToFloat64(requestsRejectedTotal.WithLabelValues("saturated")); got != before+1 {
+		t.Errorf("requestsRejectedTotal == %g, want %g", got, before+1)
+	}
+
+	m.Limiter.release()
+	if !m.Limiter.acquire() {
+		t.Errorf("slot not released")
+	}
+}
+
+// blockingTwilioClient counts the messages being sent, until released
+type blockingTwilioClient struct {
+	sending, max int32
+	release      chan struct{}
+}
+
+func (c *blockingTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	n := atomic.AddInt32(&c.sending, 1)
+	for {
+		max := atomic.LoadInt32(&c.max)
+		if n <= max || atomic.CompareAndSwapInt32(&c.max, max, n) {
+			break
+		}
+	}
+	<-c.release
+	atomic.AddInt32(&c.sending, -1)
+	return &MessageReceipt{Sid: "SM1", Status: "queued"}, nil
+}
+
+func TestLimitedTwilioClient(t *testing.T) {
+	blocking := &blockingTwilioClient{release: make(chan struct{})}
+	client := limitedTwilioClient{blocking, newSendLimiter(2)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.SendMessage(&Message{To: "+200", Body: "Disk full"})
+		}()
+	}
+	for atomic.LoadInt64(&twilioRequestsWaiting) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(blocking.release)
+	wg.Wait()
+
+	if blocking.max != 2 {
+		t.Errorf("%d messages sent at once, want 2", blocking.max)
+	}
+	if waiting := atomic.LoadInt64(&twilioRequestsWaiting); waiting != 0 {
+		t.Errorf("twilioRequestsWaiting == %d, want 0", waiting)
+	}
+}
+
+func TestTimeoutRequests(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	h := TimeoutRequests(func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) == "/slow" {
+			<-release
+		}
+		ctx.SetBodyString("ok")
+	}, 50*time.Millisecond)
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go (&fasthttp.Server{Handler: WithRequestID(h)}).Serve(ln)
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/fast", fasthttp.StatusOK},
+		{"/slow", fasthttp.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		code, _, err := client.Get(nil, "http://promtotwilio"+tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("GET %s == %d, want %d", tt.path, code, tt.code)
+		}
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	m := OptionsWithHandler{
+		Options: &Config{QueueMaxDepth: 2},
+		Batcher: newBatcher(time.Hour, func(receiver, text string) error { return nil }),
+	}
+	m.Batcher.add("+100", "firing", "Disk full")
+
+	tests := []struct {
+		depth string
+		code  int
+	}{
+		{"1", fasthttp.StatusMethodNotAllowed},
+		{"2", fasthttp.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/send")
+		m.HandleFastHTTP(ctx)
+		if ctx.Response.StatusCode() != tt.code {
+			t.Errorf("status with %s queued == %d, want %d", tt.depth, ctx.Response.StatusCode(), tt.code)
+		}
+		if tt.code == fasthttp.StatusServiceUnavailable {
+			if got := string(ctx.Response.Header.Peek("X-Queue-Depth")); got != tt.depth {
+				t.Errorf("X-Queue-Depth == %q, want %q", got, tt.depth)
+			}
+		}
+		m.Batcher.add("+100", "firing", "CPU high")
+	}
+}
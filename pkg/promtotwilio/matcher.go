@@ -0,0 +1,84 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matcher matches a label value like Alertmanager matchers do, e.g.
+// severity="critical", env!="staging" or team=~"db|net"
+type Matcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+var matcherReg = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*"|[^,"]*?)\s*(?:,|$)`)
+
+// ParseMatchers parses a comma separated list of matchers
+func ParseMatchers(s string) ([]*Matcher, error) {
+	var matchers []*Matcher
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		m := matcherReg.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("invalid matcher %q", rest)
+		}
+		rest = strings.TrimSpace(rest[len(m[0]):])
+
+		value := m[3]
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid matcher value %s: %v", value, err)
+			}
+			value = unquoted
+		}
+
+		mt := &Matcher{name: m[1], op: m[2], value: value}
+		if mt.op == "=~" || mt.op == "!~" {
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid matcher regexp %q: %v", value, err)
+			}
+			mt.re = re
+		}
+		matchers = append(matchers, mt)
+	}
+	return matchers, nil
+}
+
+// matches reports whether the label value satisfies the matcher
+func (m *Matcher) matches(value string) bool {
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+func (m *Matcher) String() string {
+	return m.name + m.op + strconv.Quote(m.value)
+}
+
+// matchAll reports whether all the matchers are satisfied by the labels
+// returned by label. No matchers never match.
+func matchAll(matchers []*Matcher, label func(name string) string) bool {
+	if len(matchers) == 0 {
+		return false
+	}
+	for _, m := range matchers {
+		if !m.matches(label(m.name)) {
+			return false
+		}
+	}
+	return true
+}
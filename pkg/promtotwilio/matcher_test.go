@@ -0,0 +1,52 @@
+package promtotwilio
+
+import "testing"
+
+func TestParseMatchers(t *testing.T) {
+	matchers, err := ParseMatchers(`severity="critical", env!=staging,team=~"db|net", instance!~"test-.*"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`severity="critical"`, `env!="staging"`, `team=~"db|net"`, `instance!~"test-.*"`}
+	if len(matchers) != len(expected) {
+		t.Fatalf("ParseMatchers() returned %d matchers, want %d", len(matchers), len(expected))
+	}
+	for i, m := range matchers {
+		if m.String() != expected[i] {
+			t.Errorf("matcher #%d == %s, want %s", i, m, expected[i])
+		}
+	}
+
+	for _, invalid := range []string{`severity`, `severity=="x"`, `team=~"("`, `1abc="x"`} {
+		if _, err := ParseMatchers(invalid); err == nil {
+			t.Errorf("ParseMatchers(%q) didn't fail", invalid)
+		}
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	matchers, _ := ParseMatchers(`severity="critical",team=~"db|net",env!="staging"`)
+
+	tests := []struct {
+		labels   map[string]string
+		expected bool
+	}{
+		{map[string]string{"severity": "critical", "team": "db"}, true},
+		{map[string]string{"severity": "critical", "team": "net", "env": "prod"}, true},
+		{map[string]string{"severity": "critical", "team": "db", "env": "staging"}, false},
+		{map[string]string{"severity": "warning", "team": "db"}, false},
+		{map[string]string{"severity": "critical", "team": "dba"}, false},
+	}
+
+	for _, test := range tests {
+		label := func(name string) string { return test.labels[name] }
+		if output := matchAll(matchers, label); output != test.expected {
+			t.Errorf("matchAll(%v) == %v, want %v", test.labels, output, test.expected)
+		}
+	}
+
+	if matchAll(nil, func(string) string { return "" }) {
+		t.Errorf("matchAll() without matchers matched")
+	}
+}
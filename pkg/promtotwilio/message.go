@@ -0,0 +1,130 @@
+package promtotwilio
+
+import (
+	"strings"
+	"time"
+
+	"github.com/buger/jsonparser"
+	log "github.com/sirupsen/logrus"
+)
+
+// PayloadMeta holds the fields of a webhook payload shared by its alerts
+type PayloadMeta struct {
+	Status   string
+	Receiver string
+	// ExternalURL links back to the Alertmanager which sent the payload
+	ExternalURL string
+	// CommonLabels and CommonAnnotations are the raw JSON objects of the
+	// labels and annotations all the alerts of the payload have in common
+	CommonLabels      []byte
+	CommonAnnotations []byte
+	// TruncatedAlerts is the number of alerts Alertmanager left out of the
+	// payload, over the max_alerts of its webhook configuration
+	TruncatedAlerts int
+}
+
+// parsePayloadMeta extracts the shared fields of a webhook payload
+func parsePayloadMeta(payload []byte) *PayloadMeta {
+	meta := &PayloadMeta{}
+	meta.Status, _ = jsonparser.GetString(payload, "status")
+	meta.Receiver, _ = jsonparser.GetString(payload, "receiver")
+	meta.ExternalURL, _ = jsonparser.GetString(payload, "externalURL")
+	meta.CommonLabels, _, _, _ = jsonparser.Get(payload, "commonLabels")
+	meta.CommonAnnotations, _, _, _ = jsonparser.Get(payload, "commonAnnotations")
+	truncated, _ := jsonparser.GetInt(payload, "truncatedAlerts")
+	meta.TruncatedAlerts = int(truncated)
+	return meta
+}
+
+// status returns the status of the alert, falling back to the one of the
+// payload, as a payload can group firing and resolved alerts
+func (meta *PayloadMeta) status(alert []byte) string {
+	if status, _ := jsonparser.GetString(alert, "status"); status != "" {
+		return status
+	}
+	return meta.Status
+}
+
+// annotation returns an annotation of the alert, falling back to the common
+// annotations of the payload
+func (meta *PayloadMeta) annotation(alert []byte, name string) string {
+	if value, _ := jsonparser.GetString(alert, "annotations", name); value != "" {
+		return value
+	}
+	value, _ := jsonparser.GetString(meta.CommonAnnotations, name)
+	return value
+}
+
+// label returns a label of the alert, falling back to the common labels of
+// the payload
+func (meta *PayloadMeta) label(alert []byte, name string) string {
+	if value, _ := jsonparser.GetString(alert, "labels", name); value != "" {
+		return value
+	}
+	value, _ := jsonparser.GetString(meta.CommonLabels, name)
+	return value
+}
+
+// formatMessage builds the text message for an alert of the payload with
+// its template, or else from the configured annotations. It
+// returns an empty string when none of them is set.
+func formatMessage(o *Config, meta *PayloadMeta, alert []byte) string {
+	if t := messageTemplate(o, meta, alert); t != nil {
+		body, err := executeTemplate(t, meta, alert)
+		if err == nil {
+			return body
+		}
+		log.Warnf("Error executing message template, using the default format: %v", err)
+	}
+
+	parts := make([]string, 0, len(o.Annotations))
+	for _, name := range o.Annotations {
+		if value := meta.annotation(alert, name); value != "" {
+			parts = append(parts, findAndReplaceLables(value, alert))
+		}
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	body := strings.Join(parts, " - ")
+	startsAt, _ := jsonparser.GetString(alert, "startsAt")
+	parsedStartsAt, err := time.Parse(time.RFC3339, startsAt)
+	if err == nil {
+		body = "\"" + body + "\"" + " alert starts at " + parsedStartsAt.Format(time.RFC1123)
+	}
+
+	if prefix := o.SeverityPrefixes[meta.label(alert, "severity")]; prefix != "" {
+		body = prefix + " " + body
+	}
+
+	switch meta.status(alert) {
+	case "firing":
+		body = o.FiringPrefix + body
+	case "resolved":
+		body = o.ResolvedPrefix + body
+	}
+
+	if labels := formatLabels(o.Labels, meta, alert); labels != "" {
+		body += " " + labels
+	}
+
+	if o.IncludeExternalURL && meta.ExternalURL != "" {
+		body += " " + meta.ExternalURL
+	}
+
+	return body
+}
+
+// formatLabels returns the given labels of an alert as space separated
+// key=value pairs, skipping the ones the alert doesn't carry.
+func formatLabels(names []string, meta *PayloadMeta, alert []byte) string {
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		if value := meta.label(alert, name); value != "" {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return strings.Join(pairs, " ")
+}
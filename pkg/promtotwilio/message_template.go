@@ -0,0 +1,142 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	tmpl "github.com/swatto/promtotwilio/internal/template"
+)
+
+// templateData is what message templates are executed with
+type templateData struct {
+	Status       string
+	Receiver     string
+	ExternalURL  string
+	Fingerprint  string
+	GeneratorURL string
+	StartsAt     time.Time
+	EndsAt       time.Time
+	// Labels and Annotations of the alert, completed by the common ones
+	Labels            map[string]string
+	Annotations       map[string]string
+	CommonLabels      map[string]string
+	CommonAnnotations map[string]string
+}
+
+// newTemplateData decodes an alert of the payload for templates
+func newTemplateData(meta *PayloadMeta, alert []byte) (*templateData, error) {
+	data := &templateData{
+		Receiver:          meta.Receiver,
+		ExternalURL:       meta.ExternalURL,
+		CommonLabels:      map[string]string{},
+		CommonAnnotations: map[string]string{},
+	}
+	if err := json.Unmarshal(alert, data); err != nil {
+		return nil, err
+	}
+	if data.Status == "" {
+		data.Status = meta.Status
+	}
+
+	if len(meta.CommonLabels) > 0 {
+		if err := json.Unmarshal(meta.CommonLabels, &data.CommonLabels); err != nil {
+			return nil, err
+		}
+	}
+	if len(meta.CommonAnnotations) > 0 {
+		if err := json.Unmarshal(meta.CommonAnnotations, &data.CommonAnnotations); err != nil {
+			return nil, err
+		}
+	}
+
+	data.Labels = mergeMaps(data.CommonLabels, data.Labels)
+	data.Annotations = mergeMaps(data.CommonAnnotations, data.Annotations)
+	return data, nil
+}
+
+// mergeMaps returns the entries of base overridden by the ones of m
+func mergeMaps(base, m map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(m))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range m {
+		merged[k] = v
+	}
+	return merged
+}
+
+// executeTemplate renders the message of an alert of the payload with t
+func executeTemplate(t *template.Template, meta *PayloadMeta, alert []byte) (string, error) {
+	data, err := newTemplateData(meta, alert)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// messageTemplate returns the template of the messages of an alert: the
+// one named by its sms_template annotation, its template of TEMPLATE_DIR or
+// of ALERT_TEMPLATES_FILE, or else the default one of TEMPLATE_DIR or
+// MESSAGE_TEMPLATE, nil when none is set
+func messageTemplate(o *Config, meta *PayloadMeta, alert []byte) *template.Template {
+	if name := meta.annotation(alert, "sms_template"); name != "" {
+		if t := namedTemplate(o, name); t != nil {
+			return t
+		}
+		log.Warnf("Unknown template %q of the sms_template annotation, using the default one", name)
+	}
+	if t := namedTemplate(o, meta.label(alert, "alertname")); t != nil {
+		return t
+	}
+	if o.TemplateDir != nil {
+		if t := namedTemplate(o, defaultTemplateName); t != nil {
+			return t
+		}
+	}
+	return o.Template
+}
+
+// namedTemplate returns the template with the given name of TEMPLATE_DIR
+// or ALERT_TEMPLATES_FILE, if any
+func namedTemplate(o *Config, name string) *template.Template {
+	if o.TemplateDir != nil {
+		if t, ok := o.TemplateDir.current.Load().(*templateSet).templates[name]; ok {
+			return t
+		}
+	}
+	return o.AlertTemplates[name]
+}
+
+// LoadAlertTemplates parses the message templates of a JSON file mapping
+// alert names to templates
+func LoadAlertTemplates(path string) (map[string]*template.Template, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts map[string]string
+	if err := json.Unmarshal(content, &texts); err != nil {
+		return nil, err
+	}
+	templates := make(map[string]*template.Template, len(texts))
+	for alertname, text := range texts {
+		t, err := tmpl.New(alertname, text)
+		if err != nil {
+			return nil, fmt.Errorf("template of %s: %v", alertname, err)
+		}
+		templates[alertname] = t
+	}
+	return templates, nil
+}
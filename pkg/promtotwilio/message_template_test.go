@@ -0,0 +1,91 @@
+package promtotwilio
+
+import (
+	"testing"
+
+	tmpl "github.com/swatto/promtotwilio/internal/template"
+)
+
+func TestFormatMessageTemplate(t *testing.T) {
+	template, err := tmpl.New("message", `[{{ .Status | upper }}] {{ .Labels.alertname }} on {{ .Labels.instance }}: {{ .Annotations.summary | truncate 20 }} ({{ .ExternalURL }})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := parsePayloadMeta([]byte(`{
+		"status": "firing",
+		"externalURL": "http://alertmanager:9093",
+		"commonLabels": {"alertname": "DiskFull"},
+		"commonAnnotations": {"summary": "Disk is almost full on all databases"}
+	}`))
+	alert := []byte(`{"status": "firing", "labels": {"alertname": "DiskFull", "instance": "db-1"}, "startsAt": "2017-01-06T19:34:52.887Z"}`)
+
+	o := &Config{Annotations: []string{"summary"}, Template: template}
+	expected := "[FIRING] DiskFull on db-1: Disk is almost full… (http://alertmanager:9093)"
+	if output := formatMessage(o, meta, alert); output != expected {
+		t.Errorf("formatMessage() == %q, want %q", output, expected)
+	}
+}
+
+func TestFormatMessageTemplateError(t *testing.T) {
+	template, err := tmpl.New("message", `{{ humanizeDuration .Labels.alertname }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &PayloadMeta{Status: "firing"}
+	alert := []byte(`{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}`)
+
+	o := &Config{Annotations: []string{"summary"}, Template: template}
+	if output := formatMessage(o, meta, alert); output != "Disk full" {
+		t.Errorf("formatMessage() == %q, want the default format on template errors", output)
+	}
+}
+
+func TestFormatMessageAlertTemplates(t *testing.T) {
+	templates, err := LoadAlertTemplates(writeTempFile(t, `{"HostDown": "{{ .Labels.instance }} down"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &PayloadMeta{Status: "firing"}
+	o := &Config{Annotations: []string{"summary"}, AlertTemplates: templates}
+	tests := []struct {
+		alert    string
+		expected string
+	}{
+		{`{"labels": {"alertname": "HostDown", "instance": "db-1"}, "annotations": {"summary": "Host db-1 is down"}}`, "db-1 down"},
+		{`{"labels": {"alertname": "DiskFull", "instance": "db-1"}, "annotations": {"summary": "Disk full"}}`, "Disk full"},
+	}
+	for _, test := range tests {
+		if output := formatMessage(o, meta, []byte(test.alert)); output != test.expected {
+			t.Errorf("formatMessage(%q) == %q, want %q", test.alert, output, test.expected)
+		}
+	}
+
+	if _, err := LoadAlertTemplates(writeTempFile(t, `{"HostDown": "{{ .Labels.instance "}`)); err == nil {
+		t.Errorf("LoadAlertTemplates() of an invalid template succeeded")
+	}
+}
+
+func TestFormatMessageTemplateAnnotation(t *testing.T) {
+	templates, err := LoadAlertTemplates(writeTempFile(t, `{"short": "{{ .Labels.alertname }}!"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &PayloadMeta{Status: "firing"}
+	o := &Config{Annotations: []string{"summary"}, AlertTemplates: templates}
+	tests := []struct {
+		alert    string
+		expected string
+	}{
+		{`{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full", "sms_template": "short"}}`, "DiskFull!"},
+		{`{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full", "sms_template": "unknown"}}`, "Disk full"},
+	}
+	for _, test := range tests {
+		if output := formatMessage(o, meta, []byte(test.alert)); output != test.expected {
+			t.Errorf("formatMessage(%q) == %q, want %q", test.alert, output, test.expected)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package promtotwilio
+
+import "testing"
+
+func TestFormatMessageAnnotations(t *testing.T) {
+	alert := []byte(`{
+		"labels": {"instance": "http://test.com"},
+		"annotations": {
+			"summary": "Address $labels.instance appears to be down",
+			"runbook_url": "http://runbooks/down"
+		},
+		"startsAt": "2017-01-06T19:34:52.887Z"
+	}`)
+
+	tests := []struct {
+		annotations []string
+		expected    string
+	}{
+		{[]string{"summary"}, `"Address http://test.com appears to be down" alert starts at Fri, 06 Jan 2017 19:34:52 UTC`},
+		{[]string{"summary", "description", "runbook_url"}, `"Address http://test.com appears to be down - http://runbooks/down" alert starts at Fri, 06 Jan 2017 19:34:52 UTC`},
+		{[]string{"description"}, ""},
+	}
+
+	for _, test := range tests {
+		output := formatMessage(&Config{Annotations: test.annotations}, &PayloadMeta{Status: "firing"}, alert)
+		if output != test.expected {
+			t.Errorf("formatMessage(%v) == %q, want %q", test.annotations, output, test.expected)
+		}
+	}
+}
+
+func TestFormatMessageLabels(t *testing.T) {
+	alert := []byte(`{
+		"labels": {"instance": "db-1", "severity": "critical"},
+		"annotations": {"summary": "Disk full"}
+	}`)
+
+	expected := "Disk full instance=db-1 severity=critical"
+	output := formatMessage(&Config{Annotations: []string{"summary"}, Labels: []string{"instance", "job", "severity"}}, &PayloadMeta{Status: "firing"}, alert)
+	if output != expected {
+		t.Errorf("formatMessage() == %q, want %q", output, expected)
+	}
+}
+
+func TestFormatMessageSeverityPrefix(t *testing.T) {
+	o := &Config{
+		Annotations:      []string{"summary"},
+		SeverityPrefixes: map[string]string{"critical": "🔴", "warning": "🟡"},
+	}
+
+	tests := []struct {
+		alert    string
+		expected string
+	}{
+		{`{"labels": {"severity": "critical"}, "annotations": {"summary": "Disk full"}}`, "🔴 Disk full"},
+		{`{"labels": {"severity": "info"}, "annotations": {"summary": "Disk full"}}`, "Disk full"},
+		{`{"annotations": {"summary": "Disk full"}}`, "Disk full"},
+	}
+
+	for _, test := range tests {
+		output := formatMessage(o, &PayloadMeta{Status: "firing"}, []byte(test.alert))
+		if output != test.expected {
+			t.Errorf("formatMessage(%s) == %q, want %q", test.alert, output, test.expected)
+		}
+	}
+}
+
+func TestFormatMessageStatusPrefix(t *testing.T) {
+	o := &Config{
+		Annotations:      []string{"summary"},
+		SeverityPrefixes: map[string]string{"critical": "🔴"},
+		FiringPrefix:     "ALERTE: ",
+		ResolvedPrefix:   "RÉTABLI: ",
+	}
+	alert := []byte(`{"labels": {"severity": "critical"}, "annotations": {"summary": "Disk full"}}`)
+
+	tests := []struct {
+		status   string
+		expected string
+	}{
+		{"firing", "ALERTE: 🔴 Disk full"},
+		{"resolved", "RÉTABLI: 🔴 Disk full"},
+	}
+
+	for _, test := range tests {
+		output := formatMessage(o, &PayloadMeta{Status: test.status}, alert)
+		if output != test.expected {
+			t.Errorf("formatMessage(%q) == %q, want %q", test.status, output, test.expected)
+		}
+	}
+}
+
+func TestFormatMessageCommonFallback(t *testing.T) {
+	payload := []byte(`{
+		"status": "firing",
+		"receiver": "sms",
+		"commonLabels": {"severity": "critical", "job": "db"},
+		"commonAnnotations": {"summary": "Databases down"},
+		"alerts": []
+	}`)
+	meta := parsePayloadMeta(payload)
+	if meta.Status != "firing" || meta.Receiver != "sms" {
+		t.Fatalf("parsePayloadMeta() == %+v", meta)
+	}
+
+	o := &Config{
+		Annotations:      []string{"summary"},
+		Labels:           []string{"instance", "job"},
+		SeverityPrefixes: map[string]string{"critical": "🔴"},
+	}
+
+	tests := []struct {
+		alert    string
+		expected string
+	}{
+		{`{"labels": {"instance": "db-1"}}`, "🔴 Databases down instance=db-1 job=db"},
+		{`{"labels": {"instance": "db-2", "job": "pg"}, "annotations": {"summary": "db-2 down"}}`, "🔴 db-2 down instance=db-2 job=pg"},
+	}
+
+	for _, test := range tests {
+		output := formatMessage(o, meta, []byte(test.alert))
+		if output != test.expected {
+			t.Errorf("formatMessage(%s) == %q, want %q", test.alert, output, test.expected)
+		}
+	}
+}
+
+func TestFormatMessageExternalURL(t *testing.T) {
+	meta := parsePayloadMeta([]byte(`{"status": "firing", "externalURL": "http://alertmanager:9093"}`))
+	alert := []byte(`{"labels": {"job": "db"}, "annotations": {"summary": "Disk full"}}`)
+
+	o := &Config{Annotations: []string{"summary"}, Labels: []string{"job"}}
+	if output := formatMessage(o, meta, alert); output != "Disk full job=db" {
+		t.Errorf("formatMessage() == %q without INCLUDE_EXTERNAL_URL", output)
+	}
+
+	o.IncludeExternalURL = true
+	expected := "Disk full job=db http://alertmanager:9093"
+	if output := formatMessage(o, meta, alert); output != expected {
+		t.Errorf("formatMessage() == %q, want %q", output, expected)
+	}
+}
@@ -0,0 +1,179 @@
+package promtotwilio
+
+import (
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// registry holds the metrics of the process exposed on /metrics, those of
+// the handlers being held by their own registry
+var registry = prometheus.NewRegistry()
+
+func newCounter(name, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	registry.MustRegister(c)
+	return c
+}
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	registry.MustRegister(c)
+	return c
+}
+
+// defaultBuckets are the upper bounds of the histogram buckets, in seconds
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	registry.MustRegister(h)
+	return h
+}
+
+var (
+	messagesSentTotal = newCounter("promtotwilio_messages_sent_total",
+		"Number of messages accepted by Twilio.")
+	messagesFailedTotal = newCounter("promtotwilio_messages_failed_total",
+		"Number of messages which couldn't be sent.")
+	messagesSuppressedTotal = newCounterVec("promtotwilio_messages_suppressed_total",
+		"Number of messages which weren't sent on purpose, by reason.", "reason")
+	alertsFilteredTotal = newCounter("promtotwilio_alerts_filtered_total",
+		"Number of alerts dropped by the include and exclude filters.")
+	alertsDroppedTotal = newCounter("promtotwilio_alerts_dropped_total",
+		"Number of alerts beyond MAX_ALERTS_PER_WEBHOOK, summarized in a single message.")
+	alertsOptedOutTotal = newCounter("promtotwilio_alerts_opted_out_total",
+		"Number of alerts not sent because of their sms or sms_skip annotation.")
+	smsUndeliveredTotal = newCounterVec("promtotwilio_sms_undelivered_total",
+		"Number of messages Twilio reported as undelivered or failed, by status.", "status")
+	messagesEscalatedTotal = newCounter("promtotwilio_messages_escalated_total",
+		"Number of undelivered messages sent again through another channel.")
+	whatsAppFallbacksTotal = newCounter("promtotwilio_whatsapp_fallbacks_total",
+		"Number of WhatsApp messages which failed and were sent as SMS instead.")
+	twilioErrorsTotal = newCounterVec("promtotwilio_twilio_errors_total",
+		"Number of errors returned by the Twilio API, by Twilio error code.", "code")
+	twilioRateLimitedTotal = newCounter("promtotwilio_twilio_rate_limited_total",
+		"Number of requests rejected by the rate limits of the Twilio API.")
+	notificationsTotal = newCounterVec("promtotwilio_notifications_total",
+		"Number of alerts sent to the incident management services, by notifier and result.", "notifier", "result")
+	archiveFailuresTotal = newCounter("promtotwilio_archive_failures_total",
+		"Number of failed uploads or deletions of the archives of the history and payloads.")
+	panicsTotal = newCounter("promtotwilio_panics_total",
+		"Number of panics recovered while serving requests.")
+	requestsRejectedTotal = newCounterVec("promtotwilio_requests_rejected_total",
+		"Number of requests answered 503 because the server or its queues were saturated or they timed out, by reason.", "reason")
+	queueDroppedTotal = newCounterVec("promtotwilio_queue_dropped_total",
+		"Number of messages dropped because the batch and delay queues were full, and of payloads because the forward one was, by queue.", "queue")
+	httpRequestDuration = newHistogramVec("promtotwilio_http_request_duration_seconds",
+		"Duration of the HTTP requests, by path, method and status code.", defaultBuckets, "path", "method", "code")
+)
+
+var (
+	// version and commit are set at build time with -ldflags
+	version = "dev"
+	commit  = "unknown"
+
+	inFlightRequests int64
+	// twilioRequestsWaiting is the number of messages waiting for a slot
+	// of TWILIO_MAX_CONCURRENT
+	twilioRequestsWaiting int64
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "promtotwilio_build_info",
+			Help:        "A metric with a constant '1' value labeled by the version, Go version and commit promtotwilio was built from.",
+			ConstLabels: prometheus.Labels{"version": version, "goversion": runtime.Version(), "commit": commit},
+		}, func() float64 { return 1 }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "promtotwilio_http_requests_in_flight",
+			Help: "Number of HTTP requests being served.",
+		}, func() float64 { return float64(atomic.LoadInt64(&inFlightRequests)) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "promtotwilio_twilio_requests_waiting",
+			Help: "Number of messages waiting for one of the TWILIO_MAX_CONCURRENT requests to the Twilio API to complete.",
+		}, func() float64 { return float64(atomic.LoadInt64(&twilioRequestsWaiting)) }),
+	)
+}
+
+// newHandlerMetrics returns the registry of the metrics of a handler: the
+// rate limiting backoff of its Twilio account and the depth of its queues
+func newHandlerMetrics(throttle *twilioThrottle, batcher *batcher, delayer *delayer) *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	if throttle != nil {
+		r.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "promtotwilio_twilio_rate_limit_backoff_seconds",
+			Help: "Time left before the messages are sent again, after Twilio rate limited a request.",
+		}, func() float64 { return throttle.backoff(time.Now()).Seconds() }))
+	}
+	queueDepth := func(queue string, depth func() int) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "promtotwilio_queue_depth",
+			Help:        "Number of messages waiting to be sent, by queue.",
+			ConstLabels: prometheus.Labels{"queue": queue},
+		}, func() float64 { return float64(depth()) })
+	}
+	if batcher != nil {
+		r.MustRegister(queueDepth("batch", batcher.depth))
+	}
+	if delayer != nil {
+		r.MustRegister(queueDepth("delay", delayer.depth))
+	}
+	return r
+}
+
+// InstrumentRequests wraps a handler to track the number of requests being
+// served and their duration
+func InstrumentRequests(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		atomic.AddInt64(&inFlightRequests, 1)
+		start := time.Now()
+		h(ctx)
+		atomic.AddInt64(&inFlightRequests, -1)
+
+		code := ctx.Response.StatusCode()
+		httpRequestDuration.WithLabelValues(routeLabel(string(ctx.Path())), string(ctx.Method()), strconv.Itoa(code)).Observe(time.Since(start).Seconds())
+	}
+}
+
+// routes are the paths served by HandleFastHTTP
+var routes = map[string]bool{
+	"/": true, "/v1/health": true, "/send": true, "/v1/send": true, "/cloudevents": true,
+	"/status": true, "/v1/status": true, "/openapi.json": true, "/metrics": true,
+	"/events": true, "/ui": true, "/admin/messages": true, "/v1/messages": true,
+	"/messages/export": true, "/admin/messages/export": true, "/admin/heartbeat": true, "/admin/replay": true,
+	"/admin/payloads": true, "/admin/audit": true, "/admin/payloads/replay": true,
+}
+
+// routeLabel returns the path label of a request, "other" for the paths
+// which aren't routes whatever their status, so that the requests for
+// random paths don't add series
+func routeLabel(path string) string {
+	if routes[path] {
+		return path
+	}
+	if pprofPath(path) {
+		return pprofPrefix
+	}
+	return "other"
+}
+
+// writeMetrics exposes the metrics of the process and of the handler in
+// the format negotiated with the scraper
+func (m OptionsWithHandler) writeMetrics(ctx *fasthttp.RequestCtx) {
+	gatherers := prometheus.Gatherers{registry}
+	if m.Metrics != nil {
+		gatherers = append(gatherers, m.Metrics)
+	}
+	fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}))(ctx)
+}
@@ -0,0 +1,67 @@
+package promtotwilio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	// every bridge exposes its own handler metrics
+	for i := 0; i < 2; i++ {
+		bridge := New("AC123", "secret", "+100")
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/metrics")
+		bridge.HandleFastHTTP(ctx)
+		bridge.Stop()
+
+		if ctx.Response.StatusCode() != fasthttp.StatusOK {
+			t.Fatalf("/metrics answered %d", ctx.Response.StatusCode())
+		}
+		body := string(ctx.Response.Body())
+		for _, line := range []string{
+			"# TYPE promtotwilio_messages_sent_total counter\n",
+			"# TYPE promtotwilio_twilio_rate_limit_backoff_seconds gauge\n",
+			"# TYPE go_goroutines gauge\n",
+			"promtotwilio_build_info{commit=\"unknown\",goversion=",
+		} {
+			if !strings.Contains(body, line) {
+				t.Errorf("/metrics of bridge %d doesn't contain %q: %s", i, line, body)
+			}
+		}
+	}
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	r := newHandlerMetrics(newTwilioThrottle(), newBatcher(0, nil), nil)
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	if expected := "promtotwilio_queue_depth,promtotwilio_twilio_rate_limit_backoff_seconds"; strings.Join(names, ",") != expected {
+		t.Errorf("handler metrics %v, want %s", names, expected)
+	}
+}
+
+func TestRouteLabel(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/send", "/send"},
+		{"/admin/audit", "/admin/audit"},
+		{"/admin/f00b4r", "other"},
+		{"/debug/pprof/heap", "/debug/pprof/"},
+		{"/random", "other"},
+	}
+	for _, test := range tests {
+		if label := routeLabel(test.path); label != test.expected {
+			t.Errorf("routeLabel(%q) == %q, want %q", test.path, label, test.expected)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package promtotwilio
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNATSSubscriber(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+	s, err := newNATSSubscriber("nats://token@"+l.Addr().String(), "alerts", "promtotwilio", func(payload []byte) {
+		received <- string(payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.start()
+	defer s.Stop()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+	connect, _ := r.ReadString('\n')
+	if !strings.HasPrefix(connect, "CONNECT ") || !strings.Contains(connect, `"auth_token":"token"`) {
+		t.Errorf("CONNECT == %q", connect)
+	}
+	if sub, _ := r.ReadString('\n'); sub != "SUB alerts promtotwilio 1\r\n" {
+		t.Errorf("SUB == %q", sub)
+	}
+	r.ReadString('\n') // PING
+
+	conn.Write([]byte("PING\r\n"))
+	if pong, _ := r.ReadString('\n'); pong != "PONG\r\n" {
+		t.Errorf("PONG == %q", pong)
+	}
+
+	conn.Write([]byte("MSG alerts 1 15\r\n{\"status\":\"ok\"}\r\n"))
+	select {
+	case payload := <-received:
+		if payload != `{"status":"ok"}` {
+			t.Errorf("payload == %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no payload received")
+	}
+}
@@ -0,0 +1,73 @@
+package promtotwilio
+
+import (
+	"sync"
+	"time"
+)
+
+// notifiedTTL is how long alerts are remembered as notified, bounding the
+// memory used by the alerts which never resolve
+const notifiedTTL = 7 * 24 * time.Hour
+
+// notifiedAlerts remembers the alerts which were notified while firing, so
+// that only their resolved notifications are sent
+type notifiedAlerts struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	alerts map[string]time.Time
+}
+
+func newNotifiedAlerts() *notifiedAlerts {
+	return &notifiedAlerts{now: time.Now, alerts: make(map[string]time.Time)}
+}
+
+// add remembers the alert as notified
+func (n *notifiedAlerts) add(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := n.now()
+	for k, t := range n.alerts {
+		if now.Sub(t) > notifiedTTL {
+			delete(n.alerts, k)
+		}
+	}
+	n.alerts[key] = now
+}
+
+// contains reports whether the alert was notified
+func (n *notifiedAlerts) contains(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.alerts[key]
+	return ok
+}
+
+// take reports whether the alert was notified and forgets it
+func (n *notifiedAlerts) take(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.alerts[key]
+	delete(n.alerts, key)
+	return ok
+}
+
+// snapshot returns the notified alerts
+func (n *notifiedAlerts) snapshot() map[string]time.Time {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	alerts := make(map[string]time.Time, len(n.alerts))
+	for k, t := range n.alerts {
+		alerts[k] = t
+	}
+	return alerts
+}
+
+// restore adds saved notified alerts
+func (n *notifiedAlerts) restore(alerts map[string]time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, t := range alerts {
+		n.alerts[k] = t
+	}
+}
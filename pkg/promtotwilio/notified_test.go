@@ -0,0 +1,27 @@
+package promtotwilio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotifiedAlerts(t *testing.T) {
+	now := time.Now()
+	n := newNotifiedAlerts()
+	n.now = func() time.Time { return now }
+
+	n.add("a")
+	if !n.contains("a") || n.contains("b") {
+		t.Errorf("contains() doesn't match the added alerts")
+	}
+	if !n.take("a") || n.take("a") {
+		t.Errorf("take() didn't forget the alert")
+	}
+
+	n.add("b")
+	now = now.Add(notifiedTTL + time.Hour)
+	n.add("c")
+	if n.contains("b") {
+		t.Errorf("alert remembered after the TTL")
+	}
+}
@@ -0,0 +1,169 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// notifyMaxRetries is the number of times a failed notification is
+	// retried
+	notifyMaxRetries = 3
+	// notifyRetryBase is the delay before the first retry, doubled after
+	// every retry
+	notifyRetryBase = time.Second
+)
+
+// notifierNames are the names of the notifiers routes can select
+var notifierNames = map[string]bool{
+	"pagerduty": true,
+	"opsgenie":  true,
+}
+
+// notifier opens and closes the incidents of the alerts in an incident
+// management service, alongside the text messages
+type notifier interface {
+	// name identifies the notifier in the logs and metrics
+	name() string
+	// notify opens the incident of a firing alert or closes the one of a
+	// resolved alert
+	notify(meta *PayloadMeta, alert []byte) error
+}
+
+// notifiers sends the alerts to the notifiers in the background, retrying
+// the failed requests
+type notifiers struct {
+	list  []notifier
+	sleep func(time.Duration)
+
+	wg sync.WaitGroup
+}
+
+func newNotifiers(list ...notifier) *notifiers {
+	return &notifiers{list: list, sleep: time.Sleep}
+}
+
+// dispatch sends an alert to the notifiers of its route in the background
+func (n *notifiers) dispatch(logger *log.Entry, r *Route, meta *PayloadMeta, alert []byte) {
+	// the request buffers are reused once the handler returns
+	copied := *meta
+	copied.CommonLabels = append([]byte(nil), meta.CommonLabels...)
+	copied.CommonAnnotations = append([]byte(nil), meta.CommonAnnotations...)
+	alert = append([]byte(nil), alert...)
+
+	for _, nt := range n.list {
+		if !r.notifies(nt.name()) {
+			continue
+		}
+		n.wg.Add(1)
+		go func(nt notifier) {
+			defer n.wg.Done()
+			delay := notifyRetryBase
+			for attempt := 0; ; attempt++ {
+				err := nt.notify(&copied, alert)
+				if err == nil {
+					notificationsTotal.WithLabelValues(nt.name(), "sent").Inc()
+					return
+				}
+				if e, ok := err.(*notifyError); (ok && !e.temporary()) || attempt == notifyMaxRetries {
+					notificationsTotal.WithLabelValues(nt.name(), "failed").Inc()
+					logger.Errorf("Error notifying %s, giving up: %v", nt.name(), err)
+					return
+				}
+				logger.Warnf("Error notifying %s, retrying in %s: %v", nt.name(), delay, err)
+				n.sleep(delay)
+				delay *= 2
+			}
+		}(nt)
+	}
+}
+
+// Stop waits for the notifications in progress
+func (n *notifiers) Stop() {
+	n.wg.Wait()
+}
+
+// notify sends the firing and resolved alerts of a /send request to the
+// notifiers of its route, unless it's a dry run or this instance is a
+// standby
+func (m OptionsWithHandler) notify(job *sendJob, alert []byte) {
+	if m.Notifiers == nil || job.response.DryRun || (m.Leader != nil && !m.Leader.leading()) {
+		return
+	}
+	switch job.meta.status(alert) {
+	case "firing", "resolved":
+		m.Notifiers.dispatch(job.logger, job.route, job.meta, alert)
+	}
+}
+
+// notifyError is the error status returned by the API of a notifier
+type notifyError struct {
+	status int
+	body   string
+}
+
+func (e *notifyError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.status, e.body)
+}
+
+// temporary reports whether the request may succeed when retried
+func (e *notifyError) temporary() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= http.StatusInternalServerError
+}
+
+// postJSON posts a JSON body with the headers, returning a *notifyError
+// for the error statuses
+func postJSON(client *http.Client, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		b, _ := ioutil.ReadAll(res.Body)
+		return &notifyError{status: res.StatusCode, body: string(bytes.TrimSpace(b))}
+	}
+	return nil
+}
+
+// dedupKey identifies the incident of an alert by its fingerprint, or else
+// by a hash of its labels
+func dedupKey(alert []byte) string {
+	if fingerprint, _ := jsonparser.GetString(alert, "fingerprint"); fingerprint != "" {
+		return fingerprint
+	}
+	sum := sha256.Sum256([]byte(alertKey(alert)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// alertSummary describes an alert in a line: its summary or description
+// annotation, or else its name
+func alertSummary(meta *PayloadMeta, alert []byte) string {
+	for _, name := range []string{"summary", "description"} {
+		if value := meta.annotation(alert, name); value != "" {
+			return findAndReplaceLables(value, alert)
+		}
+	}
+	if name := meta.label(alert, "alertname"); name != "" {
+		return name
+	}
+	return "Alert"
+}
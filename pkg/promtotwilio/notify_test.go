@@ -0,0 +1,60 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeNotifier fails with the given errors, in order, then succeeds
+type fakeNotifier struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeNotifier) name() string { return "fake" }
+
+func (f *fakeNotifier) notify(meta *PayloadMeta, alert []byte) error {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func TestNotifiersRetry(t *testing.T) {
+	tests := []struct {
+		errs     []error
+		expected int
+	}{
+		{nil, 1},
+		{[]error{&notifyError{status: http.StatusServiceUnavailable}, &notifyError{status: http.StatusTooManyRequests}}, 3},
+		{[]error{&notifyError{status: http.StatusBadRequest}}, 1},
+		{[]error{&notifyError{status: 500}, &notifyError{status: 500}, &notifyError{status: 500}, &notifyError{status: 500}, &notifyError{status: 500}}, notifyMaxRetries + 1},
+	}
+	for _, test := range tests {
+		f := &fakeNotifier{errs: test.errs}
+		n := newNotifiers(f)
+		n.sleep = func(time.Duration) {}
+		n.dispatch(log.NewEntry(log.StandardLogger()), nil, &PayloadMeta{}, []byte(`{}`))
+		n.Stop()
+		if f.calls != test.expected {
+			t.Errorf("%d calls with errors %v, want %d", f.calls, test.errs, test.expected)
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	if key := dedupKey([]byte(`{"fingerprint": "abc", "labels": {"alertname": "A"}}`)); key != "abc" {
+		t.Errorf("dedupKey() == %q, want the fingerprint", key)
+	}
+	a := dedupKey([]byte(`{"labels": {"alertname": "A"}}`))
+	b := dedupKey([]byte(`{"labels": {"alertname": "B"}}`))
+	if len(a) != 32 || a == b {
+		t.Errorf("dedupKey() == %q and %q, want distinct hashes", a, b)
+	}
+}
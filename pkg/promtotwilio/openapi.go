@@ -0,0 +1,253 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// openAPISchemas are the types of the responses described in the OpenAPI
+// document, whose schemas are derived from their fields so they can't drift
+var openAPISchemas = []interface{}{
+	SendResponse{},
+	SendResult{},
+	HealthResponse{},
+	HistoryEntry{},
+	ReplayResponse{},
+	CapturedPayload{},
+	AuditEntry{},
+	Problem{},
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+// serveOpenAPI serves the OpenAPI 3 document describing the API
+func (m OptionsWithHandler) serveOpenAPI(ctx *fasthttp.RequestCtx) {
+	openAPIOnce.Do(func() {
+		openAPIDocument, _ = json.MarshalIndent(newOpenAPIDocument(), "", "  ")
+	})
+	ctx.SetContentType("application/json")
+	ctx.Write(openAPIDocument)
+}
+
+func newOpenAPIDocument() map[string]interface{} {
+	schemas := make(map[string]interface{}, len(openAPISchemas))
+	for _, v := range openAPISchemas {
+		t := reflect.TypeOf(v)
+		schemas[t.Name()] = typeSchema(t, true)
+	}
+
+	send := func(summary string) map[string]interface{} {
+		return map[string]interface{}{
+			"post": operation(summary, []interface{}{
+				query("receiver", "Comma separated receivers"),
+				query("group", "Comma separated groups of RECEIVER_GROUPS"),
+				query("dry_run", "Don't send anything, only describe the messages"),
+			}, map[string]interface{}{
+				"200": jsonResponse("Every message was sent", "SendResponse"),
+				"207": jsonResponse("Some messages couldn't be sent", "SendResponse"),
+				"400": problemResponse(),
+				"413": problemResponse(),
+				"500": jsonResponse("No message could be sent", "SendResponse"),
+			}),
+		}
+	}
+	messages := map[string]interface{}{
+		"get": operation("Outcome of the last messages, most recent first", nil, map[string]interface{}{
+			"200": jsonArrayResponse("The messages", "HistoryEntry"),
+		}),
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "promtotwilio",
+			"version": version,
+		},
+		"paths": map[string]interface{}{
+			"/v1/send":     send("Send the alerts of an Alertmanager webhook payload"),
+			"/send":        send("Alias of /v1/send"),
+			"/cloudevents": send("Alias of /v1/send for CloudEvents"),
+			"/v1/health": map[string]interface{}{
+				"get": operation("Describe the instance", nil, map[string]interface{}{
+					"200": jsonResponse("The instance", "HealthResponse"),
+				}),
+			},
+			"/v1/messages":    messages,
+			"/admin/messages": messages,
+			"/admin/replay": map[string]interface{}{
+				"post": operation("Send again the messages which failed", []interface{}{
+					query("since", "Duration, e.g. 1h, the failed messages are sent again within"),
+				}, map[string]interface{}{
+					"200": jsonResponse("The messages sent again", "ReplayResponse"),
+					"400": problemResponse(),
+				}),
+			},
+			"/admin/heartbeat": map[string]interface{}{
+				"post": operation("Send a heartbeat to HEARTBEAT_RECEIVER", nil, map[string]interface{}{
+					"200": jsonResponse("The heartbeat", "SendResult"),
+					"502": problemResponse(),
+				}),
+			},
+			"/admin/payloads": map[string]interface{}{
+				"get": operation("Last payloads received, most recent first", nil, map[string]interface{}{
+					"200": jsonArrayResponse("The payloads", "CapturedPayload"),
+				}),
+			},
+			"/admin/payloads/replay": map[string]interface{}{
+				"post": operation("Process a captured payload again", []interface{}{
+					query("id", "Id of the captured payload"),
+				}, map[string]interface{}{
+					"200": jsonResponse("The outcome of the payload", "SendResponse"),
+					"404": problemResponse(),
+				}),
+			},
+			"/admin/audit": map[string]interface{}{
+				"get": operation("Admin actions, most recent first", []interface{}{
+					query("actor", "Actor of the actions"),
+					query("action", "Type of the actions, e.g. dead_letters.replay"),
+					query("since", "Duration, e.g. 24h, the actions were made within"),
+					query("limit", "Maximum number of actions, 100 by default"),
+				}, map[string]interface{}{
+					"200": jsonArrayResponse("The actions", "AuditEntry"),
+					"400": problemResponse(),
+				}),
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+func operation(summary string, parameters []interface{}, responses map[string]interface{}) map[string]interface{} {
+	op := map[string]interface{}{"summary": summary, "responses": responses}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	return op
+}
+
+func query(name, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]interface{}{"type": "string"},
+	}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonResponse(description, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": ref(schema)}},
+	}
+}
+
+func jsonArrayResponse(description, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{"application/json": map[string]interface{}{
+			"schema": map[string]interface{}{"type": "array", "items": ref(schema)},
+		}},
+	}
+}
+
+func problemResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"description": "The problem",
+		"content":     map[string]interface{}{"application/problem+json": map[string]interface{}{"schema": ref("Problem")}},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema returns the JSON schema of a type as encoded by encoding/json,
+// referring to the other schemas of the document. top is false for the
+// types of fields.
+func typeSchema(t reflect.Type, top bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), false)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), false)}
+	case reflect.Struct:
+		if !top && isOpenAPISchema(t) {
+			return ref(t.Name())
+		}
+		properties := make(map[string]interface{})
+		var required []string
+		addProperties(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// addProperties adds the fields of a struct, and of the ones it embeds, to
+// the properties of its schema
+func addProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addProperties(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type, false)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func isOpenAPISchema(t reflect.Type) bool {
+	for _, v := range openAPISchemas {
+		if reflect.TypeOf(v) == t {
+			return true
+		}
+	}
+	return false
+}
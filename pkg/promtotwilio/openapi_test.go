@@ -0,0 +1,42 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestOpenAPI(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/openapi.json")
+	OptionsWithHandler{Options: &Config{}}.HandleFastHTTP(ctx)
+
+	var document struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(ctx.Response.Body(), &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != "3.0.3" {
+		t.Errorf("openapi == %q", document.OpenAPI)
+	}
+
+	response := document.Components.Schemas["SendResponse"]
+	properties := response["properties"].(map[string]interface{})
+	results := properties["results"].(map[string]interface{})
+	if !reflect.DeepEqual(results["items"], map[string]interface{}{"$ref": "#/components/schemas/SendResult"}) {
+		t.Errorf("results == %v", results)
+	}
+
+	entry := document.Components.Schemas["HistoryEntry"]["properties"].(map[string]interface{})
+	for _, name := range []string{"time", "request_id", "receiver", "sid"} {
+		if entry[name] == nil {
+			t.Errorf("HistoryEntry misses the %s property of %v", name, entry)
+		}
+	}
+}
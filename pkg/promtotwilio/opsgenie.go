@@ -0,0 +1,123 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	// DefaultOpsgenieURL is the Opsgenie API, https://api.eu.opsgenie.com
+	// for the EU instance
+	DefaultOpsgenieURL = "https://api.opsgenie.com"
+	// opsgenieMessageLength and opsgenieDescriptionLength are the maximum
+	// lengths of the message and description of an alert
+	opsgenieMessageLength     = 130
+	opsgenieDescriptionLength = 15000
+)
+
+// opsgeniePriorities map the severity labels to the priorities of the
+// Opsgenie alerts, the other severities being P3
+var opsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+// opsgenie creates and closes Opsgenie alerts, whose aliases are the
+// fingerprints of the alerts
+type opsgenie struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func newOpsgenie(url, apiKey string) *opsgenie {
+	return &opsgenie{
+		url:    strings.TrimRight(url, "/"),
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// opsgenieAlert is the body creating an Opsgenie alert
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// opsgenieClose is the body closing an Opsgenie alert
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+func (o *opsgenie) name() string {
+	return "opsgenie"
+}
+
+// notify creates the Opsgenie alert of a firing alert, or closes the one of
+// a resolved alert
+func (o *opsgenie) notify(meta *PayloadMeta, alert []byte) error {
+	header := http.Header{"Authorization": {"GenieKey " + o.apiKey}}
+	alias := dedupKey(alert)
+	if meta.status(alert) == "resolved" {
+		body, err := json.Marshal(opsgenieClose{Source: "promtotwilio", Note: "Resolved in Alertmanager"})
+		if err != nil {
+			return err
+		}
+		endpoint := o.url + "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		return postJSON(o.client, endpoint, header, body)
+	}
+
+	body, err := json.Marshal(o.alert(meta, alert, alias))
+	if err != nil {
+		return err
+	}
+	return postJSON(o.client, o.url+"/v2/alerts", header, body)
+}
+
+func (o *opsgenie) alert(meta *PayloadMeta, alert []byte, alias string) opsgenieAlert {
+	priority, ok := opsgeniePriorities[meta.label(alert, "severity")]
+	if !ok {
+		priority = "P3"
+	}
+	created := opsgenieAlert{
+		Message:  truncate(alertSummary(meta, alert), opsgenieMessageLength, true, DefaultEllipsis),
+		Alias:    alias,
+		Priority: priority,
+		Source:   "promtotwilio",
+		Entity:   meta.label(alert, "instance"),
+		Details:  make(map[string]string),
+	}
+	if description := meta.annotation(alert, "description"); description != "" {
+		created.Description = truncate(findAndReplaceLables(description, alert), opsgenieDescriptionLength, true, DefaultEllipsis)
+	}
+	if name := meta.label(alert, "alertname"); name != "" {
+		created.Tags = append(created.Tags, name)
+	}
+	if severity := meta.label(alert, "severity"); severity != "" {
+		created.Tags = append(created.Tags, severity)
+	}
+	jsonparser.ObjectEach(alert, func(key, value []byte, dataType jsonparser.ValueType, offset int) error {
+		if dataType == jsonparser.String {
+			created.Details[string(key)], _ = jsonparser.ParseString(value)
+		}
+		return nil
+	}, "labels")
+	if generatorURL, _ := jsonparser.GetString(alert, "generatorURL"); generatorURL != "" {
+		created.Details["generatorURL"] = generatorURL
+	}
+	return created
+}
@@ -0,0 +1,75 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpsgenieAlert(t *testing.T) {
+	o := newOpsgenie(DefaultOpsgenieURL, "key")
+	meta := &PayloadMeta{CommonAnnotations: []byte(`{"summary": "Disk full", "description": "Only 1% left on $labels.instance"}`)}
+	alert := []byte(`{"status": "firing", "labels": {"alertname": "DiskFull", "instance": "db1", "severity": "critical", "path": "C:\\data"}}`)
+
+	created := o.alert(meta, alert, "abc")
+	expected := opsgenieAlert{
+		Message:     "Disk full",
+		Alias:       "abc",
+		Description: "Only 1% left on db1",
+		Priority:    "P1",
+		Source:      "promtotwilio",
+		Entity:      "db1",
+		Tags:        []string{"DiskFull", "critical"},
+		Details:     map[string]string{"alertname": "DiskFull", "instance": "db1", "severity": "critical", "path": `C:\data`},
+	}
+	if !reflect.DeepEqual(created, expected) {
+		t.Errorf("alert() == %+v, want %+v", created, expected)
+	}
+
+	if created := o.alert(&PayloadMeta{}, []byte(`{"labels": {"severity": "page"}}`), "abc"); created.Priority != "P3" || created.Message != "Alert" {
+		t.Errorf("alert() == %+v, want a P3 alert", created)
+	}
+}
+
+func TestSendRequestOpsgenie(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests = make(map[string]string)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		requests[r.URL.RequestURI()] = string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	notifiers := newNotifiers(newOpsgenie(server.URL+"/", "key"))
+	notifiers.sleep = func(time.Duration) {}
+	m := OptionsWithHandler{
+		Options:   &Config{Receiver: "+15550001", Sender: "+100", Annotations: []string{"summary"}},
+		Client:    &fakeTwilioClient{},
+		Notifiers: notifiers,
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"status": "firing", "fingerprint": "a", "annotations": {"summary": "Disk full"}},
+		{"status": "resolved", "fingerprint": "b/c", "annotations": {"summary": "CPU high"}}]}`))
+	notifiers.Stop()
+
+	var created opsgenieAlert
+	if err := json.Unmarshal([]byte(requests["/v2/alerts"]), &created); err != nil || created.Alias != "a" || created.Message != "Disk full" {
+		t.Errorf("created %+v, %v", created, err)
+	}
+	if _, ok := requests["/v2/alerts/b%2Fc/close?identifierType=alias"]; !ok || len(requests) != 2 {
+		t.Errorf("requests == %v, want the closing of b/c", requests)
+	}
+}
@@ -0,0 +1,1217 @@
+package promtotwilio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/swatto/promtotwilio/pkg/promtotwilio/alertspb"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc/credentials"
+)
+
+// OptionsWithHandler is a struct with a mux and shared credentials
+type OptionsWithHandler struct {
+	Options *Config
+	Client  TwilioClient
+	// Payloads keeps the last webhook payloads, nil when capture is disabled
+	Payloads *payloadRing
+	// Batcher sends non-critical messages as digests, nil when disabled
+	Batcher *batcher
+	// Storm suppresses messages during alert storms, nil when disabled
+	Storm *stormGuard
+	// Budget caps the messages sent per month, nil when disabled
+	Budget *budget
+	// Idempotency replays the responses of retried /send requests, nil when
+	// disabled
+	Idempotency *idempotencyCache
+	// Forwarder re-posts the payloads to other URLs, nil when disabled
+	Forwarder *forwarder
+	// Pinger pings an external watchdog after the successful webhooks, nil
+	// when disabled
+	Pinger *pinger
+	// Watchdog warns when no webhook is received for a while, nil when
+	// disabled
+	Watchdog *watchdog
+	// Heartbeat sends periodic heartbeat messages, nil when disabled
+	Heartbeat *heartbeat
+	// Events streams the send activity on /events
+	Events *eventBroker
+	// NATS receives the payloads published on NATS_SUBJECT, nil when disabled
+	NATS *natsSubscriber
+	// SQS drains the payloads of SQS_QUEUE_URL, nil when disabled
+	SQS *sqsPoller
+	// Kafka consumes the payloads of KAFKA_TOPIC, nil when disabled
+	Kafka *kafkaConsumer
+	// GRPC serves the SendAlert RPC on GRPC_PORT, nil when disabled
+	GRPC *grpcServer
+	// Archiver uploads the history and payloads to ARCHIVE_URL, nil when
+	// disabled
+	Archiver *archiver
+	// Leader elects the instance of an active/standby pair sending messages,
+	// nil when it runs alone
+	Leader *leaderElection
+	// Flaps collapses the notifications of flapping alerts, nil when disabled
+	Flaps *flapDetector
+	// Notified remembers the alerts notified while firing, so that only
+	// their resolved notifications are sent, nil to send them all
+	Notified *notifiedAlerts
+	// JWT validates the JWTs of the webhooks, nil when disabled
+	JWT *jwtVerifier
+	// Limiter caps the number of webhooks served at once, nil when unlimited
+	Limiter *sendLimiter
+	// Throttle holds back the requests to the Twilio account while it's
+	// rate limited, nil for the clients set up by the caller
+	Throttle *twilioThrottle
+	// DeadLetters keeps the failed messages for replay, nil when disabled
+	DeadLetters *deadLetters
+	// Deliveries tracks the messages until Twilio reports whether they were
+	// delivered, nil without STATUS_CALLBACK_URL
+	Deliveries *deliveryTracker
+	// History keeps the outcome of the last messages, nil when disabled
+	History *messageHistory
+	// Audit records the admin actions, nil without AUDIT_LOG_FILE
+	Audit *auditLog
+	// Notifiers sends the alerts to incident management services alongside
+	// the messages, nil when none is configured
+	Notifiers *notifiers
+	// State saves the state of the options above to a file, nil when disabled
+	State *stateSaver
+	// Delayer holds back the messages of the firing alerts for FOR_DURATION
+	// or the delay of their route, nil when none is set
+	Delayer *delayer
+	// Senders picks the sender number of each receiver, nil to always use
+	// the one of the options
+	Senders *senderPool
+	// CountrySenders picks the sender number of the receivers by calling code
+	CountrySenders map[string]*senderPool
+	// Subaccounts are the clients of the Twilio subaccounts of the routes
+	Subaccounts map[string]TwilioClient
+	// Metrics holds the metrics of the handler, exposed on /metrics with
+	// those of the process, nil to expose the latter only
+	Metrics *prometheus.Registry
+}
+
+// SendResponse is the body returned by /send
+type SendResponse struct {
+	RequestID  string `json:"request_id"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+	Sent       int    `json:"sent"`
+	Batched    int    `json:"batched,omitempty"`
+	Delayed    int    `json:"delayed,omitempty"`
+	Suppressed int    `json:"suppressed,omitempty"`
+	Standby    int    `json:"standby,omitempty"`
+	Filtered   int    `json:"filtered,omitempty"`
+	// Dropped is the number of alerts beyond MaxAlertsPerWebhook
+	Dropped int          `json:"dropped,omitempty"`
+	Failed  int          `json:"failed"`
+	Results []SendResult `json:"results"`
+	// Alerts tells what happened to each alert of the payload
+	Alerts []AlertOutcome `json:"alerts"`
+}
+
+// SendResult describes the outcome of the message of an alert to a receiver,
+// whose number is masked
+type SendResult struct {
+	Receiver  string `json:"receiver"`
+	Alertname string `json:"alertname,omitempty"`
+	Sid       string `json:"sid,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Segments  int    `json:"segments,omitempty"`
+	Body      string `json:"body,omitempty"`
+	// Fallback is set when a WhatsApp message failed and was sent as SMS
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// NewMOptionsWithHandler returns a OptionsWithHandler for http requests
+// with shared credentials
+func NewMOptionsWithHandler(o *Config) OptionsWithHandler {
+	addSecrets(o.AuthToken, o.AWSCredentials.SecretAccessKey, o.AWSCredentials.SessionToken)
+	addSecrets(o.WebhookSecrets...)
+	addSecrets(o.AdminToken, o.AdminPassword)
+	addSecrets(o.KafkaPassword)
+	addURLSecrets(o.NATSURL)
+	addURLSecrets(o.LeaderRedisURL)
+	addURLSecrets(o.AlertmanagerURL)
+	if o.TwilioProxy != nil {
+		addURLSecrets(o.TwilioProxy.String())
+	}
+	transport := newTwilioTransport(o)
+	// the limit is shared by the clients of the subaccounts
+	var twilioLimiter *sendLimiter
+	if o.TwilioMaxConcurrent > 0 {
+		twilioLimiter = newSendLimiter(o.TwilioMaxConcurrent)
+	}
+	// the rate limits apply to the whole account
+	throttle := newTwilioThrottle()
+	newClient := func(subaccount string) TwilioClient {
+		client := newTwilioHTTPClient(o, transport)
+		client.Subaccount = subaccount
+		var limited TwilioClient = client
+		if twilioLimiter != nil {
+			limited = limitedTwilioClient{client, twilioLimiter}
+		}
+		return newRetryingTwilioClient(throttledTwilioClient{limited, throttle}, o.Retry)
+	}
+
+	m := OptionsWithHandler{
+		Options:        o,
+		Throttle:       throttle,
+		Client:         newClient(""),
+		Events:         newEventBroker(),
+		DeadLetters:    newDeadLetters(),
+		Notified:       newNotifiedAlerts(),
+		Senders:        newSenderPool(SplitList(o.Sender)),
+		CountrySenders: make(map[string]*senderPool),
+		Subaccounts:    make(map[string]TwilioClient),
+	}
+	if o.StatusCallbackURL != "" {
+		m.Deliveries = newDeliveryTracker()
+	}
+	if o.JWTJWKSURL != "" {
+		m.JWT = newJWTVerifier(o.JWTJWKSURL, o.JWTIssuer, o.JWTAudience)
+	}
+	if o.MaxConcurrentSends > 0 {
+		m.Limiter = newSendLimiter(o.MaxConcurrentSends)
+	}
+	for code, senders := range o.CountrySenders {
+		m.CountrySenders[code] = newSenderPool(senders)
+	}
+	for _, r := range o.Routes {
+		if r.AccountSid != "" && m.Subaccounts[r.AccountSid] == nil {
+			m.Subaccounts[r.AccountSid] = newClient(r.AccountSid)
+		}
+		if r.delay > 0 && m.Delayer == nil {
+			m.Delayer = newDelayer()
+		}
+	}
+	if o.LeaderRedisURL != "" {
+		leader, err := newLeaderElection(o.LeaderRedisURL, o.LeaderLeaseKey, o.LeaderID, o.LeaderLeaseDuration)
+		if err != nil {
+			log.Errorf("Error electing the leader: %v", err)
+		} else {
+			m.Leader = leader
+			m.Leader.start()
+		}
+	}
+	if o.FlapThreshold > 0 {
+		m.Flaps = newFlapDetector(o.FlapThreshold, o.FlapWindow)
+	}
+	if o.ForDuration > 0 && m.Delayer == nil {
+		m.Delayer = newDelayer()
+	}
+	if o.HistorySize > 0 {
+		m.History = newMessageHistory(o.HistorySize)
+	}
+	if o.IdempotencyTTL > 0 {
+		m.Idempotency = newIdempotencyCache(o.IdempotencyTTL)
+	}
+	if o.PayloadCaptureSize > 0 {
+		m.Payloads = newPayloadRing(o.PayloadCaptureSize)
+	}
+	if o.AuditLogFile != "" {
+		audit, err := openAuditLog(o.AuditLogFile)
+		if err != nil {
+			log.Errorf("Error opening the audit log: %v", err)
+		} else {
+			m.Audit = audit
+		}
+	}
+	if o.TemplateDir != nil {
+		o.TemplateDir.reloaded = func(err error) {
+			after := map[string]interface{}{"template_dir": o.TemplateDir.path, "status": "reloaded"}
+			if err != nil {
+				after["status"] = "failed"
+				after["error"] = err.Error()
+			}
+			m.auditSystem("templates.reload", nil, after)
+		}
+		o.TemplateDir.start()
+	}
+	if o.BatchInterval > 0 {
+		m.Batcher = newBatcher(o.BatchInterval, func(receiver, text string) error {
+			return m.sendDigest(receiver, text)
+		})
+		m.Batcher.start()
+	}
+	if o.Budget > 0 || o.BudgetAmount > 0 {
+		m.Budget = newBudget(o.Budget, o.BudgetAmount)
+	}
+	if o.StormThreshold > 0 {
+		m.Storm = newStormGuard(o.StormThreshold, o.StormWindow, func(receiver, text string) error {
+			m.auditSystem("silence.end", nil, map[string]interface{}{"reason": "storm", "receiver": maskNumber(receiver)})
+			_, err := m.sendMessage(m.Client, log.WithField("storm", true), "", receiver, text)
+			return err
+		})
+		m.Storm.start()
+	}
+	if o.HeartbeatReceiver != "" && o.HeartbeatInterval > 0 {
+		m.Heartbeat = newHeartbeat(o.HeartbeatInterval, func() error {
+			_, err := m.sendMessage(m.Client, log.WithField("heartbeat", true), "", o.HeartbeatReceiver, heartbeatText)
+			return err
+		})
+		m.Heartbeat.start()
+	}
+	if o.WatchdogTimeout > 0 {
+		m.Watchdog = newWatchdog(o.WatchdogTimeout, func(text string) error {
+			logger := log.WithField("watchdog", true)
+			for _, receiver := range SplitList(o.WatchdogReceiver) {
+				if _, err := m.sendMessage(m.Client, logger, "", receiver, text); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	var notifiers []notifier
+	if o.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, newPagerDuty(o.PagerDutyURL, o.PagerDutyRoutingKey))
+	}
+	if o.OpsgenieAPIKey != "" {
+		notifiers = append(notifiers, newOpsgenie(o.OpsgenieURL, o.OpsgenieAPIKey))
+	}
+	if len(notifiers) > 0 {
+		m.Notifiers = newNotifiers(notifiers...)
+	}
+	if len(o.ForwardURLs) > 0 {
+		m.Forwarder = newForwarder(o.ForwardURLs, forwardWorkers, forwardQueueSize)
+		m.Forwarder.start()
+	}
+	if o.PingURL != "" {
+		m.Pinger = newPinger(o.PingURL, pingMinInterval)
+	}
+	if o.NATSURL != "" {
+		subscriber, err := newNATSSubscriber(o.NATSURL, o.NATSSubject, o.NATSQueue, func(payload []byte) {
+			m.processPayload("nats", payload)
+		})
+		if err != nil {
+			log.Errorf("Error subscribing to NATS: %v", err)
+		} else {
+			m.NATS = subscriber
+			m.NATS.start()
+		}
+	}
+	if o.SQSQueueURL != "" {
+		region := o.SQSRegion
+		if region == "" {
+			region = sqsRegion(o.SQSQueueURL)
+		}
+		m.SQS = newSQSPoller(o.SQSQueueURL, region, o.AWSCredentials, o.SQSBatchSize, o.SQSVisibilityTimeout, func(payload []byte) int {
+			return m.processPayload("sqs", payload)
+		})
+		m.SQS.start()
+	}
+	if len(o.KafkaBrokers) > 0 {
+		m.Kafka = newKafkaConsumer(o.KafkaBrokers, o.KafkaTopic, o.KafkaGroup, o.KafkaTLS, o.KafkaUsername, o.KafkaPassword, func(payload []byte) int {
+			return m.processPayload("kafka", payload)
+		})
+		m.Kafka.start()
+	}
+	if o.GRPCListenAddr != "" {
+		creds, err := credentials.NewServerTLSFromFile(o.GRPCTLSCert, o.GRPCTLSKey)
+		if err == nil {
+			m.GRPC, err = newGRPCServer(o.GRPCListenAddr, creds, func(ctx context.Context, request *alertspb.SendAlertRequest) (*alertspb.SendAlertResponse, error) {
+				return m.sendAlert(ctx, request)
+			})
+		}
+		if err != nil {
+			log.Errorf("Error serving gRPC on %s: %v", o.GRPCListenAddr, err)
+		} else {
+			m.GRPC.start()
+		}
+	}
+	if o.ArchiveURL != "" {
+		target, err := parseArchiveURL(o.ArchiveURL, o.ArchiveRegion, o.ArchiveEndpoint)
+		if err != nil {
+			log.Errorf("Error archiving to %s: %v", o.ArchiveURL, err)
+		} else {
+			m.Archiver = newArchiver(target, o.AWSCredentials, o.ArchiveInterval, o.ArchiveRetention, m.History, m.Payloads)
+			m.Archiver.start()
+		}
+	}
+	if o.StateFile != "" {
+		state, err := loadState(o.StateFile)
+		if err != nil {
+			log.Errorf("Error loading state, starting afresh: %v", err)
+		} else if state != nil {
+			m.restoreState(state)
+			m.auditSystem("state.restore", nil, map[string]interface{}{"state_file": o.StateFile, "storms": len(state.Storms), "flaps": len(state.Flaps)})
+		}
+		m.State = newStateSaver(o.StateFile, o.StateSaveInterval, m.snapshotState)
+		m.State.start()
+	}
+	m.Metrics = newHandlerMetrics(m.Throttle, m.Batcher, m.Delayer)
+	return m
+}
+
+// Stop sends the delayed and batched messages and stops the background work,
+// once the server serving the handler is stopped
+func (m OptionsWithHandler) Stop() {
+	if m.GRPC != nil {
+		m.GRPC.Stop()
+	}
+	if m.NATS != nil {
+		m.NATS.Stop()
+	}
+	if m.SQS != nil {
+		m.SQS.Stop()
+	}
+	if m.Kafka != nil {
+		m.Kafka.Stop()
+	}
+	if m.Delayer != nil {
+		m.Delayer.Stop()
+	}
+	if m.Batcher != nil {
+		m.Batcher.Stop()
+	}
+	if m.Storm != nil {
+		m.Storm.Stop()
+	}
+	if m.Forwarder != nil {
+		m.Forwarder.Stop()
+	}
+	if m.Notifiers != nil {
+		m.Notifiers.Stop()
+	}
+	if m.Heartbeat != nil {
+		m.Heartbeat.Stop()
+	}
+	if m.Archiver != nil {
+		m.Archiver.Stop()
+	}
+	if m.Watchdog != nil {
+		m.Watchdog.Stop()
+	}
+	if m.State != nil {
+		m.State.Stop()
+	}
+	if m.Leader != nil {
+		m.Leader.Stop()
+	}
+	if m.Audit != nil {
+		m.Audit.Close()
+	}
+	if m.Options.TemplateDir != nil {
+		m.Options.TemplateDir.Stop()
+	}
+}
+
+// operationalPath reports whether a path is served on ADMIN_PORT when set:
+// the health checks, the API description and the admin paths
+func operationalPath(path string) bool {
+	return path == "/" || path == "/v1/health" || path == "/openapi.json" || adminPath(path)
+}
+
+// HandleWebhooks serves the webhooks and the ping, for the public port when
+// the operational endpoints are served on ADMIN_PORT
+func (m OptionsWithHandler) HandleWebhooks(ctx *fasthttp.RequestCtx) {
+	if path := string(ctx.Path()); path != "/" && operationalPath(path) {
+		writeProblem(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	m.HandleFastHTTP(ctx)
+}
+
+// HandleAdmin serves the operational endpoints on ADMIN_PORT
+func (m OptionsWithHandler) HandleAdmin(ctx *fasthttp.RequestCtx) {
+	if !operationalPath(string(ctx.Path())) {
+		writeProblem(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	m.HandleFastHTTP(ctx)
+}
+
+// HandleFastHTTP is the router function
+func (m OptionsWithHandler) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
+	path := string(ctx.Path())
+	if adminPath(path) && !m.adminAuthorized(ctx) {
+		m.rejectAdminUnauthorized(ctx)
+		return
+	}
+
+	switch path {
+	case "/":
+		m.ping(ctx)
+	case "/v1/health":
+		m.health(ctx)
+	case "/send", "/v1/send", "/cloudevents":
+		if !m.authorized(ctx) {
+			rejectUnauthorized(ctx)
+			return
+		}
+		if m.Limiter != nil {
+			if m.Limiter.acquire() {
+				defer m.Limiter.release()
+			} else if !m.urgent(ctx) {
+				rejectSaturated(ctx)
+				return
+			}
+		}
+		if m.queueFull() && !m.urgent(ctx) {
+			m.rejectQueueFull(ctx)
+			return
+		}
+		m.send(ctx)
+		if m.Pinger != nil && ctx.Response.StatusCode() < fasthttp.StatusMultipleChoices {
+			m.Pinger.ping()
+		}
+	case "/status", "/v1/status":
+		if m.Deliveries == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.statusCallback(ctx)
+	case "/openapi.json":
+		m.serveOpenAPI(ctx)
+	case "/metrics":
+		m.writeMetrics(ctx)
+	case "/events":
+		if m.Events == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.streamEvents(ctx)
+	case "/ui":
+		m.dashboard(ctx)
+	case "/admin/messages", "/v1/messages":
+		if m.History == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.listMessages(ctx)
+	case "/messages/export", "/admin/messages/export":
+		if m.History == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.exportMessages(ctx)
+	case "/admin/heartbeat":
+		if m.Options.HeartbeatReceiver == "" {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.sendHeartbeat(ctx)
+	case "/admin/replay":
+		if m.DeadLetters == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.replayFailed(ctx)
+	case "/admin/payloads":
+		if m.Payloads == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.listPayloads(ctx)
+	case "/admin/audit":
+		if m.Audit == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.listAudit(ctx)
+	case "/admin/payloads/replay":
+		if m.Payloads == nil {
+			writeProblem(ctx, fasthttp.StatusNotFound, "")
+			return
+		}
+		m.replayPayload(ctx)
+	default:
+		if pprofPath(path) {
+			m.profile(ctx)
+			return
+		}
+		writeProblem(ctx, fasthttp.StatusNotFound, "")
+	}
+}
+
+// send handles the webhooks, replaying the responses of duplicate ones
+func (m OptionsWithHandler) send(ctx *fasthttp.RequestCtx) {
+	if err := decodeBody(ctx); err == errBodyTooLarge {
+		writeProblem(ctx, fasthttp.StatusRequestEntityTooLarge, err.Error())
+		return
+	} else if err != nil {
+		writeProblem(ctx, fasthttp.StatusBadRequest, "invalid gzip body: "+err.Error())
+		return
+	}
+	if err := unwrapCloudEvent(ctx); err != nil {
+		writeProblem(ctx, fasthttp.StatusBadRequest, "invalid CloudEvent: "+err.Error())
+		return
+	}
+	if m.Payloads != nil && ctx.IsPost() {
+		m.Payloads.add(ctx)
+	}
+	if m.Watchdog != nil && ctx.IsPost() {
+		m.Watchdog.kick()
+	}
+	// dry runs send nothing, so their responses are neither cached nor
+	// taken from the cache
+	if m.Idempotency != nil && ctx.IsPost() && !ctx.QueryArgs().GetBool("dry_run") {
+		key := string(ctx.Request.Header.Peek(idempotencyHeader))
+		if key == "" {
+			key = notificationKey(ctx.PostBody())
+		}
+		if key != "" {
+			key = requestKey(key, ctx.QueryArgs())
+			if m.Idempotency.do(key, ctx, m.forwardAndSend) {
+				requestLogger(ctx).Infof("Replaying the response of duplicate request %s", key)
+				if m.Events != nil {
+					m.Events.publish("duplicate", map[string]string{"request_id": requestID(ctx), "key": key})
+				}
+			}
+			return
+		}
+	}
+	m.forwardAndSend(ctx)
+}
+
+// forwardAndSend forwards the payload of a request which isn't a dry run and
+// sends its alerts, once send checked that it isn't a duplicate
+func (m OptionsWithHandler) forwardAndSend(ctx *fasthttp.RequestCtx) {
+	if m.Forwarder != nil && ctx.IsPost() && !ctx.QueryArgs().GetBool("dry_run") {
+		m.Forwarder.forward(requestID(ctx), string(ctx.Request.Header.ContentType()), ctx.PostBody())
+	}
+	m.sendRequest(ctx)
+}
+
+// SendPayload sends the alerts of an Alertmanager payload as /send does with
+// the query string, returning the status code and body of its response
+func (m OptionsWithHandler) SendPayload(payload []byte, query string) (int, []byte) {
+	ctx := newPayloadCtx(payload, query)
+	WithRequestID(RecoverPanics(m.send))(ctx)
+	return ctx.Response.StatusCode(), ctx.Response.Body()
+}
+
+// SendMessage sends a text message to the receiver
+func (m OptionsWithHandler) SendMessage(receiver, body string) (*SendResult, error) {
+	return m.sendMessage(m.Client, log.WithField("receiver", maskNumber(receiver)), "", receiver, truncateMessage(m.Options, body, ""))
+}
+
+// CheckCredentials fetches the Twilio account of the credentials
+func (m OptionsWithHandler) CheckCredentials() (*TwilioAccount, error) {
+	return newTwilioHTTPClient(m.Options, newTwilioTransport(m.Options)).Account()
+}
+
+// newPayloadCtx returns a request to /send of the payload
+func newPayloadCtx(payload []byte, query string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/send?" + query)
+	ctx.Request.Header.SetContentType("application/json")
+	ctx.Request.SetBody(payload)
+	return ctx
+}
+
+// processPayload sends the alerts of a payload received from a broker
+// rather than on /send, returning the status code of the response /send
+// would have returned
+func (m OptionsWithHandler) processPayload(source string, payload []byte) int {
+	ctx := newPayloadCtx(payload, "")
+	WithRequestID(RecoverPanics(m.send))(ctx)
+
+	logger := requestLogger(ctx).WithField("source", source)
+	code := ctx.Response.StatusCode()
+	if code >= fasthttp.StatusMultipleChoices {
+		logger.Errorf("Error processing payload: %d %s", code, ctx.Response.Body())
+	} else {
+		logger.Infof("Processed payload")
+	}
+	return code
+}
+
+func (m OptionsWithHandler) ping(ctx *fasthttp.RequestCtx) {
+	fmt.Fprint(ctx, "ping")
+}
+
+// HealthResponse is the response of /v1/health
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	// Standby is true for the instance of an active/standby pair which
+	// doesn't send messages
+	Standby bool `json:"standby,omitempty"`
+}
+
+// health describes the instance
+func (m OptionsWithHandler) health(ctx *fasthttp.RequestCtx) {
+	response := HealthResponse{
+		Status:  "ok",
+		Version: version,
+		Commit:  commit,
+		Standby: m.Leader != nil && !m.Leader.leading(),
+	}
+	ctx.SetContentType("application/json")
+	if err := json.NewEncoder(ctx).Encode(response); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
+
+func (m OptionsWithHandler) sendRequest(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+	} else {
+		if string(ctx.Request.Header.Peek("Content-Type")) != "application/json" {
+			writeProblem(ctx, fasthttp.StatusNotAcceptable, "Content-Type must be application/json")
+		} else {
+			body := ctx.PostBody()
+			meta := parsePayloadMeta(body)
+
+			logger := requestLogger(ctx)
+			receivers, r, err := requestReceivers(m.Options, ctx.QueryArgs(), meta.Receiver, time.Now())
+			if err != nil {
+				writeProblem(ctx, fasthttp.StatusBadRequest, err.Error())
+				logger.Errorf("Bad request: %v", err)
+				return
+			}
+			if len(receivers) == 0 {
+				writeProblem(ctx, fasthttp.StatusBadRequest, "receiver not specified")
+				logger.Error("Bad request: receiver not specified")
+				return
+			}
+
+			job := &sendJob{
+				events:    m.Events,
+				history:   m.History,
+				route:     r,
+				client:    m.clientFor(r),
+				logger:    logger,
+				meta:      meta,
+				receivers: receivers,
+				response: SendResponse{
+					RequestID: requestID(ctx),
+					DryRun:    ctx.QueryArgs().GetBool("dry_run"),
+					Results:   []SendResult{},
+					Alerts:    []AlertOutcome{},
+				},
+			}
+
+			if m.Delayer != nil {
+				job.cancelled = m.cancelDelayed(logger, body)
+			}
+
+			var selected []selectedAlert
+			_, err = jsonparser.ArrayEach(body, func(alert []byte, dataType jsonparser.ValueType, offset int, err error) {
+				job.addAlert(alert)
+				m.notify(job, alert)
+				status := meta.status(alert)
+				var flap flapStatus
+				if m.Flaps != nil {
+					flap = m.Flaps.observe(alertKey(alert), status)
+				}
+				if status == "firing" || (status == "resolved" && m.Options.SendResolved) || flap.started {
+					selected = append(selected, selectedAlert{alert, flap})
+				} else {
+					job.skip(alert, outcomeSkipped, status)
+				}
+			}, "alerts")
+			sortBySeverity(meta, selected)
+			var dropped []selectedAlert
+			if max := m.Options.MaxAlertsPerWebhook; max > 0 && len(selected) > max {
+				selected, dropped = selected[:max], selected[max:]
+				job.response.Dropped = len(dropped)
+				alertsDroppedTotal.Add(float64(len(dropped)))
+				for _, a := range dropped {
+					job.skip(a.raw, outcomeDropped, "max_alerts")
+				}
+			}
+			// the last message tells the alerts left out, by the cap or by
+			// Alertmanager
+			more := job.response.Dropped + meta.TruncatedAlerts
+			for i, a := range selected {
+				if i < len(selected)-1 {
+					m.processAlert(job, a.raw, a.flap, 0)
+				} else {
+					m.processAlert(job, a.raw, a.flap, more)
+				}
+			}
+			if len(dropped) > 0 {
+				m.deliverAll(job, nil, truncateMessage(m.Options, summarizeAlerts(meta, dropped), ""))
+			}
+			job.wg.Wait()
+			job.finishOutcomes()
+			if err != nil {
+				logger.Warnf("Error parsing json: %v", err)
+			}
+
+			response := job.response
+			if response.Failed > 0 {
+				if len(response.Results) > response.Failed {
+					ctx.SetStatusCode(fasthttp.StatusMultiStatus)
+				} else {
+					ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+				}
+			}
+			ctx.SetContentType("application/json")
+			if err := json.NewEncoder(ctx).Encode(response); err != nil {
+				logger.Errorf("Error writing response: %v", err)
+			}
+		}
+	}
+}
+
+// selectedAlert is an alert of a payload whose message is to be sent
+type selectedAlert struct {
+	raw  []byte
+	flap flapStatus
+}
+
+// sendJob is a /send request being processed
+type sendJob struct {
+	events    *eventBroker
+	history   *messageHistory
+	route     *Route
+	client    TwilioClient
+	logger    *log.Entry
+	meta      *PayloadMeta
+	receivers []string
+	// cancelled are the keys of the resolved alerts whose delayed messages
+	// were dropped
+	cancelled map[string]bool
+	// alerts indexes the alerts of response.Alerts by key
+	alerts map[string]int
+
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	response SendResponse
+}
+
+// detach returns a copy of the job for the messages sent once its response
+// is written, with the payload meta copied out of the request buffers
+func (j *sendJob) detach() *sendJob {
+	meta := *j.meta
+	meta.CommonLabels = append([]byte(nil), j.meta.CommonLabels...)
+	meta.CommonAnnotations = append([]byte(nil), j.meta.CommonAnnotations...)
+	return &sendJob{
+		events:    j.events,
+		history:   j.history,
+		route:     j.route,
+		client:    j.client,
+		logger:    j.logger,
+		meta:      &meta,
+		receivers: j.receivers,
+		response:  SendResponse{RequestID: j.response.RequestID, DryRun: j.response.DryRun},
+	}
+}
+
+// clientFor returns the Twilio client of the route, the default one when
+// the route is nil or doesn't use a subaccount
+func (m OptionsWithHandler) clientFor(r *Route) TwilioClient {
+	if r != nil {
+		if client, ok := m.Subaccounts[r.AccountSid]; ok {
+			return client
+		}
+	}
+	return m.Client
+}
+
+// record adds the outcome of the message of an alert to a receiver to the
+// response
+func (j *sendJob) record(alert []byte, receiver string, result *SendResult, err error) {
+	if err != nil {
+		result = &SendResult{Status: "failed", Error: redact(err.Error())}
+	}
+	if result.Receiver == "" {
+		result.Receiver = receiver
+	}
+	result.Receiver = maskNumber(result.Receiver)
+	result.Alertname = j.meta.label(alert, "alertname")
+	if j.history != nil && !j.response.DryRun && result.Status != "standby" {
+		j.history.add(j.response.RequestID, *result)
+	}
+	if j.events != nil && !j.response.DryRun {
+		j.events.publish(eventType(result), HistoryEntry{Time: time.Now().UTC(), RequestID: j.response.RequestID, SendResult: *result})
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if alert != nil {
+		j.setOutcome(alert, eventType(result))
+	}
+	switch {
+	case err != nil:
+		j.response.Failed++
+		j.response.Results = append(j.response.Results, *result)
+	case result.Status == "batched":
+		j.response.Batched++
+		j.response.Results = append(j.response.Results, *result)
+	case result.Status == "delayed":
+		j.response.Delayed++
+		j.response.Results = append(j.response.Results, *result)
+	case result.Status == "suppressed":
+		j.response.Suppressed++
+		j.response.Results = append(j.response.Results, *result)
+	case result.Status == "standby":
+		j.response.Standby++
+		j.response.Results = append(j.response.Results, *result)
+	default:
+		j.response.Sent++
+		j.response.Results = append(j.response.Results, *result)
+	}
+}
+
+// eventType returns the type of the /events event of a result
+func eventType(result *SendResult) string {
+	switch result.Status {
+	case "failed", "batched", "suppressed", "delayed", "standby", "dry_run":
+		return result.Status
+	default:
+		return "sent"
+	}
+}
+
+// processAlert formats the message of an alert and sends it, in the
+// background, to every receiver of the job. The message of a flapping alert
+// is replaced by a flapping notice, or suppressed once it was sent. When
+// more is set, the message tells that many alerts were left out.
+func (m OptionsWithHandler) processAlert(job *sendJob, alert []byte, flap flapStatus, more int) {
+	if !m.included(job.meta, alert) {
+		alertsFilteredTotal.Inc()
+		job.mu.Lock()
+		job.response.Filtered++
+		job.mu.Unlock()
+		job.skip(alert, outcomeFiltered, "filter")
+		return
+	}
+	if optedOut(job.meta, alert) {
+		alertsOptedOutTotal.Inc()
+		job.mu.Lock()
+		job.response.Filtered++
+		job.mu.Unlock()
+		job.skip(alert, outcomeFiltered, "opt_out")
+		return
+	}
+
+	if flap.started {
+		m.auditSystem("silence.start", nil, map[string]interface{}{"reason": "flapping", "alert": alertKey(alert), "transitions": flap.transitions})
+		text := fmt.Sprintf("ALERT %s is flapping (%d transitions in %s), suppressing its notifications until it settles",
+			job.meta.label(alert, "alertname"), flap.transitions, m.Options.FlapWindow)
+		m.deliverAll(job, alert, text)
+		return
+	}
+	if flap.flapping {
+		for _, receiver := range job.receivers {
+			messagesSuppressedTotal.WithLabelValues("flapping").Inc()
+			job.record(alert, receiver, &SendResult{Status: "suppressed"}, nil)
+		}
+		return
+	}
+
+	if job.cancelled[alertKey(alert)] {
+		job.skip(alert, outcomeSkipped, "delay_cancelled")
+		return
+	}
+	if m.Notified != nil && job.meta.status(alert) == "resolved" {
+		notified := m.Notified.contains(alertKey(alert))
+		if !job.response.DryRun {
+			notified = m.Notified.take(alertKey(alert))
+		}
+		if !notified {
+			job.logger.Debugf("Alert %s resolved without having been notified", alertKey(alert))
+			job.skip(alert, outcomeSkipped, "not_notified")
+			return
+		}
+	}
+
+	text, err := m.format(job.meta, alert)
+	if err != nil {
+		job.logger.Errorf("Bad format: %v", err)
+		job.skip(alert, outcomeSkipped, "format")
+		return
+	}
+	if text == "" {
+		job.logger.Error("Bad format")
+		job.skip(alert, outcomeSkipped, "empty_message")
+		return
+	}
+	text = truncateMessage(m.Options, text, moreAlerts(more))
+
+	priority := m.priority(job.meta, alert)
+	batch := m.Batcher != nil && !priority && !contains(m.Options.BatchBypassSeverities, job.meta.label(alert, "severity"))
+	// later records the delayed messages, sent once the response is written
+	var later *sendJob
+	for _, receiver := range job.receivers {
+		if batch {
+			if m.queueFull() {
+				queueDroppedTotal.WithLabelValues("batch").Inc()
+				job.record(alert, receiver, nil, errQueueFull)
+				continue
+			}
+			if !job.response.DryRun {
+				m.Batcher.add(receiver, job.meta.status(alert), text)
+				m.notified(job, alert)
+			}
+			job.record(alert, receiver, &SendResult{Status: "batched", Body: text}, nil)
+			continue
+		}
+
+		if delay := m.delay(job); delay > 0 && job.meta.status(alert) == "firing" && !job.response.DryRun {
+			if m.queueFull() && !priority {
+				queueDroppedTotal.WithLabelValues("delay").Inc()
+				job.record(alert, receiver, nil, errQueueFull)
+				continue
+			}
+			if later == nil {
+				later = job.detach()
+				alert = append([]byte(nil), alert...)
+			}
+			receiver, alert := receiver, alert
+			m.Delayer.schedule(alertKey(alert), delay, func() {
+				m.deliverAndRecord(later, alert, receiver, text)
+			})
+			job.record(alert, receiver, &SendResult{Status: "delayed", Body: text}, nil)
+			continue
+		}
+
+		m.deliverTo(job, alert, receiver, text)
+	}
+}
+
+// summarizeAlerts returns a single message listing the names of alerts,
+// with their count when several share one
+func summarizeAlerts(meta *PayloadMeta, alerts []selectedAlert) string {
+	var names []string
+	counts := make(map[string]int)
+	for _, a := range alerts {
+		name := meta.label(a.raw, "alertname")
+		if counts[name] == 0 {
+			names = append(names, name)
+		}
+		counts[name]++
+	}
+	for i, name := range names {
+		if counts[name] > 1 {
+			names[i] = fmt.Sprintf("%s (x%d)", name, counts[name])
+		}
+	}
+	return strings.TrimSpace(moreAlerts(len(alerts))) + ": " + strings.Join(names, ", ")
+}
+
+// deliverAll sends the text about an alert, in the background, to every
+// receiver of the job
+func (m OptionsWithHandler) deliverAll(job *sendJob, alert []byte, text string) {
+	for _, receiver := range job.receivers {
+		m.deliverTo(job, alert, receiver, text)
+	}
+}
+
+// deliverTo sends the text about an alert to the receiver in the background
+func (m OptionsWithHandler) deliverTo(job *sendJob, alert []byte, receiver, text string) {
+	job.wg.Add(1)
+	go func() {
+		defer job.wg.Done()
+		m.deliverAndRecord(job, alert, receiver, text)
+	}()
+}
+
+// deliverAndRecord sends the text about an alert to the receiver, keeping
+// it for replay when it fails, and records the result
+func (m OptionsWithHandler) deliverAndRecord(job *sendJob, alert []byte, receiver, text string) {
+	result, err := m.deliver(job, receiver, text, alert)
+	if err != nil && m.DeadLetters != nil {
+		m.DeadLetters.add(job.client, job.response.RequestID, receiver, text)
+	}
+	job.record(alert, receiver, result, err)
+}
+
+// sendDigest sends the digest of the batched messages of a receiver, keeping
+// it for replay when it fails, and records the result
+func (m OptionsWithHandler) sendDigest(receiver, text string) error {
+	// the digest isn't part of a request, it's recorded on a job of its own
+	job := &sendJob{events: m.Events, history: m.History, meta: &PayloadMeta{}}
+	text = truncateMessage(m.Options, text, "")
+	result, err := m.sendMessage(m.Client, log.WithField("digest", true), "", receiver, text)
+	if err != nil && m.DeadLetters != nil {
+		m.DeadLetters.add(m.Client, "", receiver, text)
+	}
+	job.record(nil, receiver, result, err)
+	return err
+}
+
+// delay returns how long the messages of the firing alerts of the job are
+// held back: the delay of its route, or else FOR_DURATION
+func (m OptionsWithHandler) delay(job *sendJob) time.Duration {
+	if job.route != nil && job.route.delay > 0 {
+		return job.route.delay
+	}
+	return m.Options.ForDuration
+}
+
+// cancelDelayed drops the delayed messages of the resolved alerts of the
+// payload and returns their keys
+func (m OptionsWithHandler) cancelDelayed(logger *log.Entry, payload []byte) map[string]bool {
+	cancelled := make(map[string]bool)
+	jsonparser.ArrayEach(payload, func(alert []byte, dataType jsonparser.ValueType, offset int, err error) {
+		if status, _ := jsonparser.GetString(alert, "status"); status != "resolved" {
+			return
+		}
+		if key := alertKey(alert); m.Delayer.cancel(key) {
+			logger.Infof("Alert %s resolved within its delay, its messages are dropped", key)
+			cancelled[key] = true
+		}
+	}, "alerts")
+	return cancelled
+}
+
+// included reports whether an alert passes the FILTER_INCLUDE and
+// FILTER_EXCLUDE matchers
+func (m OptionsWithHandler) included(meta *PayloadMeta, alert []byte) bool {
+	label := func(name string) string { return meta.label(alert, name) }
+	if len(m.Options.FilterInclude) > 0 && !matchAll(m.Options.FilterInclude, label) {
+		return false
+	}
+	return !matchAll(m.Options.FilterExclude, label)
+}
+
+// notified remembers a firing alert as notified
+func (m OptionsWithHandler) notified(job *sendJob, alert []byte) {
+	if m.Notified != nil && job.meta.status(alert) == "firing" {
+		m.Notified.add(alertKey(alert))
+	}
+}
+
+// optedOut reports whether the rule of the alert opted out of text
+// messages with a sms: "false" or sms_skip: "true" annotation
+func optedOut(meta *PayloadMeta, alert []byte) bool {
+	return meta.annotation(alert, "sms") == "false" || meta.annotation(alert, "sms_skip") == "true"
+}
+
+// deliver sends the message of an alert of the job to the receiver, or only
+// describes it on dry runs
+func (m OptionsWithHandler) deliver(job *sendJob, receiver, text string, alert []byte) (*SendResult, error) {
+	if job.response.DryRun {
+		return &SendResult{Receiver: receiver, Status: "dry_run", Body: text}, nil
+	}
+
+	if m.Budget != nil {
+		label := func(name string) string { return job.meta.label(alert, name) }
+		if exceeded, first := m.Budget.exceeded(); exceeded && !matchAll(m.Options.BudgetCritical, label) {
+			messagesSuppressedTotal.WithLabelValues("budget").Inc()
+			if first {
+				m.auditSystem("silence.start", nil, map[string]interface{}{"reason": "budget", "budget": describeBudget(m.Options)})
+			}
+			if first && m.Options.BudgetAdmin != "" {
+				notice := fmt.Sprintf("SMS budget of %s exceeded this month, only critical alerts are sent until the next one", describeBudget(m.Options))
+				if _, err := m.sendMessage(m.Client, job.logger, job.response.RequestID, m.Options.BudgetAdmin, notice); err != nil {
+					job.logger.Errorf("Error sending budget notice: %v", err)
+				}
+			}
+			return &SendResult{Receiver: receiver, Status: "suppressed", Body: text}, nil
+		}
+	}
+
+	if m.Storm != nil && !m.priority(job.meta, alert) {
+		if ok, started := m.Storm.allow(receiver); !ok {
+			messagesSuppressedTotal.WithLabelValues("storm").Inc()
+			if started {
+				m.auditSystem("silence.start", nil, map[string]interface{}{"reason": "storm", "receiver": maskNumber(receiver)})
+				notice := fmt.Sprintf("Alert storm: more than %d alerts in %s, suppressing alerts until it ends, see Alertmanager", m.Options.StormThreshold, m.Options.StormWindow)
+				if job.meta.ExternalURL != "" {
+					notice += " " + job.meta.ExternalURL
+				}
+				if _, err := m.sendMessage(job.client, job.logger, job.response.RequestID, receiver, notice); err != nil {
+					job.logger.Errorf("Error sending alert storm notice: %v", err)
+				}
+			}
+			return &SendResult{Receiver: receiver, Status: "suppressed", Body: text}, nil
+		}
+	}
+
+	result, err := m.sendMessage(job.client, job.logger, job.response.RequestID, receiver, text)
+	if err == nil {
+		m.notified(job, alert)
+	}
+	if err != nil && strings.HasPrefix(receiver, whatsAppPrefix) {
+		job.logger.Warnf("WhatsApp message to %s failed, sending it as SMS", maskNumber(receiver))
+		whatsAppFallbacksTotal.Inc()
+		result, err = m.sendMessage(job.client, job.logger, job.response.RequestID, strings.TrimPrefix(receiver, whatsAppPrefix), text)
+		if result != nil {
+			result.Fallback = true
+			m.notified(job, alert)
+		}
+	}
+	return result, err
+}
+
+// whatsAppPrefix marks the receivers messaged on WhatsApp instead of SMS
+const whatsAppPrefix = "whatsapp:"
+
+// sender returns the number messages are sent to the receiver from
+func (m OptionsWithHandler) sender(receiver string) string {
+	if strings.HasPrefix(receiver, whatsAppPrefix) {
+		if m.Options.WhatsAppSender != "" {
+			return whatsAppPrefix + m.Options.WhatsAppSender
+		}
+		return whatsAppPrefix + m.sender(strings.TrimPrefix(receiver, whatsAppPrefix))
+	}
+	if code := countryCode(m.CountrySenders, receiver); code != "" {
+		return m.CountrySenders[code].pick(receiver)
+	}
+	if m.Senders == nil {
+		return m.Options.Sender
+	}
+	return m.Senders.pick(receiver)
+}
+
+// sendMessage sends a text message to the receiver with the client, unless
+// this instance is a standby
+func (m OptionsWithHandler) sendMessage(client TwilioClient, logger *log.Entry, requestID, receiver, body string) (*SendResult, error) {
+	if m.Leader != nil && !m.Leader.leading() {
+		logger.Infof("Standby, not sending the message to %s", maskNumber(receiver))
+		return &SendResult{Receiver: receiver, Status: "standby"}, nil
+	}
+
+	message := Message{
+		From:           m.sender(receiver),
+		To:             receiver,
+		Body:           body,
+		RequestID:      requestID,
+		StatusCallback: m.Options.StatusCallbackURL,
+	}
+	receipt, err := client.SendMessage(&message)
+	if err != nil {
+		messagesFailedTotal.Inc()
+		logger.Error(err)
+		return nil, err
+	}
+
+	messagesSentTotal.Inc()
+	if m.Budget != nil {
+		m.Budget.spend(messageCost(receipt, m.Options.BudgetSegmentPrice))
+	}
+	if m.Deliveries != nil && receipt.Sid != "" {
+		m.Deliveries.track(receipt.Sid, trackedMessage{Message: message, client: client})
+	}
+	logger.Infof("Message %s %s", receipt.Sid, receipt.Status)
+	return &SendResult{
+		Receiver: receiver,
+		Sid:      receipt.Sid,
+		Status:   receipt.Status,
+		Segments: receipt.Segments,
+	}, nil
+}
+
+func findAndReplaceLables(body string, alert []byte) string {
+	labelReg := regexp.MustCompile(`\$labels.[a-z]+`)
+	matches := labelReg.FindAllString(body, -1)
+
+	if matches != nil {
+		for _, match := range matches {
+			labelName := strings.Split(match, ".")
+			if len(labelName) == 2 {
+				replaceWith, _ := jsonparser.GetString(alert, "labels", labelName[1])
+				body = strings.Replace(body, match, replaceWith, -1)
+			}
+		}
+	}
+
+	return body
+}
@@ -0,0 +1,525 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+func TestFindAndReplaceLables(t *testing.T) {
+	alert := []byte(`
+      {
+        "status": "firing",
+        "labels": {
+          "alertname": "InstanceDown",
+          "instance": "http://test.com",
+          "job": "blackbox"
+        },
+        "annotations": {
+          "description": "Unable to scrape $labels.instance",
+          "summary": "Address $labels.instance appears to be down"
+        },
+        "startsAt": "2017-01-06T19:34:52.887Z",
+        "endsAt": "0001-01-01T00:00:00Z",
+        "generatorURL": "http://test.com/graph?g0.expr=probe_success%7Bjob%3D%22blackbox%22%7D+%3D%3D+0&g0.tab=0"
+      }
+    `)
+
+	input := "Address $labels.instance appears to be down with $labels.alertname"
+	expected := "Address http://test.com appears to be down with InstanceDown"
+	output := findAndReplaceLables(input, alert)
+
+	if output != expected {
+		t.Errorf("findAndReplaceLables(%q, alert) == %q, want %q", input, output, expected)
+	}
+}
+
+// fakeTwilioClient records the messages it is asked to send
+type fakeTwilioClient struct {
+	mu       sync.Mutex
+	messages []*Message
+	err      error
+}
+
+func (c *fakeTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.messages = append(c.messages, m)
+	return &MessageReceipt{Sid: fmt.Sprintf("SM%d", len(c.messages)), Status: "queued", Segments: 1}, nil
+}
+
+func newSendRequestCtx(uri, payload string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.SetContentType("application/json")
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.SetBodyString(payload)
+	return ctx
+}
+
+func TestSendRequest(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send?receiver=%2B300", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusOK)
+	}
+	if len(client.messages) != 1 || client.messages[0].To != "+300" || client.messages[0].Body != "Disk full" {
+		t.Fatalf("unexpected messages sent: %+v", client.messages)
+	}
+
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || len(response.Results) != 1 || response.Results[0] != (SendResult{Receiver: "+300", Sid: "SM1", Status: "queued", Segments: 1}) {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
+
+func TestSendRequestFailure(t *testing.T) {
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  &fakeTwilioClient{err: errors.New("boom")},
+	}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusInternalServerError)
+	}
+}
+
+func TestSendRequestDryRun(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send?dry_run=true", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+
+	if len(client.messages) != 0 {
+		t.Errorf("dry run sent messages: %+v", client.messages)
+	}
+
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := SendResult{Receiver: "+200", Status: "dry_run", Body: "Disk full"}
+	if !response.DryRun || response.Sent != 1 || len(response.Results) != 1 || response.Results[0] != expected {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if len(response.Alerts) != 1 || response.Alerts[0].Status != "dry_run" {
+		t.Errorf("alerts == %+v, want a dry_run outcome", response.Alerts)
+	}
+}
+
+func TestSendRequestBatched(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, BatchBypassSeverities: []string{"critical"}},
+		Client:  client,
+	}
+	m.Batcher = newBatcher(time.Hour, func(receiver, text string) error {
+		_, err := m.sendMessage(m.Client, log.WithField("digest", true), "", receiver, text)
+		return err
+	})
+	m.Batcher.start()
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"labels": {"severity": "critical"}, "annotations": {"summary": "Site down"}},
+		{"labels": {"severity": "warning"}, "annotations": {"summary": "Disk full"}},
+		{"labels": {"severity": "warning"}, "annotations": {"summary": "CPU high"}}
+	]}`)
+	m.HandleFastHTTP(ctx)
+
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || response.Batched != 2 || len(client.messages) != 1 || client.messages[0].Body != "Site down" {
+		t.Fatalf("unexpected response %+v, messages %+v", response, client.messages)
+	}
+
+	m.Batcher.Stop()
+	if len(client.messages) != 2 || client.messages[1].Body != "2 alerts (2 firing):\n- Disk full\n- CPU high" {
+		t.Errorf("unexpected digest %+v", client.messages[1:])
+	}
+}
+
+func TestSendRequestFilters(t *testing.T) {
+	include, _ := ParseMatchers(`team=~"infra|db"`)
+	exclude, _ := ParseMatchers(`env="staging"`)
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, FilterInclude: include, FilterExclude: exclude},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "commonLabels": {"team": "infra"}, "alerts": [
+		{"labels": {"env": "prod"}, "annotations": {"summary": "Prod down"}},
+		{"labels": {"env": "staging"}, "annotations": {"summary": "Staging down"}},
+		{"labels": {"team": "web"}, "annotations": {"summary": "Web down"}}
+	]}`)
+	m.HandleFastHTTP(ctx)
+
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || response.Filtered != 2 || len(client.messages) != 1 || client.messages[0].Body != "Prod down" {
+		t.Errorf("unexpected response %+v, messages %+v", response, client.messages)
+	}
+}
+
+func TestSendRequestSubaccount(t *testing.T) {
+	office := &Route{Hours: "00:00-24:00", Receivers: []string{"+300"}, AccountSid: "AC-team"}
+	if err := office.init(); err != nil {
+		t.Fatal(err)
+	}
+	client, subaccount := &fakeTwilioClient{}, &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:     &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, Routes: []*Route{office}},
+		Client:      client,
+		Subaccounts: map[string]TwilioClient{"AC-team": subaccount},
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+	if len(client.messages) != 0 || len(subaccount.messages) != 1 || subaccount.messages[0].To != "+300" {
+		t.Errorf("route didn't use its subaccount: %+v, %+v", client.messages, subaccount.messages)
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send?receiver=%2B400", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`))
+	if len(client.messages) != 1 || client.messages[0].To != "+400" {
+		t.Errorf("explicit receiver didn't use the default account: %+v", client.messages)
+	}
+}
+
+func TestSendRequestDelayed(t *testing.T) {
+	night := &Route{Receivers: []string{"+300"}, Delay: "1h"}
+	if err := night.init(); err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Annotations: []string{"summary"}, Routes: []*Route{night}, SendResolved: true},
+		Client:  client,
+		Delayer: newDelayer(),
+	}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"status": "firing", "fingerprint": "a1", "annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Delayed != 1 || len(client.messages) != 0 {
+		t.Fatalf("unexpected response %+v, messages %+v", response, client.messages)
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "resolved", "alerts": [{"status": "resolved", "fingerprint": "a1", "annotations": {"summary": "Disk full"}}]}`))
+	m.Delayer.Stop()
+	if len(client.messages) != 0 {
+		t.Errorf("messages sent for an alert resolved within its delay: %+v", client.messages)
+	}
+}
+
+// partialTwilioClient fails the messages to the receivers with a prefix
+type partialTwilioClient struct {
+	fakeTwilioClient
+	failing string
+}
+
+func (c *partialTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	if strings.HasPrefix(m.To, c.failing) {
+		return nil, &TwilioError{Status: 400, Code: 63016, Message: "Failed to send freeform message"}
+	}
+	return c.fakeTwilioClient.SendMessage(m)
+}
+
+func TestSendRequestWhatsAppFallback(t *testing.T) {
+	client := &partialTwilioClient{failing: whatsAppPrefix}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send?receiver=whatsapp:%2B200", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || response.Failed != 0 || !response.Results[0].Fallback || response.Results[0].Receiver != "+200" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if len(client.messages) != 1 || client.messages[0].To != "+200" || client.messages[0].From != "+100" {
+		t.Errorf("unexpected messages %+v", client.messages)
+	}
+}
+
+func TestSender(t *testing.T) {
+	m := OptionsWithHandler{Options: &Config{Sender: "+100"}}
+	if got := m.sender("whatsapp:+200"); got != "whatsapp:+100" {
+		t.Errorf("sender(%q) == %q, want %q", "whatsapp:+200", got, "whatsapp:+100")
+	}
+	m.Options.WhatsAppSender = "+101"
+	if got := m.sender("whatsapp:+200"); got != "whatsapp:+101" {
+		t.Errorf("sender(%q) == %q, want %q", "whatsapp:+200", got, "whatsapp:+101")
+	}
+}
+
+func TestSendRequestPartialFailure(t *testing.T) {
+	client := &partialTwilioClient{failing: "+15550002"}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send?receiver=%2B15550001,%2B15550002", `{"status": "firing", "alerts": [{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusMultiStatus {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusMultiStatus)
+	}
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || response.Failed != 1 || len(response.Results) != 2 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	for _, result := range response.Results {
+		if result.Alertname != "DiskFull" || (result.Receiver != "+****0001" && result.Receiver != "+****0002") {
+			t.Errorf("unexpected result %+v", result)
+		}
+		if result.Status == "failed" && (result.Receiver != "+****0002" || result.Error == "") {
+			t.Errorf("unexpected failure %+v", result)
+		}
+	}
+}
+
+func TestSendRequestOptOut(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"annotations": {"summary": "Disk full", "sms": "false"}},
+		{"annotations": {"summary": "CPU high", "sms_skip": "true"}},
+		{"annotations": {"summary": "Site down"}}
+	]}`)
+	m.HandleFastHTTP(ctx)
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || response.Filtered != 2 || len(client.messages) != 1 || client.messages[0].Body != "Site down" {
+		t.Errorf("unexpected response %+v, messages %+v", response, client.messages)
+	}
+}
+
+func TestSendRequestMixedStatus(t *testing.T) {
+	payload := `{"status": "firing", "alerts": [
+		{"status": "firing", "annotations": {"summary": "Disk full"}},
+		{"status": "resolved", "annotations": {"summary": "CPU high"}}
+	]}`
+	tests := []struct {
+		sendResolved bool
+		expected     []string
+	}{
+		{false, []string{"Disk full"}},
+		{true, []string{"Disk full", "RESOLVED: CPU high"}},
+	}
+	for _, test := range tests {
+		client := &fakeTwilioClient{}
+		m := OptionsWithHandler{
+			Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, SendResolved: test.sendResolved, ResolvedPrefix: "RESOLVED: "},
+			Client:  client,
+		}
+		m.HandleFastHTTP(newSendRequestCtx("/send", payload))
+
+		var bodies []string
+		for _, message := range client.messages {
+			bodies = append(bodies, message.Body)
+		}
+		sort.Strings(bodies)
+		if !reflect.DeepEqual(bodies, test.expected) {
+			t.Errorf("messages with SendResolved %v == %q, want %q", test.sendResolved, bodies, test.expected)
+		}
+	}
+}
+
+func TestSendRequestMaxAlerts(t *testing.T) {
+	tests := []struct {
+		max      int
+		payload  string
+		expected []string
+		dropped  int
+	}{
+		{2, `{"status": "firing", "alerts": [
+			{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}},
+			{"labels": {"alertname": "HighCPU"}, "annotations": {"summary": "CPU high"}},
+			{"labels": {"alertname": "SiteDown"}, "annotations": {"summary": "Site down"}},
+			{"labels": {"alertname": "SiteDown"}, "annotations": {"summary": "Site down"}},
+			{"labels": {"alertname": "LowMemory"}, "annotations": {"summary": "Memory low"}}
+		]}`, []string{"+3 more alerts: SiteDown (x2), LowMemory", "CPU high +3 more alerts", "Disk full"}, 3},
+		{0, `{"status": "firing", "truncatedAlerts": 1, "alerts": [
+			{"annotations": {"summary": "Disk full"}}
+		]}`, []string{"Disk full +1 more alert"}, 0},
+		{2, `{"status": "firing", "alerts": [
+			{"annotations": {"summary": "Disk full"}},
+			{"annotations": {"summary": "CPU high"}}
+		]}`, []string{"CPU high", "Disk full"}, 0},
+	}
+	for _, test := range tests {
+		client := &fakeTwilioClient{}
+		m := OptionsWithHandler{
+			Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, MaxAlertsPerWebhook: test.max},
+			Client:  client,
+		}
+		before := testutil.ToFloat64(alertsDroppedTotal)
+		ctx := newSendRequestCtx("/send", test.payload)
+		m.HandleFastHTTP(ctx)
+		if got := testutil.ToFloat64(alertsDroppedTotal) - before; got != float64(test.dropped) {
+			t.Errorf("alertsDroppedTotal increased by %g, want %d", got, test.dropped)
+		}
+
+		var response SendResponse
+		if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Dropped != test.dropped {
+			t.Errorf("dropped with %d alerts max == %d, want %d", test.max, response.Dropped, test.dropped)
+		}
+		var bodies []string
+		for _, message := range client.messages {
+			bodies = append(bodies, message.Body)
+		}
+		sort.Strings(bodies)
+		if !reflect.DeepEqual(bodies, test.expected) {
+			t.Errorf("messages with %d alerts max == %q, want %q", test.max, bodies, test.expected)
+		}
+	}
+}
+
+func TestSendRequestResolvedNotified(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:  &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, SendResolved: true},
+		Client:   client,
+		Notified: newNotifiedAlerts(),
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"status": "firing", "fingerprint": "a1", "annotations": {"summary": "Disk full"}}]}`))
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "resolved", "alerts": [
+		{"status": "resolved", "fingerprint": "a1", "annotations": {"summary": "Disk full"}},
+		{"status": "resolved", "fingerprint": "a2", "annotations": {"summary": "CPU high"}}
+	]}`))
+	if len(client.messages) != 2 || client.messages[1].Body != "Disk full" {
+		t.Errorf("unexpected messages %+v", client.messages)
+	}
+}
+
+func TestSendRequestForDuration(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, ForDuration: 10 * time.Millisecond},
+		Client:  client,
+		Delayer: newDelayer(),
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"status": "firing", "fingerprint": "a1", "annotations": {"summary": "Disk full"}},
+		{"status": "firing", "fingerprint": "a2", "annotations": {"summary": "CPU high"}}
+	]}`))
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "resolved", "alerts": [{"status": "resolved", "fingerprint": "a2", "annotations": {"summary": "CPU high"}}]}`))
+	time.Sleep(50 * time.Millisecond)
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.messages) != 1 || client.messages[0].Body != "Disk full" {
+		t.Errorf("unexpected messages %+v", client.messages)
+	}
+}
+
+func TestV1Routes(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  client,
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/v1/health")
+	m.HandleFastHTTP(ctx)
+	var health HealthResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &health); err != nil || health.Status != "ok" {
+		t.Errorf("/v1/health == %s, %v", ctx.Response.Body(), err)
+	}
+
+	ctx = newSendRequestCtx("/v1/send", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || len(client.messages) != 1 {
+		t.Errorf("/v1/send == %d, sent %+v", ctx.Response.StatusCode(), client.messages)
+	}
+}
+
+func TestAdminPortRoutes(t *testing.T) {
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  &fakeTwilioClient{},
+	}
+
+	tests := []struct {
+		path           string
+		webhook, admin int
+	}{
+		{"/", fasthttp.StatusOK, fasthttp.StatusOK},
+		{"/send", fasthttp.StatusOK, fasthttp.StatusNotFound},
+		{"/v1/send", fasthttp.StatusOK, fasthttp.StatusNotFound},
+		{"/metrics", fasthttp.StatusNotFound, fasthttp.StatusOK},
+		{"/v1/health", fasthttp.StatusNotFound, fasthttp.StatusOK},
+		{"/openapi.json", fasthttp.StatusNotFound, fasthttp.StatusOK},
+	}
+	for _, tt := range tests {
+		for _, port := range []struct {
+			name   string
+			handle fasthttp.RequestHandler
+			code   int
+		}{{"webhook", m.HandleWebhooks, tt.webhook}, {"admin", m.HandleAdmin, tt.admin}} {
+			ctx := newSendRequestCtx(tt.path, `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+			if tt.path != "/send" && tt.path != "/v1/send" {
+				ctx.Request.Header.SetMethod("GET")
+			}
+			port.handle(ctx)
+			if ctx.Response.StatusCode() != port.code {
+				t.Errorf("%s on the %s port == %d, want %d", tt.path, port.name, ctx.Response.StatusCode(), port.code)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package promtotwilio
+
+import "github.com/buger/jsonparser"
+
+// Outcomes of the alerts of a /send request, besides the event types of the
+// results of their messages
+const (
+	outcomeFiltered = "filtered"
+	outcomeDropped  = "dropped"
+	outcomeSkipped  = "skipped"
+)
+
+// AlertOutcome describes what happened to an alert of a /send request
+type AlertOutcome struct {
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Alertname   string `json:"alertname,omitempty"`
+	// Status is sent, batched, delayed, suppressed, standby or failed for the
+	// alerts whose messages were processed, according to the results of
+	// their receivers, dry_run for those which would have been sent, and
+	// filtered, dropped or skipped for the others
+	Status string `json:"status"`
+	// Reason tells why an alert was filtered, dropped or skipped
+	Reason string `json:"reason,omitempty"`
+}
+
+// outcomeRanks order the outcomes of the messages of an alert to its
+// receivers, the outcome of the alert being the lowest ranked one
+var outcomeRanks = map[string]int{
+	"failed":     1,
+	"sent":       2,
+	"dry_run":    2,
+	"batched":    3,
+	"delayed":    3,
+	"suppressed": 4,
+	"standby":    4,
+}
+
+// addAlert adds an alert of the payload to the breakdown of the response,
+// in the order of the payload
+func (j *sendJob) addAlert(alert []byte) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key := outcomeKey(alert)
+	if _, ok := j.alerts[key]; ok {
+		return
+	}
+	if j.alerts == nil {
+		j.alerts = make(map[string]int)
+	}
+	fingerprint, _ := jsonparser.GetString(alert, "fingerprint")
+	j.alerts[key] = len(j.response.Alerts)
+	j.response.Alerts = append(j.response.Alerts, AlertOutcome{
+		Fingerprint: fingerprint,
+		Alertname:   j.meta.label(alert, "alertname"),
+	})
+}
+
+// outcomeKey identifies an alert of the payload by its key, or else its JSON
+func outcomeKey(alert []byte) string {
+	if key := alertKey(alert); key != "" {
+		return key
+	}
+	return string(alert)
+}
+
+// skip sets the outcome of an alert whose messages aren't processed
+func (j *sendJob) skip(alert []byte, status, reason string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if i, ok := j.alerts[outcomeKey(alert)]; ok {
+		j.response.Alerts[i].Status = status
+		j.response.Alerts[i].Reason = reason
+	}
+}
+
+// setOutcome merges the outcome of the message of an alert to a receiver
+// into the one of the alert. j.mu must be held.
+func (j *sendJob) setOutcome(alert []byte, status string) {
+	i, ok := j.alerts[outcomeKey(alert)]
+	if !ok {
+		return
+	}
+	current := j.response.Alerts[i].Status
+	if rank, ok := outcomeRanks[current]; !ok || outcomeRanks[status] < rank {
+		j.response.Alerts[i].Status = status
+	}
+}
+
+// finishOutcomes marks the alerts left without an outcome as skipped
+func (j *sendJob) finishOutcomes() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := range j.response.Alerts {
+		if j.response.Alerts[i].Status == "" {
+			j.response.Alerts[i].Status = outcomeSkipped
+		}
+	}
+}
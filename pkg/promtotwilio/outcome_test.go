@@ -0,0 +1,65 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSendRequestOutcomes(t *testing.T) {
+	exclude, _ := ParseMatchers(`env="staging"`)
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, FilterExclude: exclude, MaxAlertsPerWebhook: 4},
+		Client:  &fakeTwilioClient{},
+	}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"fingerprint": "fp1", "labels": {"alertname": "DiskFull", "severity": "critical"}, "annotations": {"summary": "Disk full"}},
+		{"fingerprint": "fp2", "labels": {"alertname": "SiteDown", "env": "staging"}, "annotations": {"summary": "Site down"}},
+		{"fingerprint": "fp3", "status": "resolved", "labels": {"alertname": "HighCPU"}, "annotations": {"summary": "CPU high"}},
+		{"fingerprint": "fp4", "labels": {"alertname": "LowMemory"}, "annotations": {"summary": "Memory low", "sms": "false"}},
+		{"fingerprint": "fp5", "labels": {"alertname": "NoSummary"}},
+		{"fingerprint": "fp6", "labels": {"alertname": "SlowQueries"}, "annotations": {"summary": "Slow queries"}}
+	]}`)
+	m.HandleFastHTTP(ctx)
+
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := []AlertOutcome{
+		{Fingerprint: "fp1", Alertname: "DiskFull", Status: "sent"},
+		{Fingerprint: "fp2", Alertname: "SiteDown", Status: "filtered", Reason: "filter"},
+		{Fingerprint: "fp3", Alertname: "HighCPU", Status: "skipped", Reason: "resolved"},
+		{Fingerprint: "fp4", Alertname: "LowMemory", Status: "filtered", Reason: "opt_out"},
+		{Fingerprint: "fp5", Alertname: "NoSummary", Status: "skipped", Reason: "empty_message"},
+		{Fingerprint: "fp6", Alertname: "SlowQueries", Status: "dropped", Reason: "max_alerts"},
+	}
+	if !reflect.DeepEqual(response.Alerts, expected) {
+		t.Errorf("alerts == %+v, want %+v", response.Alerts, expected)
+	}
+}
+
+func TestSendJobSetOutcome(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		want     string
+	}{
+		{[]string{"sent"}, "sent"},
+		{[]string{"suppressed", "sent"}, "sent"},
+		{[]string{"sent", "failed", "batched"}, "failed"},
+		{[]string{"suppressed", "delayed"}, "delayed"},
+		{[]string{"batched", "dry_run"}, "dry_run"},
+	}
+	for _, tt := range tests {
+		alert := []byte(`{"fingerprint": "fp1"}`)
+		job := &sendJob{meta: &PayloadMeta{}}
+		job.addAlert(alert)
+		for _, status := range tt.statuses {
+			job.setOutcome(alert, status)
+		}
+		if got := job.response.Alerts[0].Status; got != tt.want {
+			t.Errorf("outcome of %q == %q, want %q", tt.statuses, got, tt.want)
+		}
+	}
+}
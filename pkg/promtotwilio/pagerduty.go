@@ -0,0 +1,131 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	// DefaultPagerDutyURL is the endpoint of the PagerDuty Events API v2
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	// pagerDutySummaryLength is the maximum length of an event summary
+	pagerDutySummaryLength = 1024
+)
+
+// pagerDutySeverities are the severities of the PagerDuty events, the
+// alerts with another severity label being sent as errors
+var pagerDutySeverities = map[string]bool{
+	"critical": true,
+	"error":    true,
+	"warning":  true,
+	"info":     true,
+}
+
+// pagerDuty triggers and resolves PagerDuty incidents through the Events
+// API v2, deduplicated by the fingerprints of the alerts
+type pagerDuty struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+func newPagerDuty(url, routingKey string) *pagerDuty {
+	return &pagerDuty{
+		url:        url,
+		routingKey: routingKey,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// pagerDutyEvent is an event of the PagerDuty Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Client      string            `json:"client,omitempty"`
+	ClientURL   string            `json:"client_url,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                     `json:"summary"`
+	Source        string                     `json:"source"`
+	Severity      string                     `json:"severity"`
+	Timestamp     string                     `json:"timestamp,omitempty"`
+	Group         string                     `json:"group,omitempty"`
+	CustomDetails map[string]json.RawMessage `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (p *pagerDuty) name() string {
+	return "pagerduty"
+}
+
+// notify triggers the incident of a firing alert, or resolves the one of a
+// resolved alert
+func (p *pagerDuty) notify(meta *PayloadMeta, alert []byte) error {
+	body, err := json.Marshal(p.event(meta, alert))
+	if err != nil {
+		return err
+	}
+	return postJSON(p.client, p.url, nil, body)
+}
+
+func (p *pagerDuty) event(meta *PayloadMeta, alert []byte) pagerDutyEvent {
+	event := pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey(alert),
+	}
+	if meta.status(alert) == "resolved" {
+		event.EventAction = "resolve"
+		return event
+	}
+
+	severity := meta.label(alert, "severity")
+	if !pagerDutySeverities[severity] {
+		severity = "error"
+	}
+	source := meta.label(alert, "instance")
+	if source == "" {
+		source = meta.label(alert, "job")
+	}
+	if source == "" {
+		source = "promtotwilio"
+	}
+	event.Payload = &pagerDutyPayload{
+		Summary:  truncate(alertSummary(meta, alert), pagerDutySummaryLength, true, DefaultEllipsis),
+		Source:   source,
+		Severity: severity,
+		Group:    meta.label(alert, "job"),
+	}
+	if startsAt, _ := jsonparser.GetString(alert, "startsAt"); startsAt != "" {
+		if t, err := time.Parse(time.RFC3339, startsAt); err == nil {
+			event.Payload.Timestamp = t.UTC().Format(time.RFC3339)
+		}
+	}
+	event.Payload.CustomDetails = make(map[string]json.RawMessage)
+	if labels, _, _, err := jsonparser.Get(alert, "labels"); err == nil {
+		event.Payload.CustomDetails["labels"] = labels
+	}
+	if annotations, _, _, err := jsonparser.Get(alert, "annotations"); err == nil {
+		event.Payload.CustomDetails["annotations"] = annotations
+	}
+
+	if meta.ExternalURL != "" {
+		event.Client = "Alertmanager"
+		event.ClientURL = meta.ExternalURL
+	}
+	if generatorURL, _ := jsonparser.GetString(alert, "generatorURL"); generatorURL != "" {
+		event.Links = []pagerDutyLink{{Href: generatorURL, Text: "Source"}}
+	}
+	return event
+}
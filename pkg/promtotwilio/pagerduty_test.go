@@ -0,0 +1,88 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyEvent(t *testing.T) {
+	p := newPagerDuty(DefaultPagerDutyURL, "key")
+	meta := &PayloadMeta{ExternalURL: "http://alertmanager:9093", CommonAnnotations: []byte(`{"summary": "Disk full on $labels.instance"}`)}
+	alert := []byte(`{"status": "firing", "fingerprint": "abc", "startsAt": "2019-01-01T10:00:00+01:00", "generatorURL": "http://prometheus/graph",
+		"labels": {"alertname": "DiskFull", "instance": "db1", "job": "node", "severity": "page"}}`)
+
+	event := p.event(meta, alert)
+	if event.RoutingKey != "key" || event.EventAction != "trigger" || event.DedupKey != "abc" || event.ClientURL != "http://alertmanager:9093" {
+		t.Errorf("event == %+v", event)
+	}
+	payload := event.Payload
+	if payload.Summary != "Disk full on db1" || payload.Source != "db1" || payload.Severity != "error" || payload.Group != "node" || payload.Timestamp != "2019-01-01T09:00:00Z" {
+		t.Errorf("payload == %+v", payload)
+	}
+	if string(payload.CustomDetails["labels"]) == "" || len(event.Links) != 1 || event.Links[0].Href != "http://prometheus/graph" {
+		t.Errorf("details == %s, links == %+v", payload.CustomDetails, event.Links)
+	}
+
+	resolved := p.event(meta, []byte(`{"status": "resolved", "fingerprint": "abc"}`))
+	if resolved.EventAction != "resolve" || resolved.DedupKey != "abc" || resolved.Payload != nil {
+		t.Errorf("resolved event == %+v", resolved)
+	}
+}
+
+func TestSendRequestPagerDuty(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []pagerDutyEvent
+		calls  int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := &fakeTwilioClient{}
+	notifiers := newNotifiers(newPagerDuty(server.URL, "key"))
+	notifiers.sleep = func(time.Duration) {}
+	m := OptionsWithHandler{
+		Options:   &Config{Receiver: "+15550001", Sender: "+100", Annotations: []string{"summary"}},
+		Client:    client,
+		Notifiers: notifiers,
+	}
+	m.HandleFastHTTP(newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"status": "firing", "fingerprint": "a", "annotations": {"summary": "Disk full"}},
+		{"status": "resolved", "fingerprint": "b", "annotations": {"summary": "CPU high"}}]}`))
+	notifiers.Stop()
+
+	if len(client.messages) != 1 {
+		t.Errorf("%d messages sent, want 1", len(client.messages))
+	}
+	actions := make(map[string]string)
+	for _, event := range events {
+		actions[event.DedupKey] = event.EventAction
+	}
+	if len(events) != 2 || actions["a"] != "trigger" || actions["b"] != "resolve" {
+		t.Errorf("events == %+v", events)
+	}
+
+	m.HandleFastHTTP(newSendRequestCtx("/send?dry_run=true", `{"status": "firing", "alerts": [{"status": "firing", "fingerprint": "c"}]}`))
+	notifiers.Stop()
+	if len(events) != 2 {
+		t.Errorf("dry run sent %+v", events[2:])
+	}
+}
@@ -0,0 +1,120 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CapturedPayload is a raw webhook payload kept for debugging
+type CapturedPayload struct {
+	ID          int       `json:"id"`
+	ReceivedAt  time.Time `json:"received_at"`
+	RequestID   string    `json:"request_id"`
+	Query       string    `json:"query"`
+	ContentType string    `json:"content_type"`
+	Body        string    `json:"body"`
+}
+
+// payloadRing keeps the last payloads received on /send
+type payloadRing struct {
+	mu       sync.Mutex
+	payloads []CapturedPayload
+	size     int
+	nextID   int
+}
+
+func newPayloadRing(size int) *payloadRing {
+	return &payloadRing{size: size, nextID: 1}
+}
+
+// add captures the payload of the request, dropping the oldest one when
+// the ring is full
+func (r *payloadRing) add(ctx *fasthttp.RequestCtx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.payloads = append(r.payloads, CapturedPayload{
+		ID:          r.nextID,
+		ReceivedAt:  time.Now().UTC(),
+		RequestID:   requestID(ctx),
+		Query:       string(ctx.QueryArgs().QueryString()),
+		ContentType: string(ctx.Request.Header.ContentType()),
+		Body:        string(ctx.PostBody()),
+	})
+	r.nextID++
+	if len(r.payloads) > r.size {
+		r.payloads = r.payloads[1:]
+	}
+}
+
+// list returns the captured payloads, most recent first
+func (r *payloadRing) list() []CapturedPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payloads := make([]CapturedPayload, len(r.payloads))
+	for i, p := range r.payloads {
+		payloads[len(payloads)-1-i] = p
+	}
+	return payloads
+}
+
+// get returns the captured payload with the given ID
+func (r *payloadRing) get(id int) (CapturedPayload, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.payloads {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return CapturedPayload{}, false
+}
+
+// listPayloads returns the captured payloads as JSON
+func (m OptionsWithHandler) listPayloads(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	ctx.SetContentType("application/json")
+	if err := json.NewEncoder(ctx).Encode(m.Payloads.list()); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
+
+// replayPayload sends again the captured payload selected by the id query
+// parameter, as if it was just received on /send
+func (m OptionsWithHandler) replayPayload(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsPost() {
+		writeProblem(ctx, fasthttp.StatusMethodNotAllowed, "")
+		return
+	}
+
+	id, err := strconv.Atoi(string(ctx.QueryArgs().Peek("id")))
+	if err != nil {
+		writeProblem(ctx, fasthttp.StatusBadRequest, "invalid id")
+		return
+	}
+
+	payload, ok := m.Payloads.get(id)
+	if !ok {
+		writeProblem(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+
+	requestLogger(ctx).Infof("Replaying payload %d of request %s", payload.ID, payload.RequestID)
+	ctx.Request.SetRequestURI("/send?" + payload.Query)
+	ctx.Request.Header.SetContentType(payload.ContentType)
+	ctx.Request.SetBodyString(payload.Body)
+	m.sendRequest(ctx)
+	m.audit(ctx, "payload.replay",
+		map[string]interface{}{"id": payload.ID, "request_id": payload.RequestID},
+		map[string]interface{}{"status_code": ctx.Response.StatusCode()})
+}
@@ -0,0 +1,61 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPayloadRing(t *testing.T) {
+	r := newPayloadRing(2)
+	for _, body := range []string{"one", "two", "three"} {
+		r.add(newSendRequestCtx("/send", body))
+	}
+
+	payloads := r.list()
+	if len(payloads) != 2 || payloads[0].Body != "three" || payloads[1].Body != "two" {
+		t.Fatalf("list() == %+v, want the last two payloads", payloads)
+	}
+	if _, ok := r.get(1); ok {
+		t.Errorf("get(1) found a payload dropped from the ring")
+	}
+	if p, ok := r.get(2); !ok || p.Body != "two" {
+		t.Errorf("get(2) == %+v, %v", p, ok)
+	}
+}
+
+func TestReplayPayload(t *testing.T) {
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options:  &Config{Sender: "+100", Annotations: []string{"summary"}},
+		Client:   client,
+		Payloads: newPayloadRing(10),
+	}
+
+	ctx := newSendRequestCtx("/send?receiver=%2B300", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/admin/payloads")
+	m.HandleFastHTTP(ctx)
+	var payloads []CapturedPayload
+	if err := json.Unmarshal(ctx.Response.Body(), &payloads); err != nil || len(payloads) != 1 {
+		t.Fatalf("/admin/payloads returned %s", ctx.Response.Body())
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/admin/payloads/replay?id=1")
+	m.HandleFastHTTP(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("replay status == %d, body %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if len(client.messages) != 2 || client.messages[1].To != "+300" {
+		t.Errorf("unexpected messages sent: %+v", client.messages)
+	}
+	if len(m.Payloads.list()) != 1 {
+		t.Errorf("replayed payload was captured again")
+	}
+}
@@ -0,0 +1,71 @@
+package promtotwilio
+
+import (
+	"strings"
+)
+
+// numberingPlan is what national numbers of a country are converted to
+// E.164 with
+type numberingPlan struct {
+	// callingCode replaces the trunk prefix of national numbers
+	callingCode string
+	// trunkPrefix starts the national numbers dialed within the country,
+	// empty for the countries keeping it, e.g. Italy
+	trunkPrefix string
+	// internationalPrefix starts the numbers dialed abroad
+	internationalPrefix string
+}
+
+// numberingPlans are the numbering plans of DEFAULT_COUNTRY, by ISO 3166
+// country code
+var numberingPlans = map[string]numberingPlan{
+	"AT": {"43", "0", "00"},
+	"AU": {"61", "0", "0011"},
+	"BE": {"32", "0", "00"},
+	"BR": {"55", "0", "00"},
+	"CA": {"1", "1", "011"},
+	"CH": {"41", "0", "00"},
+	"DE": {"49", "0", "00"},
+	"DK": {"45", "", "00"},
+	"ES": {"34", "", "00"},
+	"FI": {"358", "0", "00"},
+	"FR": {"33", "0", "00"},
+	"GB": {"44", "0", "00"},
+	"IE": {"353", "0", "00"},
+	"IN": {"91", "0", "00"},
+	"IT": {"39", "", "00"},
+	"JP": {"81", "0", "010"},
+	"LU": {"352", "", "00"},
+	"MX": {"52", "", "00"},
+	"NL": {"31", "0", "00"},
+	"NO": {"47", "", "00"},
+	"NZ": {"64", "0", "00"},
+	"PL": {"48", "", "00"},
+	"PT": {"351", "", "00"},
+	"SE": {"46", "0", "00"},
+	"SG": {"65", "", "000"},
+	"US": {"1", "1", "011"},
+	"ZA": {"27", "0", "00"},
+}
+
+// ValidCountry reports whether the numbering plan of a country, as an ISO
+// 3166 code, is known
+func ValidCountry(country string) bool {
+	_, ok := numberingPlans[country]
+	return ok
+}
+
+// toE164 converts a number dialed from the country, in the national or
+// international format, to E.164, leaving the other numbers as they are
+func toE164(number, country string) string {
+	plan, ok := numberingPlans[country]
+	digits := receiverPunctuation.Replace(number)
+	if !ok || digits == "" || strings.HasPrefix(digits, "+") || strings.Trim(digits, "0123456789") != "" {
+		return number
+	}
+
+	if strings.HasPrefix(digits, plan.internationalPrefix) {
+		return "+" + strings.TrimPrefix(digits, plan.internationalPrefix)
+	}
+	return "+" + plan.callingCode + strings.TrimPrefix(digits, plan.trunkPrefix)
+}
@@ -0,0 +1,57 @@
+package promtotwilio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestToE164(t *testing.T) {
+	tests := []struct {
+		number, country, expected string
+	}{
+		{"06 12 34 56 78", "FR", "+33612345678"},
+		{"06.12.34.56.78", "FR", "+33612345678"},
+		{"0044 7700 900100", "FR", "+447700900100"},
+		{"+33 6 12 34 56 78", "FR", "+33 6 12 34 56 78"},
+		{"07700 900100", "GB", "+447700900100"},
+		{"(555) 010-0001", "US", "+15550100001"},
+		{"1 555 010 0001", "US", "+15550100001"},
+		{"011 33 6 12 34 56 78", "US", "+33612345678"},
+		{"06 12 34 56 78", "IT", "+390612345678"},
+		{"06 12 34 56 78", "XX", "06 12 34 56 78"},
+		{"alice", "FR", "alice"},
+	}
+	for _, tt := range tests {
+		if got := toE164(tt.number, tt.country); got != tt.expected {
+			t.Errorf("toE164(%q, %q) == %q, want %q", tt.number, tt.country, got, tt.expected)
+		}
+	}
+}
+
+func TestRequestReceiversDefaultCountry(t *testing.T) {
+	o := &Config{
+		Receiver:       "06 12 34 56 78",
+		DefaultCountry: "FR",
+		Contacts:       map[string]string{"alice": "whatsapp:07 00 00 00 01"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"+33612345678"}},
+		{"receiver=06%2012%2034%2056%2079&receiver=alice", []string{"+33612345679", "whatsapp:+33700000001"}},
+		{"receiver=whatsapp:0612345678", []string{"whatsapp:+33612345678"}},
+	}
+	for _, tt := range tests {
+		args := &fasthttp.Args{}
+		args.Parse(tt.query)
+		receivers, _, err := requestReceivers(o, args, "", time.Now())
+		if err != nil || !reflect.DeepEqual(receivers, tt.expected) {
+			t.Errorf("requestReceivers(%q) == %v, %v, want %v", tt.query, receivers, err, tt.expected)
+		}
+	}
+}
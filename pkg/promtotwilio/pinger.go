@@ -0,0 +1,59 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pingMinInterval throttles the pings, as services like Healthchecks.io
+// only need one every few minutes
+const pingMinInterval = time.Minute
+
+// pinger pings an external watchdog, e.g. Healthchecks.io, which alerts
+// when the pings stop coming
+type pinger struct {
+	url         string
+	minInterval time.Duration
+	client      *http.Client
+	now         func() time.Time
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newPinger(url string, minInterval time.Duration) *pinger {
+	return &pinger{
+		url:         url,
+		minInterval: minInterval,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+}
+
+// ping requests the URL in the background, unless it was already within
+// minInterval
+func (p *pinger) ping() {
+	p.mu.Lock()
+	now := p.now()
+	if !p.last.IsZero() && now.Sub(p.last) < p.minInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.last = now
+	p.mu.Unlock()
+
+	go func() {
+		res, err := p.client.Get(p.url)
+		if err != nil {
+			log.Warnf("Error pinging the watchdog: %v", err)
+			return
+		}
+		res.Body.Close()
+		if res.StatusCode >= http.StatusMultipleChoices {
+			log.Warnf("Error pinging the watchdog: status %d", res.StatusCode)
+		}
+	}()
+}
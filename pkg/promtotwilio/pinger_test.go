@@ -0,0 +1,38 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPinger(t *testing.T) {
+	pings := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings <- struct{}{}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	p := newPinger(server.URL, time.Minute)
+	p.now = func() time.Time { return now }
+
+	p.ping()
+	p.ping()
+	now = now.Add(2 * time.Minute)
+	p.ping()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatalf("%d pings received, want 2", i)
+		}
+	}
+	select {
+	case <-pings:
+		t.Errorf("throttled ping received")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,42 @@
+package promtotwilio
+
+import (
+	"net/http/pprof"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// pprofPrefix is the path the runtime profiles are served under
+const pprofPrefix = "/debug/pprof/"
+
+// pprofHandlers serve the profiles of net/http/pprof which aren't listed by
+// its index
+var pprofHandlers = map[string]fasthttp.RequestHandler{
+	pprofPrefix + "cmdline": fasthttpadaptor.NewFastHTTPHandlerFunc(pprof.Cmdline),
+	pprofPrefix + "profile": fasthttpadaptor.NewFastHTTPHandlerFunc(pprof.Profile),
+	pprofPrefix + "symbol":  fasthttpadaptor.NewFastHTTPHandlerFunc(pprof.Symbol),
+	pprofPrefix + "trace":   fasthttpadaptor.NewFastHTTPHandlerFunc(pprof.Trace),
+}
+
+// pprofIndex serves the index and the named profiles, such as heap
+var pprofIndex = fasthttpadaptor.NewFastHTTPHandlerFunc(pprof.Index)
+
+// pprofPath reports whether a path is one of the runtime profiles
+func pprofPath(path string) bool {
+	return strings.HasPrefix(path, pprofPrefix)
+}
+
+// profile serves the runtime profiles when PPROF is enabled
+func (m OptionsWithHandler) profile(ctx *fasthttp.RequestCtx) {
+	if !m.Options.Pprof {
+		writeProblem(ctx, fasthttp.StatusNotFound, "")
+		return
+	}
+	if handler, ok := pprofHandlers[string(ctx.Path())]; ok {
+		handler(ctx)
+		return
+	}
+	pprofIndex(ctx)
+}
@@ -0,0 +1,44 @@
+package promtotwilio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestPprof(t *testing.T) {
+	tests := []struct {
+		path   string
+		pprof  bool
+		token  string
+		status int
+		body   string
+	}{
+		{"/debug/pprof/", false, "secret", fasthttp.StatusNotFound, ""},
+		{"/debug/pprof/", true, "", fasthttp.StatusUnauthorized, ""},
+		{"/debug/pprof/", true, "secret", fasthttp.StatusOK, "goroutine"},
+		{"/debug/pprof/heap", true, "secret", fasthttp.StatusOK, ""},
+		{"/debug/pprof/cmdline", true, "secret", fasthttp.StatusOK, ""},
+	}
+	for _, test := range tests {
+		m := OptionsWithHandler{Options: &Config{AdminToken: "secret", Pprof: test.pprof}}
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(test.path)
+		if test.token != "" {
+			ctx.Request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		m.HandleAdmin(ctx)
+		if ctx.Response.StatusCode() != test.status || !strings.Contains(string(ctx.Response.Body()), test.body) {
+			t.Errorf("GET %s == %d, want %d with %q", test.path, ctx.Response.StatusCode(), test.status, test.body)
+		}
+	}
+
+	m := OptionsWithHandler{Options: &Config{Pprof: true, AdminListenAddr: ":9091"}}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/debug/pprof/")
+	m.HandleWebhooks(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("GET /debug/pprof/ on the webhook port == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusNotFound)
+	}
+}
@@ -0,0 +1,29 @@
+package promtotwilio
+
+import (
+	"github.com/buger/jsonparser"
+	"github.com/valyala/fasthttp"
+)
+
+// priority reports whether an alert matches PRIORITY_ALERTS, its messages
+// bypassing batching, alert storm suppression and the queue bounds
+func (m OptionsWithHandler) priority(meta *PayloadMeta, alert []byte) bool {
+	return matchAll(m.Options.PriorityAlerts, func(name string) string { return meta.label(alert, name) })
+}
+
+// urgent reports whether a webhook has a firing alert matching
+// PRIORITY_ALERTS, to serve it even when the server is saturated
+func (m OptionsWithHandler) urgent(ctx *fasthttp.RequestCtx) bool {
+	if len(m.Options.PriorityAlerts) == 0 || decodeBody(ctx) != nil {
+		return false
+	}
+	payload := ctx.PostBody()
+	meta := parsePayloadMeta(payload)
+	urgent := false
+	jsonparser.ArrayEach(payload, func(alert []byte, dataType jsonparser.ValueType, offset int, err error) {
+		if meta.status(alert) == "firing" && m.priority(meta, alert) {
+			urgent = true
+		}
+	}, "alerts")
+	return urgent
+}
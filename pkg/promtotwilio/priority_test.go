@@ -0,0 +1,76 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSendRequestPriority(t *testing.T) {
+	priority, _ := ParseMatchers(`severity="page"`)
+	client := &fakeTwilioClient{}
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, PriorityAlerts: priority, StormThreshold: 1},
+		Client:  client,
+		Storm:   newStormGuard(1, time.Hour, func(receiver, text string) error { return nil }),
+	}
+	m.Storm.allow("+200")
+	m.Storm.allow("+200")
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [
+		{"labels": {"severity": "page"}, "annotations": {"summary": "Site down"}},
+		{"labels": {"severity": "warning"}, "annotations": {"summary": "Disk full"}}
+	]}`)
+	m.HandleFastHTTP(ctx)
+
+	var response SendResponse
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Sent != 1 || response.Suppressed != 1 {
+		t.Errorf("unexpected response %+v", response)
+	}
+	for _, message := range client.messages {
+		if message.Body != "Site down" {
+			t.Errorf("unexpected message %q", message.Body)
+		}
+	}
+}
+
+func TestUrgent(t *testing.T) {
+	priority, _ := ParseMatchers(`severity="page"`)
+	m := OptionsWithHandler{Options: &Config{PriorityAlerts: priority}}
+
+	tests := []struct {
+		payload string
+		urgent  bool
+	}{
+		{`{"status": "firing", "alerts": [{"labels": {"severity": "page"}}]}`, true},
+		{`{"status": "firing", "commonLabels": {"severity": "page"}, "alerts": [{"labels": {}}]}`, true},
+		{`{"status": "resolved", "alerts": [{"labels": {"severity": "page"}}]}`, false},
+		{`{"status": "firing", "alerts": [{"labels": {"severity": "warning"}}]}`, false},
+	}
+	for _, tt := range tests {
+		if got := m.urgent(newSendRequestCtx("/send", tt.payload)); got != tt.urgent {
+			t.Errorf("urgent(%q) == %v, want %v", tt.payload, got, tt.urgent)
+		}
+	}
+}
+
+func TestSendLimiterPriority(t *testing.T) {
+	priority, _ := ParseMatchers(`severity="page"`)
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}, PriorityAlerts: priority},
+		Client:  &fakeTwilioClient{},
+		Limiter: newSendLimiter(1),
+	}
+	m.Limiter.acquire()
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"labels": {"severity": "page"}, "annotations": {"summary": "Site down"}}]}`)
+	m.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusOK)
+	}
+}
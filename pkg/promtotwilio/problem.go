@@ -0,0 +1,36 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Problem is an RFC 7807 problem details error response
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeProblem responds with the status code and a problem describing it
+func writeProblem(ctx *fasthttp.RequestCtx, status int, detail string) {
+	ctx.SetStatusCode(status)
+	ctx.SetContentType("application/problem+json")
+	if err := json.NewEncoder(ctx).Encode(newProblem(ctx, status, detail)); err != nil {
+		requestLogger(ctx).Errorf("Error writing response: %v", err)
+	}
+}
+
+// newProblem returns the problem describing the status code of a request
+func newProblem(ctx *fasthttp.RequestCtx, status int, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     fasthttp.StatusMessage(status),
+		Status:    status,
+		Detail:    redact(detail),
+		RequestID: requestID(ctx),
+	}
+}
@@ -0,0 +1,34 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSendRequestProblem(t *testing.T) {
+	m := OptionsWithHandler{Options: &Config{Sender: "+100"}}
+
+	ctx := newSendRequestCtx("/send", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	ctx.Request.Header.Set(requestIDHeader, "abc-123")
+	WithRequestID(m.HandleFastHTTP)(ctx)
+
+	if string(ctx.Response.Header.ContentType()) != "application/problem+json" {
+		t.Errorf("Content-Type == %q, want %q", ctx.Response.Header.ContentType(), "application/problem+json")
+	}
+	var problem Problem
+	if err := json.Unmarshal(ctx.Response.Body(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	expected := Problem{
+		Type:      "about:blank",
+		Title:     "Bad Request",
+		Status:    fasthttp.StatusBadRequest,
+		Detail:    "receiver not specified",
+		RequestID: "abc-123",
+	}
+	if problem != expected {
+		t.Errorf("problem == %+v, want %+v", problem, expected)
+	}
+}
@@ -0,0 +1,92 @@
+// Package promtotwilio sends the alerts of Prometheus Alertmanager webhooks
+// as text messages through Twilio.
+//
+// The bridge can be embedded in another Go service by mounting the handler
+// returned by New in its own mux:
+//
+//	bridge := promtotwilio.New(sid, token, "+15550100",
+//		promtotwilio.WithReceivers("+15550001"),
+//		promtotwilio.WithSendResolved())
+//	defer bridge.Stop()
+//	mux.Handle("/alerts/", http.StripPrefix("/alerts", bridge))
+package promtotwilio
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Option configures the bridge created by New
+type Option func(*Config)
+
+// WithReceivers sets the default receivers of the messages
+func WithReceivers(numbers ...string) Option {
+	return func(c *Config) {
+		c.Receiver = strings.Join(numbers, ",")
+	}
+}
+
+// WithTemplate sets the template of the messages, whose data is the alert
+func WithTemplate(t *template.Template) Option {
+	return func(c *Config) {
+		c.Template = t
+	}
+}
+
+// WithFormatter sets the formatter building the messages
+func WithFormatter(f Formatter) Option {
+	return func(c *Config) {
+		c.Formatter = f
+	}
+}
+
+// WithSendResolved sends a message when alerts are resolved too
+func WithSendResolved() Option {
+	return func(c *Config) {
+		c.SendResolved = true
+	}
+}
+
+// WithTwilioAPIURL sets the base URL of the Twilio API, e.g. for a mock
+func WithTwilioAPIURL(url string) Option {
+	return func(c *Config) {
+		c.TwilioAPIURL = url
+	}
+}
+
+// WithRetry sets how failed messages are retried
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Config) {
+		c.Retry = policy
+	}
+}
+
+// New returns a bridge sending messages from sender with the given Twilio
+// credentials, configured with the defaults of the promtotwilio command
+// unless overridden by the options. Its background work ends with Stop.
+func New(accountSid, authToken, sender string, opts ...Option) OptionsWithHandler {
+	c := &Config{
+		AccountSid:            accountSid,
+		AuthToken:             authToken,
+		Sender:                sender,
+		Retry:                 RetryPolicy{MaxRetries: 2, Base: time.Second, MaxElapsed: 30 * time.Second},
+		Annotations:           []string{"summary"},
+		ResolvedPrefix:        "RESOLVED: ",
+		RoutingLocation:       time.UTC,
+		BatchBypassSeverities: []string{"critical"},
+		HistorySize:           100,
+		IdempotencyTTL:        10 * time.Minute,
+		StormWindow:           10 * time.Minute,
+		FlapWindow:            10 * time.Minute,
+		HeartbeatInterval:     24 * time.Hour,
+		StateSaveInterval:     10 * time.Second,
+		LeaderLeaseDuration:   10 * time.Second,
+		LeaderLeaseKey:        "promtotwilio:leader",
+		BudgetSegmentPrice:    DefaultSegmentPrice,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return NewMOptionsWithHandler(c)
+}
@@ -0,0 +1,24 @@
+package promtotwilio
+
+import (
+	"runtime/debug"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RecoverPanics wraps a handler to answer 500 to the requests it panics on,
+// logging the stack trace, instead of crashing the process
+func RecoverPanics(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicsTotal.Inc()
+				requestLogger(ctx).Errorf("Panic serving %s %s: %v\n%s", ctx.Method(), ctx.Path(), r, debug.Stack())
+				ctx.Response.Reset()
+				ctx.Response.Header.Set(requestIDHeader, requestID(ctx))
+				writeProblem(ctx, fasthttp.StatusInternalServerError, "")
+			}
+		}()
+		h(ctx)
+	}
+}
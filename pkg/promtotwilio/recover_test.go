@@ -0,0 +1,27 @@
+package promtotwilio
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	before := testutil.ToFloat64(panicsTotal)
+	ctx := &fasthttp.RequestCtx{}
+	WithRequestID(RecoverPanics(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		panic("malformed payload")
+	}))(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Errorf("status == %d, want %d", ctx.Response.StatusCode(), fasthttp.StatusInternalServerError)
+	}
+	if len(ctx.Response.Header.Peek(requestIDHeader)) == 0 {
+		t.Errorf("request ID lost")
+	}
+	if got := testutil.ToFloat64(panicsTotal); got != before+1 {
+		t.Errorf("panicsTotal == %g, want %g", got, before+1)
+	}
+}
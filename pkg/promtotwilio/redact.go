@@ -0,0 +1,105 @@
+package promtotwilio
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "REDACTED"
+
+// numberPattern matches the E.164 numbers, as is or URL encoded
+var numberPattern = regexp.MustCompile(`(\+|%2[bB])(\d{6,15})`)
+
+// redactNumbers masks all but the last 4 digits of the phone numbers in s
+func redactNumbers(s string) string {
+	return numberPattern.ReplaceAllStringFunc(s, func(number string) string {
+		match := numberPattern.FindStringSubmatch(number)
+		return match[1] + maskNumber(match[2])
+	})
+}
+
+var (
+	// userinfoPattern matches the passwords of the URLs
+	userinfoPattern = regexp.MustCompile(`(://[^/:@\s]+:)[^/@\s]+@`)
+	// credentialPattern matches the credentials of authorization headers
+	// and of query parameters, JSON fields or key=value pairs named after
+	// secrets
+	credentialPattern = regexp.MustCompile(`(?i)((?:authorization|token|secret|password|api_?key|signature|credential)[a-z_]*"?\s*[:=]\s*"?(?:basic |bearer |aws4-hmac-sha256 )?)[^\s"&,;]+`)
+)
+
+// secrets are the configured credentials, scrubbed wherever they appear
+var secrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// minSecretLength keeps short values, likely to appear in unrelated text,
+// from being scrubbed
+const minSecretLength = 6
+
+// addSecrets registers credentials to scrub
+func addSecrets(values ...string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	for _, v := range values {
+		if len(v) >= minSecretLength && !contains(secrets.values, v) {
+			secrets.values = append(secrets.values, v)
+		}
+	}
+}
+
+// addURLSecrets registers the password or token of the userinfo of a URL
+func addURLSecrets(rawurl string) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.User == nil {
+		return
+	}
+	if password, ok := u.User.Password(); ok {
+		addSecrets(password)
+	} else {
+		addSecrets(u.User.Username())
+	}
+}
+
+// scrubSecrets removes the configured credentials, the passwords of URLs
+// and the values of authorization headers and secret parameters from s
+func scrubSecrets(s string) string {
+	secrets.mu.RLock()
+	for _, v := range secrets.values {
+		s = strings.Replace(s, v, redacted, -1)
+	}
+	secrets.mu.RUnlock()
+
+	s = userinfoPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+	return credentialPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// redact scrubs the credentials of s and masks its phone numbers
+func redact(s string) string {
+	return redactNumbers(scrubSecrets(s))
+}
+
+// redactingFormatter scrubs the credentials and masks the phone numbers in
+// the log entries formatted by the formatter it wraps
+type redactingFormatter struct {
+	log.Formatter
+}
+
+// Redact wraps a log formatter to scrub the credentials and mask all but
+// the last 4 digits of the phone numbers in the log entries, e.g. in Twilio
+// errors and URLs
+func Redact(f log.Formatter) log.Formatter {
+	return redactingFormatter{f}
+}
+
+func (f redactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	b, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(redact(string(b))), nil
+}
@@ -0,0 +1,181 @@
+package promtotwilio
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisMaxBulkSize bounds the size of the bulk strings read from the server
+const redisMaxBulkSize = 1 << 20
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient runs commands on a Redis server, speaking RESP over a
+// connection per command
+type redisClient struct {
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	db       int
+	timeout  time.Duration
+}
+
+// ValidRedisURL reports whether rawurl is a URL such as
+// redis://:password@redis:6379/0, or rediss:// for TLS
+func ValidRedisURL(rawurl string) error {
+	_, err := newRedisClient(rawurl, time.Second)
+	return err
+}
+
+func newRedisClient(rawurl string, timeout time.Duration) (*redisClient, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, errors.New("the URL must be such as redis://:password@redis:6379/0")
+	}
+	c := &redisClient{addr: u.Host, timeout: timeout}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Scheme == "rediss" {
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if c.db, err = strconv.Atoi(path); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("invalid database %q", path)
+		}
+	}
+	return c, nil
+}
+
+// do runs a command, returning its reply: a string, an int64, nil or a
+// []interface{}, or a redisError
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+
+	var commands [][]string
+	if c.password != "" {
+		if c.username != "" {
+			commands = append(commands, []string{"AUTH", c.username, c.password})
+		} else {
+			commands = append(commands, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	commands = append(commands, args)
+
+	w := bufio.NewWriter(conn)
+	for _, command := range commands {
+		writeRedisCommand(w, command)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	var reply interface{}
+	for _, command := range commands {
+		if reply, err = readRedisReply(r); err != nil {
+			if _, ok := err.(redisError); ok && command[0] != args[0] {
+				return nil, fmt.Errorf("%s: %v", command[0], err)
+			}
+			return nil, err
+		}
+	}
+	return reply, nil
+}
+
+func writeRedisCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// readRedisReply reads a reply, the error replies being returned as a
+// redisError
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size > redisMaxBulkSize {
+			return nil, fmt.Errorf("invalid bulk string %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		bulk := make([]byte, size+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			return nil, err
+		}
+		return string(bulk[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		array := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			element, err := readRedisReply(r)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		return array, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
@@ -0,0 +1,42 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxDecodedBodySize bounds the size of decompressed request bodies, so that
+// small gzip bombs can't exhaust the memory
+const maxDecodedBodySize = fasthttp.DefaultMaxRequestBodySize
+
+var errBodyTooLarge = errors.New("decompressed body too large")
+
+// decodeBody decompresses in place the body of requests with a gzip
+// Content-Encoding
+func decodeBody(ctx *fasthttp.RequestCtx) error {
+	if string(ctx.Request.Header.Peek("Content-Encoding")) != "gzip" {
+		return nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(ctx.PostBody()))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(r, maxDecodedBodySize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxDecodedBodySize {
+		return errBodyTooLarge
+	}
+	ctx.Request.SetBody(body)
+	ctx.Request.Header.Del("Content-Encoding")
+	return nil
+}
@@ -0,0 +1,45 @@
+package promtotwilio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func gzipped(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("Content-Encoding", "gzip")
+	ctx.Request.SetBody(gzipped(t, []byte(`{"status": "firing"}`)))
+	if err := decodeBody(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if string(ctx.PostBody()) != `{"status": "firing"}` || len(ctx.Request.Header.Peek("Content-Encoding")) != 0 {
+		t.Errorf("decodeBody() left body %q", ctx.PostBody())
+	}
+
+	ctx.Request.Header.Set("Content-Encoding", "gzip")
+	ctx.Request.SetBody(gzipped(t, make([]byte, maxDecodedBodySize+1)))
+	if err := decodeBody(ctx); err != errBodyTooLarge {
+		t.Errorf("decodeBody() of a large body == %v, want %v", err, errBodyTooLarge)
+	}
+
+	ctx.Request.Header.Set("Content-Encoding", "gzip")
+	ctx.Request.SetBodyString(`{"status": "firing"}`)
+	if err := decodeBody(ctx); err == nil {
+		t.Errorf("decodeBody() of an invalid body succeeded")
+	}
+}
@@ -0,0 +1,63 @@
+package promtotwilio
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+	// maxRequestIDLength bounds the size of request IDs accepted from clients
+	maxRequestIDLength = 128
+)
+
+// WithRequestID assigns every request an ID, reusing the one sent by the
+// client in the X-Request-ID header when it is sane, and echoes it back in
+// the response so a message can be traced back to the webhook it came from.
+func WithRequestID(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		id := string(ctx.Request.Header.Peek(requestIDHeader))
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		ctx.SetUserValue(requestIDKey, id)
+		ctx.Response.Header.Set(requestIDHeader, id)
+		h(ctx)
+	}
+}
+
+// requestID returns the ID assigned to the request by WithRequestID
+func requestID(ctx *fasthttp.RequestCtx) string {
+	id, _ := ctx.UserValue(requestIDKey).(string)
+	return id
+}
+
+// requestLogger returns a logger tagged with the ID of the request
+func requestLogger(ctx *fasthttp.RequestCtx) *log.Entry {
+	return log.WithField("request_id", requestID(ctx))
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Warnf("Error generating request ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package promtotwilio
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	h := WithRequestID(func(ctx *fasthttp.RequestCtx) {
+		seen = requestID(ctx)
+	})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(requestIDHeader, "abc-123")
+	h(ctx)
+	if seen != "abc-123" {
+		t.Errorf("requestID() == %q, want %q", seen, "abc-123")
+	}
+	if got := string(ctx.Response.Header.Peek(requestIDHeader)); got != "abc-123" {
+		t.Errorf("response %s == %q, want %q", requestIDHeader, got, "abc-123")
+	}
+
+	for _, incoming := range []string{"", "bad id\x01"} {
+		ctx = &fasthttp.RequestCtx{}
+		ctx.Request.Header.Set(requestIDHeader, incoming)
+		h(ctx)
+		if len(seen) != 32 || seen == incoming {
+			t.Errorf("requestID() == %q for incoming %q, want a generated ID", seen, incoming)
+		}
+	}
+}
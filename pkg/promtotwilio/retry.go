@@ -0,0 +1,135 @@
+package promtotwilio
+
+import (
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// retryMaxBackoff bounds the exponential delay between two retries
+const retryMaxBackoff = time.Minute
+
+// RetryPolicy configures how failed Twilio requests are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// Base is the delay before the first retry, doubled for each next one
+	Base time.Duration
+	// MaxElapsed bounds the total time spent on a message
+	MaxElapsed time.Duration
+}
+
+// retryingTwilioClient is a TwilioClient retrying transient failures with
+// exponential backoff and jitter, honoring Retry-After on rate limiting
+type retryingTwilioClient struct {
+	client TwilioClient
+	policy RetryPolicy
+	sleep  func(time.Duration)
+}
+
+func newRetryingTwilioClient(client TwilioClient, policy RetryPolicy) *retryingTwilioClient {
+	return &retryingTwilioClient{
+		client: client,
+		policy: policy,
+		sleep:  time.Sleep,
+	}
+}
+
+// SendMessage sends the message, retrying as long as the policy allows
+func (c *retryingTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		receipt, err := c.client.SendMessage(m)
+		if twilioErr, ok := err.(*TwilioError); ok {
+			twilioErrorsTotal.WithLabelValues(strconv.Itoa(twilioErr.Code)).Inc()
+		}
+		if err == nil || !retryable(err) || attempt >= c.policy.MaxRetries {
+			return receipt, err
+		}
+
+		delay := c.backoff(attempt, err)
+		if c.policy.MaxElapsed > 0 && time.Since(start)+delay > c.policy.MaxElapsed {
+			return receipt, err
+		}
+
+		log.WithField("request_id", m.RequestID).Warnf("Retrying message in %s after error: %v", delay, err)
+		c.sleep(delay)
+	}
+}
+
+// backoff returns the delay before the given retry attempt: the Retry-After
+// requested by Twilio if any, otherwise an exponential delay with jitter
+func (c *retryingTwilioClient) backoff(attempt int, err error) time.Duration {
+	if twilioErr, ok := err.(*TwilioError); ok && twilioErr.RetryAfter > 0 {
+		return twilioErr.RetryAfter
+	}
+
+	delay := c.policy.Base
+	if delay <= 0 {
+		return 0
+	}
+	for i := 0; i < attempt && delay < retryMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxBackoff {
+		delay = retryMaxBackoff
+	}
+	// equal jitter: keep half of the delay and randomize the other half
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Twilio error codes known to be transient, or permanent whatever the
+// HTTP status they come with
+var (
+	transientTwilioCodes = map[int]bool{
+		20429: true, // too many requests
+		20500: true, // internal server error
+		20503: true, // service unavailable
+		30001: true, // queue overflow
+	}
+	permanentTwilioCodes = map[int]bool{
+		20003: true, // authentication failed
+		21211: true, // invalid 'To' phone number
+		21212: true, // invalid 'From' phone number
+		21408: true, // region not enabled
+		21606: true, // 'From' number can't send this message
+		21610: true, // recipient unsubscribed
+		21614: true, // 'To' number isn't a mobile number
+		21617: true, // body too long
+	}
+)
+
+// retryable reports whether sending a message again could succeed without
+// sending it twice: failing to connect, rate limiting and server errors are
+// retried, but not the network errors once the request may have been
+// received, such as timeouts, nor the other API errors
+func retryable(err error) bool {
+	twilioErr, ok := err.(*TwilioError)
+	if !ok {
+		return unsent(err)
+	}
+	if transientTwilioCodes[twilioErr.Code] {
+		return true
+	}
+	if permanentTwilioCodes[twilioErr.Code] {
+		return false
+	}
+	return twilioErr.Status == 429 || twilioErr.Status >= 500
+}
+
+// unsent reports whether a network error happened before the request was
+// sent: while resolving, connecting to Twilio or the proxy, or during the TLS
+// handshake
+func unsent(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		return opErr.Op == "dial" || opErr.Op == "proxyconnect"
+	}
+	return err.Error() == "net/http: TLS handshake timeout"
+}
@@ -0,0 +1,100 @@
+package promtotwilio
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyTwilioClient fails with the given errors before succeeding
+type flakyTwilioClient struct {
+	errs     []error
+	attempts int
+}
+
+func (c *flakyTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	c.attempts++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return nil, err
+	}
+	return &MessageReceipt{Sid: "SM1"}, nil
+}
+
+// dialError is the error of a request to a Twilio API which can't be reached
+var dialError = &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+func TestRetryingTwilioClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		errs       []error
+		maxRetries int
+		attempts   int
+		delays     []time.Duration
+		success    bool
+	}{
+		{"connection refused", []error{dialError}, 2, 2, nil, true},
+		{"timeout after sending", []error{&url.Error{Op: "Post", URL: "https://api.twilio.com", Err: &net.OpError{Op: "read", Err: errors.New("i/o timeout")}}}, 2, 1, nil, false},
+		{"retry after", []error{&TwilioError{Status: 429, RetryAfter: 7 * time.Second}}, 2, 2, []time.Duration{7 * time.Second}, true},
+		{"server errors exhausted", []error{&TwilioError{Status: 500}, &TwilioError{Status: 503}, &TwilioError{Status: 502}}, 2, 3, nil, false},
+		{"client error", []error{&TwilioError{Status: 400, Code: 21201}}, 2, 1, nil, false},
+		{"permanent code", []error{&TwilioError{Status: 500, Code: 21211}}, 2, 1, nil, false},
+		{"transient code", []error{&TwilioError{Status: 400, Code: 20429}}, 2, 2, nil, true},
+		{"disabled", []error{dialError}, 0, 1, nil, false},
+	}
+
+	for _, test := range tests {
+		flaky := &flakyTwilioClient{errs: test.errs}
+		var delays []time.Duration
+		c := newRetryingTwilioClient(flaky, RetryPolicy{MaxRetries: test.maxRetries, Base: time.Second, MaxElapsed: time.Minute})
+		c.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+		_, err := c.SendMessage(&Message{})
+		if (err == nil) != test.success || flaky.attempts != test.attempts {
+			t.Errorf("%s: %d attempts, error %v", test.name, flaky.attempts, err)
+		}
+		if test.delays != nil && (len(delays) != 1 || delays[0] != test.delays[0]) {
+			t.Errorf("%s: slept %v, want %v", test.name, delays, test.delays)
+		}
+	}
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	c := newRetryingTwilioClient(nil, RetryPolicy{Base: time.Second})
+	for attempt := 0; attempt < 4; attempt++ {
+		max := time.Second << uint(attempt)
+		if d := c.backoff(attempt, dialError); d < max/2 || d > max {
+			t.Errorf("backoff(%d) == %s, want between %s and %s", attempt, d, max/2, max)
+		}
+	}
+	for _, attempt := range []int{7, 63, 64, 1000} {
+		if d := c.backoff(attempt, dialError); d < retryMaxBackoff/2 || d > retryMaxBackoff {
+			t.Errorf("backoff(%d) == %s, want between %s and %s", attempt, d, retryMaxBackoff/2, retryMaxBackoff)
+		}
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	flaky := &flakyTwilioClient{errs: []error{&TwilioError{Status: 429, RetryAfter: time.Hour}}}
+	c := newRetryingTwilioClient(flaky, RetryPolicy{MaxRetries: 2, Base: time.Second, MaxElapsed: time.Minute})
+	c.sleep = func(d time.Duration) { t.Errorf("slept %s beyond the maximum elapsed time", d) }
+
+	if _, err := c.SendMessage(&Message{}); err == nil || flaky.attempts != 1 {
+		t.Errorf("%d attempts, error %v", flaky.attempts, err)
+	}
+}
+
+func TestRetryCountsTwilioErrorCodes(t *testing.T) {
+	before := testutil.ToFloat64(twilioErrorsTotal.WithLabelValues("21211"))
+	c := newRetryingTwilioClient(&flakyTwilioClient{errs: []error{&TwilioError{Status: 400, Code: 21211}}}, RetryPolicy{MaxRetries: 2})
+	c.SendMessage(&Message{})
+
+	if after := testutil.ToFloat64(twilioErrorsTotal.WithLabelValues("21211")); after != before+1 {
+		t.Errorf("twilioErrorsTotal{code=21211} == %g, want %g", after, before+1)
+	}
+}
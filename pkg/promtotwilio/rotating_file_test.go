@@ -0,0 +1,68 @@
+package promtotwilio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promtotwilio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "promtotwilio.log")
+	f, err := NewRotatingFile(path, 10, time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	if string(content) != "second\n" {
+		t.Errorf("current log file contains %q, want %q", content, "second\n")
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("found %d rotated files, want 1", len(backups))
+	}
+	content, _ = ioutil.ReadFile(backups[0])
+	if string(content) != "first\n" {
+		t.Errorf("rotated log file contains %q, want %q", content, "first\n")
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promtotwilio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "promtotwilio.log")
+	f, err := NewRotatingFile(path, 1024, time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("old\n"))
+	f.openedAt = f.openedAt.Add(-2 * time.Hour)
+	f.Write([]byte("new\n"))
+
+	content, _ := ioutil.ReadFile(path)
+	if string(content) != "new\n" {
+		t.Errorf("current log file contains %q, want %q", content, "new\n")
+	}
+}
@@ -0,0 +1,177 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Route sends the alerts to its receivers when the time of the notification
+// falls within its days and hours. Routes are built by LoadRoutes
+type Route struct {
+	Days      string   `json:"days"`
+	Hours     string   `json:"hours"`
+	Receivers []string `json:"receivers"`
+	// AccountSid is the Twilio subaccount the messages are sent from, to
+	// separate the costs of each team
+	AccountSid string `json:"account_sid"`
+	// Delay holds back the messages of firing alerts, which are dropped if
+	// the alerts resolve in the meantime
+	Delay string `json:"delay"`
+	// Notifiers names the notifiers, pagerduty or opsgenie, the alerts are
+	// sent to, all the configured ones when unset and none when empty
+	Notifiers []string `json:"notifiers"`
+
+	delay time.Duration
+	days  [7]bool
+	// from and to are minutes since midnight, to being excluded and lower
+	// than from for overnight hours
+	from, to int
+}
+
+// LoadRoutes reads the routing rules from a JSON file
+func LoadRoutes(path string) ([]*Route, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []*Route
+	if err := json.Unmarshal(content, &routes); err != nil {
+		return nil, err
+	}
+	for i, r := range routes {
+		if err := r.init(); err != nil {
+			return nil, fmt.Errorf("route #%d: %v", i+1, err)
+		}
+	}
+	return routes, nil
+}
+
+// init parses the days and hours of the route
+func (r *Route) init() error {
+	if len(r.Receivers) == 0 {
+		return fmt.Errorf("no receivers")
+	}
+
+	if r.Days == "" {
+		r.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, item := range SplitList(strings.ToLower(r.Days)) {
+		bounds := strings.SplitN(item, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[bounds[1]]
+		}
+		if _, valid := weekdays[bounds[0]]; !valid || !ok {
+			return fmt.Errorf("invalid days %q", item)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			r.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	for _, name := range r.Notifiers {
+		if !notifierNames[name] {
+			return fmt.Errorf("unknown notifier %q", name)
+		}
+	}
+
+	if r.Delay != "" {
+		var err error
+		if r.delay, err = time.ParseDuration(r.Delay); err != nil || r.delay < 0 {
+			return fmt.Errorf("invalid delay %q", r.Delay)
+		}
+	}
+
+	r.from, r.to = 0, 24*60
+	if r.Hours != "" {
+		bounds := strings.SplitN(r.Hours, "-", 2)
+		if len(bounds) != 2 {
+			return fmt.Errorf("invalid hours %q", r.Hours)
+		}
+		var err error
+		if r.from, err = parseClock(bounds[0]); err != nil {
+			return err
+		}
+		if r.to, err = parseClock(bounds[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseClock returns the number of minutes since midnight of a HH:MM time
+func parseClock(s string) (int, error) {
+	if strings.TrimSpace(s) == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches reports whether the route applies at t, given in the routing
+// time zone, holidays being treated like sundays
+func (r *Route) matches(t time.Time, h Holidays) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if r.from <= r.to {
+		return r.days[weekday(t, h)] && minutes >= r.from && minutes < r.to
+	}
+
+	// overnight hours belong to the day they start on
+	if minutes >= r.from {
+		return r.days[weekday(t, h)]
+	}
+	return minutes < r.to && r.days[weekday(t.AddDate(0, 0, -1), h)]
+}
+
+// weekday returns the day of the week of t, or sunday on holidays
+func weekday(t time.Time, h Holidays) time.Weekday {
+	if h.contains(t) {
+		return time.Sunday
+	}
+	return t.Weekday()
+}
+
+// matchRoute returns the first route applying at t, if any
+func matchRoute(routes []*Route, t time.Time, h Holidays) *Route {
+	for _, r := range routes {
+		if r.matches(t, h) {
+			return r
+		}
+	}
+	return nil
+}
+
+// notifies reports whether the alerts of the route are sent to the named
+// notifier, a nil route sending them to all of them
+func (r *Route) notifies(name string) bool {
+	if r == nil || r.Notifiers == nil {
+		return true
+	}
+	for _, n := range r.Notifiers {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package promtotwilio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadRoutes(t *testing.T) {
+	path := writeTempFile(t, `[
+		{"days": "mon-fri", "hours": "09:00-18:00", "receivers": ["+100"]},
+		{"days": "fri-mon", "hours": "22:00-06:00", "receivers": ["+200"]},
+		{"receivers": ["+300"]}
+	]`)
+	defer os.Remove(path)
+
+	routes, err := LoadRoutes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		time     string
+		expected string
+	}{
+		{"2019-01-07T09:00:00Z", "+100"}, // monday
+		{"2019-01-07T17:59:00Z", "+100"},
+		{"2019-01-07T18:00:00Z", "+300"},
+		{"2019-01-07T23:00:00Z", "+200"},
+		{"2019-01-08T03:00:00Z", "+200"}, // tuesday night, started on monday
+		{"2019-01-08T23:00:00Z", "+300"}, // tuesday
+		{"2019-01-09T03:00:00Z", "+300"},
+		{"2019-01-12T12:00:00Z", "+300"}, // saturday
+		{"2019-01-13T02:00:00Z", "+200"}, // sunday
+	}
+
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.time)
+		r := matchRoute(routes, at, nil)
+		if r == nil || r.Receivers[0] != test.expected {
+			t.Errorf("matchRoute(%s) == %+v, want receiver %s", test.time, r, test.expected)
+		}
+	}
+}
+
+func TestRouteInitErrors(t *testing.T) {
+	for _, r := range []*Route{
+		{Days: "mon-fri"},
+		{Days: "monday", Receivers: []string{"+100"}},
+		{Days: "mon-xyz", Receivers: []string{"+100"}},
+		{Hours: "09:00", Receivers: []string{"+100"}},
+		{Hours: "9h-18h", Receivers: []string{"+100"}},
+	} {
+		if err := r.init(); err == nil {
+			t.Errorf("init() of %+v didn't fail", r)
+		}
+	}
+}
+
+func TestRouteNotifiers(t *testing.T) {
+	path := writeTempFile(t, `[
+		{"days": "mon-fri", "receivers": ["+100"], "notifiers": ["opsgenie"]},
+		{"days": "sat", "receivers": ["+200"], "notifiers": []},
+		{"receivers": ["+300"]}
+	]`)
+	defer os.Remove(path)
+	routes, err := LoadRoutes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		route    *Route
+		expected []string
+	}{
+		{routes[0], []string{"opsgenie"}},
+		{routes[1], nil},
+		{routes[2], []string{"pagerduty", "opsgenie"}},
+		{nil, []string{"pagerduty", "opsgenie"}},
+	}
+	for i, test := range tests {
+		var selected []string
+		for _, name := range []string{"pagerduty", "opsgenie"} {
+			if test.route.notifies(name) {
+				selected = append(selected, name)
+			}
+		}
+		if !reflect.DeepEqual(selected, test.expected) {
+			t.Errorf("route #%d notifies %q, want %q", i+1, selected, test.expected)
+		}
+	}
+
+	invalid := writeTempFile(t, `[{"receivers": ["+100"], "notifiers": ["slack"]}]`)
+	defer os.Remove(invalid)
+	if _, err := LoadRoutes(invalid); err == nil {
+		t.Errorf("LoadRoutes() accepted an unknown notifier")
+	}
+}
@@ -0,0 +1,131 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ParseGroups parses receiver groups defined as name=number,number pairs
+// separated by semicolons, e.g. "db-team=+15550001,+15550002;net-team=+15550003"
+func ParseGroups(s string) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, def := range strings.Split(s, ";") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		kv := strings.SplitN(def, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" || len(SplitList(kv[1])) == 0 {
+			return nil, fmt.Errorf("invalid group definition %q", def)
+		}
+		groups[name] = SplitList(kv[1])
+	}
+	return groups, nil
+}
+
+// requestReceivers returns the receivers of a /send request received at
+// now: the ones of the receiver query parameters plus the members of the
+// groups of the group query parameter or, when none is given, the ones
+// mapped to the Alertmanager receiver of the payload, or else the ones of
+// the first routing rule applying at that time, which is also returned, or
+// else the default ones, resolved by resolveReceivers
+func requestReceivers(o *Config, args *fasthttp.Args, alertmanagerReceiver string, now time.Time) ([]string, *Route, error) {
+	var (
+		receivers []string
+		r         *Route
+	)
+	if args.Has("receiver") {
+		// the parameter can be repeated as well as comma separated
+		for _, value := range args.PeekMulti("receiver") {
+			for _, receiver := range SplitList(string(value)) {
+				normalized, err := normalizeReceiver(o, receiver)
+				if err != nil {
+					return nil, nil, err
+				}
+				receivers = append(receivers, normalized)
+			}
+		}
+	} else if !args.Has("group") {
+		if mapped, ok := o.ReceiverMap[alertmanagerReceiver]; ok {
+			receivers = append(receivers, mapped...)
+		} else if r = matchRoute(o.Routes, routingTime(o, now), o.Holidays); r != nil {
+			receivers = append(receivers, r.Receivers...)
+		} else {
+			receivers = SplitList(o.Receiver)
+		}
+	}
+
+	for _, name := range SplitList(string(args.Peek("group"))) {
+		members, ok := o.Groups[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown group %q", name)
+		}
+		receivers = append(receivers, members...)
+	}
+
+	return dedupe(resolveReceivers(o, receivers)), r, nil
+}
+
+// receiverPunctuation is stripped from the numbers of the receiver query
+// parameter, e.g. "+1 (555) 010-0001"
+var receiverPunctuation = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// normalizeReceiver validates a receiver of the receiver query parameter,
+// either a contact name or an E.164 number, possibly on WhatsApp or in the
+// national format of DEFAULT_COUNTRY, and strips the punctuation of its
+// number
+func normalizeReceiver(o *Config, receiver string) (string, error) {
+	if _, ok := o.Contacts[receiver]; ok {
+		return receiver, nil
+	}
+
+	number := receiverPunctuation.Replace(strings.TrimPrefix(receiver, whatsAppPrefix))
+	if o.DefaultCountry != "" {
+		number = toE164(number, o.DefaultCountry)
+	}
+	if !validNumber(number) {
+		return "", fmt.Errorf("invalid receiver %q, expecting a contact or an E.164 number such as +15550001", receiver)
+	}
+	if strings.HasPrefix(receiver, whatsAppPrefix) {
+		return whatsAppPrefix + number, nil
+	}
+	return number, nil
+}
+
+// validNumber reports whether a number is in the E.164 format: a plus sign
+// followed by at most 15 digits
+func validNumber(number string) bool {
+	if len(number) < 2 || len(number) > 16 || number[0] != '+' {
+		return false
+	}
+	for _, c := range number[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// routingTime returns now in the routing time zone, UTC by default
+func routingTime(o *Config, now time.Time) time.Time {
+	if o.RoutingLocation == nil {
+		return now.UTC()
+	}
+	return now.In(o.RoutingLocation)
+}
+
+// dedupe returns the items without duplicates, in their original order
+func dedupe(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	unique := make([]string, 0, len(items))
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
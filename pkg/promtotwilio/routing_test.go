@@ -0,0 +1,134 @@
+package promtotwilio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups("db-team=+100, +200; net-team=+300;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{"db-team": {"+100", "+200"}, "net-team": {"+300"}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("ParseGroups() == %v, want %v", groups, expected)
+	}
+
+	for _, invalid := range []string{"db-team", "=+100", "db-team="} {
+		if _, err := ParseGroups(invalid); err == nil {
+			t.Errorf("ParseGroups(%q) didn't fail", invalid)
+		}
+	}
+}
+
+func TestRequestReceivers(t *testing.T) {
+	o := &Config{
+		Receiver: "+100",
+		Groups:   map[string][]string{"db-team": {"+200", "+300"}, "net-team": {"+300", "+400"}},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"+100"}},
+		{"receiver=%2B500,%2B600", []string{"+500", "+600"}},
+		{"group=db-team", []string{"+200", "+300"}},
+		{"group=db-team,net-team", []string{"+200", "+300", "+400"}},
+		{"receiver=%2B500&group=net-team", []string{"+500", "+300", "+400"}},
+		{"receiver=%2B500&receiver=%2B600,%2B700", []string{"+500", "+600", "+700"}},
+		{"receiver=%2B1%20(555)%20010-0001&receiver=whatsapp:%2B33.6.12", []string{"+15550100001", "whatsapp:+33612"}},
+	}
+
+	for _, test := range tests {
+		args := &fasthttp.Args{}
+		args.Parse(test.query)
+		receivers, _, err := requestReceivers(o, args, "", time.Now())
+		if err != nil || !reflect.DeepEqual(receivers, test.expected) {
+			t.Errorf("requestReceivers(%q) == %v, %v, want %v", test.query, receivers, err, test.expected)
+		}
+	}
+
+	args := &fasthttp.Args{}
+	args.Parse("group=unknown")
+	if _, _, err := requestReceivers(o, args, "", time.Now()); err == nil {
+		t.Errorf("requestReceivers() didn't fail for an unknown group")
+	}
+}
+
+func TestRequestReceiversAlertmanagerReceiver(t *testing.T) {
+	o := &Config{
+		Receiver:    "+100",
+		Groups:      map[string][]string{"db-team": {"+200"}},
+		ReceiverMap: map[string][]string{"sms-db": {"+300", "+400"}},
+	}
+
+	tests := []struct {
+		query                string
+		alertmanagerReceiver string
+		expected             []string
+	}{
+		{"", "sms-db", []string{"+300", "+400"}},
+		{"", "sms-unknown", []string{"+100"}},
+		{"receiver=%2B500", "sms-db", []string{"+500"}},
+		{"group=db-team", "sms-db", []string{"+200"}},
+	}
+
+	for _, test := range tests {
+		args := &fasthttp.Args{}
+		args.Parse(test.query)
+		receivers, _, err := requestReceivers(o, args, test.alertmanagerReceiver, time.Now())
+		if err != nil || !reflect.DeepEqual(receivers, test.expected) {
+			t.Errorf("requestReceivers(%q, %q) == %v, %v, want %v", test.query, test.alertmanagerReceiver, receivers, err, test.expected)
+		}
+	}
+}
+
+func TestRequestReceiversRoutes(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	business := &Route{Days: "mon-fri", Hours: "09:00-18:00", Receivers: []string{"+300"}}
+	if err := business.init(); err != nil {
+		t.Fatal(err)
+	}
+	o := &Config{
+		Receiver:        "+100",
+		ReceiverMap:     map[string][]string{"sms-db": {"+200"}},
+		Routes:          []*Route{business},
+		RoutingLocation: paris,
+	}
+
+	tests := []struct {
+		time                 string
+		alertmanagerReceiver string
+		expected             []string
+	}{
+		{"2019-01-07T08:30:00Z", "", []string{"+300"}}, // 09:30 in Paris
+		{"2019-01-07T17:30:00Z", "", []string{"+100"}}, // 18:30 in Paris
+		{"2019-01-07T08:30:00Z", "sms-db", []string{"+200"}},
+	}
+
+	for _, test := range tests {
+		now, _ := time.Parse(time.RFC3339, test.time)
+		receivers, _, err := requestReceivers(o, &fasthttp.Args{}, test.alertmanagerReceiver, now)
+		if err != nil || !reflect.DeepEqual(receivers, test.expected) {
+			t.Errorf("requestReceivers() at %s == %v, %v, want %v", test.time, receivers, err, test.expected)
+		}
+	}
+}
+
+func TestRequestReceiversInvalid(t *testing.T) {
+	for _, query := range []string{"receiver=15550001", "receiver=%2B500&receiver=bob", "receiver=%2B12345678901234567"} {
+		args := &fasthttp.Args{}
+		args.Parse(query)
+		if _, _, err := requestReceivers(&Config{}, args, "", time.Now()); err == nil {
+			t.Errorf("requestReceivers(%q) accepted invalid receivers", query)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package promtotwilio
+
+import (
+	"strings"
+	"sync"
+)
+
+// senderPool spreads the receivers over several sender numbers, round-robin,
+// and keeps sending to a receiver from the same number so its replies always
+// reach the same inbound webhook
+type senderPool struct {
+	senders []string
+
+	mu       sync.Mutex
+	next     int
+	assigned map[string]string
+}
+
+func newSenderPool(senders []string) *senderPool {
+	return &senderPool{senders: senders, assigned: make(map[string]string)}
+}
+
+// pick returns the sender number of the receiver
+func (p *senderPool) pick(receiver string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sender, ok := p.assigned[receiver]; ok {
+		return sender
+	}
+	sender := p.senders[p.next%len(p.senders)]
+	p.next++
+	p.assigned[receiver] = sender
+	return sender
+}
+
+// countryCode returns the longest of the calling codes, without the plus
+// sign, the E.164 number of the receiver starts with, or an empty string
+func countryCode(codes map[string]*senderPool, receiver string) string {
+	number := strings.TrimPrefix(receiver, "+")
+	// Calling codes are up to three digits long
+	for n := 3; n > 0; n-- {
+		if len(number) > n {
+			if _, ok := codes[number[:n]]; ok {
+				return number[:n]
+			}
+		}
+	}
+	return ""
+}
+
+// maskNumber hides the digits of a phone number but the last four, so
+// responses and logs don't leak the numbers of the receivers
+func maskNumber(number string) string {
+	masked := []byte(number)
+	for i := 0; i < len(masked)-4; i++ {
+		if masked[i] >= '0' && masked[i] <= '9' {
+			masked[i] = '*'
+		}
+	}
+	return string(masked)
+}
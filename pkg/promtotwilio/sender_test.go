@@ -0,0 +1,57 @@
+package promtotwilio
+
+import "testing"
+
+func TestSenderPool(t *testing.T) {
+	p := newSenderPool([]string{"+100", "+101"})
+	tests := []struct {
+		receiver string
+		want     string
+	}{
+		{"+200", "+100"},
+		{"+201", "+101"},
+		{"+202", "+100"},
+		{"+201", "+101"},
+		{"+200", "+100"},
+	}
+	for _, test := range tests {
+		if got := p.pick(test.receiver); got != test.want {
+			t.Errorf("pick(%q) == %q, want %q", test.receiver, got, test.want)
+		}
+	}
+}
+
+func TestCountryCode(t *testing.T) {
+	codes := map[string]*senderPool{"1": nil, "44": nil, "1242": nil, "353": nil}
+	tests := []struct {
+		receiver string
+		want     string
+	}{
+		{"+15550001", "1"},
+		{"+447700900000", "44"},
+		{"+353851234567", "353"},
+		{"+33612345678", ""},
+		{"+1", ""},
+	}
+	for _, test := range tests {
+		if got := countryCode(codes, test.receiver); got != test.want {
+			t.Errorf("countryCode(%q) == %q, want %q", test.receiver, got, test.want)
+		}
+	}
+}
+
+func TestMaskNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"+15550001", "+****0001"},
+		{"whatsapp:+447700900000", "whatsapp:+********0000"},
+		{"+200", "+200"},
+	}
+	for _, test := range tests {
+		if got := maskNumber(test.number); got != test.want {
+			t.Errorf("maskNumber(%q) == %q, want %q", test.number, got, test.want)
+		}
+	}
+}
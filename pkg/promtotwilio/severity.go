@@ -0,0 +1,31 @@
+package promtotwilio
+
+import (
+	"sort"
+	"strings"
+)
+
+// severities are the common values of the severity label, most severe
+// first
+var severities = []string{"critical", "error", "warning", "info"}
+
+// severityRank orders the severities, most severe first, the unknown ones
+// and alerts without a severity coming last
+func severityRank(severity string) int {
+	severity = strings.ToLower(severity)
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(severities)
+}
+
+// sortBySeverity orders the alerts of a payload by severity, most severe
+// first, so that their messages are sent first and make the first lines of
+// the digests. Alerts of the same severity keep their order.
+func sortBySeverity(meta *PayloadMeta, alerts []selectedAlert) {
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return severityRank(meta.label(alerts[i].raw, "severity")) < severityRank(meta.label(alerts[j].raw, "severity"))
+	})
+}
@@ -0,0 +1,31 @@
+package promtotwilio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortBySeverity(t *testing.T) {
+	meta := &PayloadMeta{CommonLabels: []byte(`{"team": "db"}`)}
+	var alerts []selectedAlert
+	for _, alert := range []string{
+		`{"labels": {"alertname": "A", "severity": "info"}}`,
+		`{"labels": {"alertname": "B"}}`,
+		`{"labels": {"alertname": "C", "severity": "warning"}}`,
+		`{"labels": {"alertname": "D", "severity": "CRITICAL"}}`,
+		`{"labels": {"alertname": "E", "severity": "page"}}`,
+		`{"labels": {"alertname": "F", "severity": "warning"}}`,
+		`{"labels": {"alertname": "G", "severity": "critical"}}`,
+	} {
+		alerts = append(alerts, selectedAlert{raw: []byte(alert)})
+	}
+
+	sortBySeverity(meta, alerts)
+	var names []string
+	for _, alert := range alerts {
+		names = append(names, meta.label(alert.raw, "alertname"))
+	}
+	if want := []string{"D", "G", "C", "F", "A", "B", "E"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sortBySeverity() == %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,140 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// silencesTimeout bounds the time the dashboard waits for Alertmanager
+const silencesTimeout = 2 * time.Second
+
+// dashboardSilence is an active silence of Alertmanager, or an alert storm,
+// flapping alert or exceeded budget muting messages in promtotwilio
+type dashboardSilence struct {
+	Source   string
+	Matchers string
+	// Ends is zero for the silences lasting until their cause ends
+	Ends    time.Time
+	Comment string
+}
+
+// alertmanagerSilence is a silence of the Alertmanager API v2
+type alertmanagerSilence struct {
+	Status struct {
+		State string `json:"state"`
+	} `json:"status"`
+	Matchers []struct {
+		Name    string `json:"name"`
+		Value   string `json:"value"`
+		IsRegex bool   `json:"isRegex"`
+		// IsEqual is missing before Alertmanager 0.22
+		IsEqual *bool `json:"isEqual"`
+	} `json:"matchers"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+}
+
+// fetchSilences returns the active silences of the Alertmanager at url,
+// ending first first
+func fetchSilences(client *http.Client, url string) ([]dashboardSilence, error) {
+	res, err := client.Get(strings.TrimRight(url, "/") + "/api/v2/silences")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+	var silences []alertmanagerSilence
+	if err := json.NewDecoder(res.Body).Decode(&silences); err != nil {
+		return nil, err
+	}
+
+	var active []dashboardSilence
+	for _, s := range silences {
+		if s.Status.State != "active" {
+			continue
+		}
+		matchers := make([]string, 0, len(s.Matchers))
+		for _, m := range s.Matchers {
+			negative := m.IsEqual != nil && !*m.IsEqual
+			op := "="
+			switch {
+			case m.IsRegex && negative:
+				op = "!~"
+			case m.IsRegex:
+				op = "=~"
+			case negative:
+				op = "!="
+			}
+			matchers = append(matchers, fmt.Sprintf("%s%s%q", m.Name, op, m.Value))
+		}
+		comment := s.Comment
+		if s.CreatedBy != "" {
+			comment = s.CreatedBy + ": " + comment
+		}
+		active = append(active, dashboardSilence{
+			Source:   "alertmanager",
+			Matchers: strings.Join(matchers, ", "),
+			Ends:     s.EndsAt,
+			Comment:  comment,
+		})
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Ends.Before(active[j].Ends) })
+	return active, nil
+}
+
+// silences returns the active silences of Alertmanager, when
+// ALERTMANAGER_URL is set, followed by the messages muted by promtotwilio
+func (m OptionsWithHandler) silences() ([]dashboardSilence, error) {
+	var (
+		silences []dashboardSilence
+		err      error
+	)
+	if m.Options.AlertmanagerURL != "" {
+		silences, err = fetchSilences(&http.Client{Timeout: silencesTimeout}, m.Options.AlertmanagerURL)
+	}
+
+	state := m.snapshotState()
+	var muted []dashboardSilence
+	for receiver, storm := range state.Storms {
+		if storm.Storming {
+			muted = append(muted, dashboardSilence{
+				Source:   "promtotwilio",
+				Matchers: "receiver=" + maskNumber(receiver),
+				Comment:  fmt.Sprintf("alert storm, %d messages suppressed", storm.Suppressed),
+			})
+		}
+	}
+	for key, flap := range state.Flaps {
+		if flap.Flapping {
+			muted = append(muted, dashboardSilence{
+				Source:   "promtotwilio",
+				Matchers: "alert " + key,
+				Comment:  fmt.Sprintf("flapping, %d transitions in %s", len(flap.Transitions), m.Options.FlapWindow),
+			})
+		}
+	}
+	sort.Slice(muted, func(i, j int) bool { return muted[i].Matchers < muted[j].Matchers })
+	if m.Budget != nil && m.Budget.usedUp() {
+		matchers := "all alerts"
+		if len(m.Options.BudgetCritical) > 0 {
+			critical := make([]string, len(m.Options.BudgetCritical))
+			for i, matcher := range m.Options.BudgetCritical {
+				critical[i] = matcher.String()
+			}
+			matchers = "all but " + strings.Join(critical, ", ")
+		}
+		muted = append(muted, dashboardSilence{
+			Source:   "promtotwilio",
+			Matchers: matchers,
+			Comment:  fmt.Sprintf("SMS budget of %s exceeded this month", describeBudget(m.Options)),
+		})
+	}
+	return append(silences, muted...), err
+}
@@ -0,0 +1,194 @@
+package promtotwilio
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// sqsWaitTime is how long a receive waits for messages, at most 20s
+	sqsWaitTime = 20 * time.Second
+	// sqsErrorBackoff is the delay after a failed receive
+	sqsErrorBackoff = 5 * time.Second
+)
+
+// sqsMessage is a message received from SQS
+type sqsMessage struct {
+	MessageID     string `xml:"MessageId"`
+	ReceiptHandle string `xml:"ReceiptHandle"`
+	Body          string `xml:"Body"`
+}
+
+type sqsReceiveResponse struct {
+	Messages []sqsMessage `xml:"ReceiveMessageResult>Message"`
+}
+
+type sqsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// sqsPoller drains the Alertmanager payloads of an SQS queue, possibly
+// published through SNS. The messages whose payload couldn't be sent are
+// left in the queue, to be received again once their visibility timeout
+// expires.
+type sqsPoller struct {
+	queueURL   string
+	region     string
+	creds      AWSCredentials
+	batchSize  int
+	visibility time.Duration
+	client     *http.Client
+	// handle sends a payload, returning the status code /send would
+	handle func(payload []byte) int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newSQSPoller(queueURL, region string, creds AWSCredentials, batchSize int, visibility time.Duration, handle func(payload []byte) int) *sqsPoller {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sqsPoller{
+		queueURL:   queueURL,
+		region:     region,
+		creds:      creds,
+		batchSize:  batchSize,
+		visibility: visibility,
+		client:     &http.Client{Timeout: sqsWaitTime + 10*time.Second},
+		handle:     handle,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+// sqsRegion returns the region of a queue URL such as
+// https://sqs.eu-west-1.amazonaws.com/123456789012/alerts
+func sqsRegion(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) >= 4 && parts[0] == "sqs" {
+		return parts[1]
+	}
+	return ""
+}
+
+// start polls the queue until Stop is called
+func (p *sqsPoller) start() {
+	go func() {
+		defer close(p.done)
+		for {
+			if err := p.poll(); err != nil {
+				if p.ctx.Err() != nil {
+					return
+				}
+				log.Errorf("Error receiving SQS messages, retrying in %s: %v", sqsErrorBackoff, err)
+				select {
+				case <-time.After(sqsErrorBackoff):
+				case <-p.ctx.Done():
+					return
+				}
+			}
+			if p.ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling, once the payloads being sent are
+func (p *sqsPoller) Stop() {
+	p.cancel()
+	<-p.done
+}
+
+// poll receives a batch of messages and sends their payloads
+func (p *sqsPoller) poll() error {
+	body, err := p.call(url.Values{
+		"Action":              {"ReceiveMessage"},
+		"MaxNumberOfMessages": {strconv.Itoa(p.batchSize)},
+		"VisibilityTimeout":   {strconv.Itoa(int(p.visibility / time.Second))},
+		"WaitTimeSeconds":     {strconv.Itoa(int(sqsWaitTime / time.Second))},
+	})
+	if err != nil {
+		return err
+	}
+	var response sqsReceiveResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return err
+	}
+
+	for _, message := range response.Messages {
+		code := p.handle(unwrapSNS([]byte(message.Body)))
+		if code >= http.StatusInternalServerError {
+			log.Warnf("SQS message %s not sent, leaving it in the queue", message.MessageID)
+			continue
+		}
+		// drops the messages sent, and the invalid ones which never will be
+		if _, err := p.call(url.Values{
+			"Action":        {"DeleteMessage"},
+			"ReceiptHandle": {message.ReceiptHandle},
+		}); err != nil {
+			log.Errorf("Error deleting SQS message %s: %v", message.MessageID, err)
+		}
+	}
+	return nil
+}
+
+// call sends a signed request of the SQS query API to the queue
+func (p *sqsPoller) call(params url.Values) ([]byte, error) {
+	params.Set("Version", "2012-11-05")
+	body := []byte(params.Encode())
+	req, err := http.NewRequest(http.MethodPost, p.queueURL, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(p.ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	signV4(req, body, p.creds, p.region, "sqs", time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e sqsErrorResponse
+		if xml.Unmarshal(content, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("%s: %s", e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return content, nil
+}
+
+// unwrapSNS returns the message of an SNS notification, or the body itself
+// when published with raw message delivery or straight to the queue
+func unwrapSNS(body []byte) []byte {
+	var notification struct {
+		Type    string
+		Message string
+	}
+	if json.Unmarshal(body, &notification) == nil && notification.Type == "Notification" {
+		return []byte(notification.Message)
+	}
+	return body
+}
@@ -0,0 +1,67 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQSPoller(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("unsigned request %v", r.Header)
+		}
+		switch r.FormValue("Action") {
+		case "ReceiveMessage":
+			if r.FormValue("MaxNumberOfMessages") != "5" || r.FormValue("VisibilityTimeout") != "60" {
+				t.Errorf("unexpected form %v", r.Form)
+			}
+			w.Write([]byte(`<ReceiveMessageResponse><ReceiveMessageResult>
+<Message><MessageId>1</MessageId><ReceiptHandle>h1</ReceiptHandle><Body>{"Type": "Notification", "Message": "{\"status\": \"firing\"}"}</Body></Message>
+<Message><MessageId>2</MessageId><ReceiptHandle>h2</ReceiptHandle><Body>{"status": "failing"}</Body></Message>
+</ReceiveMessageResult></ReceiveMessageResponse>`))
+		case "DeleteMessage":
+			deleted = append(deleted, r.FormValue("ReceiptHandle"))
+			w.Write([]byte(`<DeleteMessageResponse></DeleteMessageResponse>`))
+		}
+	}))
+	defer server.Close()
+
+	var payloads []string
+	p := newSQSPoller(server.URL+"/123456789012/alerts", "eu-west-1", AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, 5, time.Minute, func(payload []byte) int {
+		payloads = append(payloads, string(payload))
+		if strings.Contains(string(payload), "failing") {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	})
+	if err := p.poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{`{"status": "firing"}`, `{"status": "failing"}`}; !reflect.DeepEqual(payloads, expected) {
+		t.Errorf("payloads == %q, want %q", payloads, expected)
+	}
+	if expected := []string{"h1"}; !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("deleted == %q, want %q", deleted, expected)
+	}
+}
+
+func TestSQSRegion(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://sqs.eu-west-1.amazonaws.com/123456789012/alerts", "eu-west-1"},
+		{"http://localhost:9324/queue/alerts", ""},
+	}
+	for _, test := range tests {
+		if got := sqsRegion(test.url); got != test.expected {
+			t.Errorf("sqsRegion(%q) == %q, want %q", test.url, got, test.expected)
+		}
+	}
+}
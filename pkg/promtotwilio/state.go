@@ -0,0 +1,177 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// persistedState is the state saved to STATE_FILE, so that a restart in the
+// middle of an incident doesn't send the suppressed messages again
+type persistedState struct {
+	Responses map[string]persistedResponse `json:"responses,omitempty"`
+	Storms    map[string]persistedStorm    `json:"storms,omitempty"`
+	Budget    *persistedBudget             `json:"budget,omitempty"`
+	Notified  map[string]time.Time         `json:"notified,omitempty"`
+	Flaps     map[string]persistedFlap     `json:"flaps,omitempty"`
+}
+
+type persistedResponse struct {
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type"`
+	Body        string    `json:"body"`
+	Expires     time.Time `json:"expires"`
+}
+
+type persistedStorm struct {
+	Attempts   []time.Time `json:"attempts"`
+	Storming   bool        `json:"storming"`
+	Suppressed int         `json:"suppressed"`
+}
+
+type persistedFlap struct {
+	Status      string      `json:"status"`
+	Transitions []time.Time `json:"transitions"`
+	Flapping    bool        `json:"flapping"`
+	Seen        time.Time   `json:"seen"`
+}
+
+type persistedBudget struct {
+	Month    string  `json:"month"`
+	Used     int     `json:"used"`
+	Spent    float64 `json:"spent,omitempty"`
+	Notified bool    `json:"notified"`
+}
+
+// loadState reads the state file, returning nil when it doesn't exist yet
+func loadState(path string) (*persistedState, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &persistedState{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveState replaces the state file atomically
+func saveState(path string, state *persistedState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// snapshotState returns the state of the duplicate detection, alert storm
+// protection, budget, notified alerts and flap detection
+func (m OptionsWithHandler) snapshotState() *persistedState {
+	state := &persistedState{}
+	if m.Idempotency != nil {
+		state.Responses = m.Idempotency.snapshot()
+	}
+	if m.Storm != nil {
+		state.Storms = m.Storm.snapshot()
+	}
+	if m.Budget != nil {
+		state.Budget = m.Budget.snapshot()
+	}
+	if m.Notified != nil {
+		state.Notified = m.Notified.snapshot()
+	}
+	if m.Flaps != nil {
+		state.Flaps = m.Flaps.snapshot()
+	}
+	return state
+}
+
+// restoreState restores a state saved by snapshotState
+func (m OptionsWithHandler) restoreState(state *persistedState) {
+	if m.Idempotency != nil {
+		m.Idempotency.restore(state.Responses)
+	}
+	if m.Storm != nil {
+		m.Storm.restore(state.Storms)
+	}
+	if m.Budget != nil && state.Budget != nil {
+		m.Budget.restore(state.Budget)
+	}
+	if m.Notified != nil {
+		m.Notified.restore(state.Notified)
+	}
+	if m.Flaps != nil {
+		m.Flaps.restore(state.Flaps)
+	}
+}
+
+// stateSaver saves the state every interval and on Stop
+type stateSaver struct {
+	path     string
+	interval time.Duration
+	snapshot func() *persistedState
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newStateSaver(path string, interval time.Duration, snapshot func() *persistedState) *stateSaver {
+	return &stateSaver{
+		path:     path,
+		interval: interval,
+		snapshot: snapshot,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start saves the state every interval until Stop is called
+func (s *stateSaver) start() {
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.save()
+			case <-s.stop:
+				s.save()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the saver after saving the state a last time
+func (s *stateSaver) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *stateSaver) save() {
+	if err := saveState(s.path, s.snapshot()); err != nil {
+		log.Errorf("Error saving state: %v", err)
+	}
+}
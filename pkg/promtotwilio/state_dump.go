@@ -0,0 +1,83 @@
+package promtotwilio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// lastErrorsSize is the number of recent failures in a state dump
+const lastErrorsSize = 5
+
+// StateDump describes the internal state, e.g. to be logged for debugging
+type StateDump struct {
+	Time             time.Time      `json:"time"`
+	InFlightRequests int64          `json:"in_flight_requests"`
+	Queues           map[string]int `json:"queues"`
+	IdempotencyKeys  int            `json:"idempotency_keys"`
+	NotifiedAlerts   int            `json:"notified_alerts"`
+	FlappingAlerts   int            `json:"flapping_alerts"`
+	// StormingReceivers are the masked receivers whose messages are
+	// suppressed by the alert storm protection
+	StormingReceivers []string `json:"storming_receivers"`
+	BudgetUsed        *int     `json:"budget_used,omitempty"`
+	// BudgetSpent is what the messages sent this month cost, estimated for
+	// the ones Twilio didn't price yet
+	BudgetSpent *float64 `json:"budget_spent,omitempty"`
+	DeadLetters int      `json:"dead_letters"`
+	Leader      *bool    `json:"leader,omitempty"`
+	// LastErrors are the last messages which couldn't be sent, most recent
+	// first
+	LastErrors []HistoryEntry `json:"last_errors"`
+}
+
+// DumpState returns the internal state
+func (m OptionsWithHandler) DumpState() StateDump {
+	state := m.snapshotState()
+	dump := StateDump{
+		Time:              time.Now(),
+		InFlightRequests:  atomic.LoadInt64(&inFlightRequests),
+		Queues:            make(map[string]int),
+		IdempotencyKeys:   len(state.Responses),
+		NotifiedAlerts:    len(state.Notified),
+		StormingReceivers: []string{},
+		LastErrors:        []HistoryEntry{},
+	}
+	if m.Batcher != nil {
+		dump.Queues["batch"] = m.Batcher.depth()
+	}
+	if m.Delayer != nil {
+		dump.Queues["delay"] = m.Delayer.depth()
+	}
+	for _, flap := range state.Flaps {
+		if flap.Flapping {
+			dump.FlappingAlerts++
+		}
+	}
+	for receiver, storm := range state.Storms {
+		if storm.Storming {
+			dump.StormingReceivers = append(dump.StormingReceivers, maskNumber(receiver))
+		}
+	}
+	if state.Budget != nil {
+		dump.BudgetUsed = &state.Budget.Used
+		dump.BudgetSpent = &state.Budget.Spent
+	}
+	if m.DeadLetters != nil {
+		dump.DeadLetters = len(m.DeadLetters.since(time.Time{}))
+	}
+	if m.Leader != nil {
+		leading := m.Leader.leading()
+		dump.Leader = &leading
+	}
+	if m.History != nil {
+		for _, entry := range m.History.list() {
+			if entry.Error != "" {
+				dump.LastErrors = append(dump.LastErrors, entry)
+				if len(dump.LastErrors) == lastErrorsSize {
+					break
+				}
+			}
+		}
+	}
+	return dump
+}
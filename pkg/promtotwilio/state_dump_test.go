@@ -0,0 +1,24 @@
+package promtotwilio
+
+import (
+	"testing"
+)
+
+func TestDumpState(t *testing.T) {
+	m := OptionsWithHandler{
+		Options: &Config{Sender: "+100", Receiver: "+200", Annotations: []string{"summary"}},
+		Client:  &partialTwilioClient{failing: "+3"},
+		History: newMessageHistory(10),
+		Budget:  newBudget(100, 0),
+	}
+	ctx := newSendRequestCtx("/send?receiver=%2B200,%2B300", `{"status": "firing", "alerts": [{"annotations": {"summary": "Disk full"}}]}`)
+	m.HandleFastHTTP(ctx)
+
+	dump := m.DumpState()
+	if len(dump.LastErrors) != 1 || dump.LastErrors[0].Receiver != "+300" {
+		t.Errorf("LastErrors == %+v", dump.LastErrors)
+	}
+	if dump.BudgetUsed == nil || *dump.BudgetUsed != 1 {
+		t.Errorf("BudgetUsed == %v, want 1", dump.BudgetUsed)
+	}
+}
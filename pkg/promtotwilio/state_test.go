@@ -0,0 +1,62 @@
+package promtotwilio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promtotwilio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	if state, err := loadState(path); state != nil || err != nil {
+		t.Fatalf("loadState() of a missing file == %v, %v", state, err)
+	}
+
+	noop := func(receiver, text string) error { return nil }
+	before := OptionsWithHandler{
+		Idempotency: newIdempotencyCache(time.Minute),
+		Storm:       newStormGuard(1, time.Minute, noop),
+		Budget:      newBudget(10, 0),
+	}
+	before.Idempotency.do("a", &fasthttp.RequestCtx{}, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString(`{"sent":1}`)
+	})
+	before.Storm.allow("+200")
+	before.Storm.allow("+200")
+	before.Budget.spend(0.0075)
+	if err := saveState(path, before.snapshotState()); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := OptionsWithHandler{
+		Idempotency: newIdempotencyCache(time.Minute),
+		Storm:       newStormGuard(1, time.Minute, noop),
+		Budget:      newBudget(10, 0),
+	}
+	after.restoreState(state)
+
+	ctx := &fasthttp.RequestCtx{}
+	if !after.Idempotency.do("a", ctx, func(ctx *fasthttp.RequestCtx) {}) || string(ctx.Response.Body()) != `{"sent":1}` {
+		t.Errorf("response not restored")
+	}
+	if ok, started := after.Storm.allow("+200"); ok || started {
+		t.Errorf("allow() == %v, %v after restore, want false, false", ok, started)
+	}
+	if got := after.Budget.snapshot(); got.Used != 1 || got.Spent != 0.0075 {
+		t.Errorf("budget == %d, %v after restore, want 1, 0.0075", got.Used, got.Spent)
+	}
+}
@@ -0,0 +1,165 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// stormMinWindow is the shortest window, the ends of the storms being
+// checked every quarter of it
+const stormMinWindow = time.Second
+
+// stormState tracks the messages of a receiver
+type stormState struct {
+	// attempts are the times messages were sent or suppressed in the window
+	attempts   []time.Time
+	storming   bool
+	suppressed int
+}
+
+// stormGuard suppresses the messages of a receiver once more than
+// threshold of them would be sent within window, until the storm ends
+type stormGuard struct {
+	threshold int
+	window    time.Duration
+	send      func(receiver, text string) error
+	now       func() time.Time
+
+	mu        sync.Mutex
+	receivers map[string]*stormState
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newStormGuard(threshold int, window time.Duration, send func(receiver, text string) error) *stormGuard {
+	if window < stormMinWindow {
+		window = stormMinWindow
+	}
+	return &stormGuard{
+		threshold: threshold,
+		window:    window,
+		send:      send,
+		now:       time.Now,
+		receivers: make(map[string]*stormState),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// start checks periodically for storms that ended until Stop is called
+func (g *stormGuard) start() {
+	go func() {
+		defer close(g.done)
+		ticker := time.NewTicker(g.window / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.checkEnded()
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops checking for storms that ended
+func (g *stormGuard) Stop() {
+	close(g.stop)
+	<-g.done
+}
+
+// allow records a message for the receiver and reports whether it may be
+// sent. started is true for the first suppressed message of a storm.
+func (g *stormGuard) allow(receiver string) (ok bool, started bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, found := g.receivers[receiver]
+	if !found {
+		state = &stormState{}
+		g.receivers[receiver] = state
+	}
+
+	now := g.now()
+	state.attempts = append(g.prune(state.attempts, now), now)
+	if state.storming {
+		state.suppressed++
+		return false, false
+	}
+	if len(state.attempts) > g.threshold {
+		state.storming = true
+		state.suppressed++
+		return false, true
+	}
+	return true, false
+}
+
+// checkEnded sends a summary to the receivers whose storm ended, i.e.
+// which are back under the threshold
+func (g *stormGuard) checkEnded() {
+	now := g.now()
+	ended := make(map[string]int)
+
+	g.mu.Lock()
+	for receiver, state := range g.receivers {
+		state.attempts = g.prune(state.attempts, now)
+		if state.storming && len(state.attempts) <= g.threshold {
+			ended[receiver] = state.suppressed
+			state.storming = false
+			state.suppressed = 0
+		}
+		if !state.storming && len(state.attempts) == 0 {
+			delete(g.receivers, receiver)
+		}
+	}
+	g.mu.Unlock()
+
+	for receiver, suppressed := range ended {
+		text := fmt.Sprintf("Alert storm over: %d alerts suppressed, see Alertmanager", suppressed)
+		if err := g.send(receiver, text); err != nil {
+			log.Errorf("Error sending alert storm summary: %v", err)
+		}
+	}
+}
+
+// prune drops the attempts older than the window
+func (g *stormGuard) prune(attempts []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(attempts) && now.Sub(attempts[i]) > g.window {
+		i++
+	}
+	return attempts[i:]
+}
+
+// snapshot returns the state of the receivers
+func (g *stormGuard) snapshot() map[string]persistedStorm {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	storms := make(map[string]persistedStorm, len(g.receivers))
+	for receiver, state := range g.receivers {
+		storms[receiver] = persistedStorm{
+			Attempts:   append([]time.Time(nil), state.attempts...),
+			Storming:   state.storming,
+			Suppressed: state.suppressed,
+		}
+	}
+	return storms
+}
+
+// restore replaces the state of the receivers with a saved one
+func (g *stormGuard) restore(storms map[string]persistedStorm) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for receiver, storm := range storms {
+		g.receivers[receiver] = &stormState{
+			attempts:   storm.Attempts,
+			storming:   storm.Storming,
+			suppressed: storm.Suppressed,
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package promtotwilio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStormGuard(t *testing.T) {
+	var sent []string
+	g := newStormGuard(2, time.Minute, func(receiver, text string) error {
+		sent = append(sent, receiver+": "+text)
+		return nil
+	})
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	expected := []struct{ ok, started bool }{{true, false}, {true, false}, {false, true}, {false, false}, {false, false}}
+	for i, e := range expected {
+		if ok, started := g.allow("+100"); ok != e.ok || started != e.started {
+			t.Errorf("allow() #%d == %v, %v, want %v, %v", i, ok, started, e.ok, e.started)
+		}
+	}
+	if ok, _ := g.allow("+200"); !ok {
+		t.Errorf("allow() suppressed a message of another receiver")
+	}
+
+	g.checkEnded()
+	if len(sent) != 0 {
+		t.Fatalf("storm ended too early: %v", sent)
+	}
+
+	now = now.Add(2 * time.Minute)
+	g.checkEnded()
+	if len(sent) != 1 || sent[0] != "+100: Alert storm over: 3 alerts suppressed, see Alertmanager" {
+		t.Fatalf("unexpected summary %v", sent)
+	}
+	if ok, _ := g.allow("+100"); !ok {
+		t.Errorf("allow() still suppressing after the storm ended")
+	}
+}
+
+func TestStormGuardMinWindow(t *testing.T) {
+	for _, window := range []time.Duration{0, -time.Minute, time.Nanosecond} {
+		g := newStormGuard(2, window, func(receiver, text string) error { return nil })
+		if g.window != stormMinWindow {
+			t.Errorf("newStormGuard(%s).window == %s, want %s", window, g.window, stormMinWindow)
+		}
+		g.start()
+		g.Stop()
+	}
+}
@@ -0,0 +1,125 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	tmpl "github.com/swatto/promtotwilio/internal/template"
+)
+
+// defaultTemplateName is the template of a template directory used for the
+// alerts without a template of their own
+const defaultTemplateName = "default"
+
+// templateSet is the parsed content of a template directory
+type templateSet struct {
+	// signature identifies the files the set was parsed from
+	signature string
+	templates map[string]*template.Template
+}
+
+// TemplateDir loads the message templates of a directory, <alertname>.tmpl
+// files for some alerts and default.tmpl for the others, and reloads them
+// when they change
+type TemplateDir struct {
+	path     string
+	interval time.Duration
+	current  atomic.Value
+	// reloaded, when set, is called once the templates changed, with the
+	// error keeping the previous ones if any
+	reloaded func(err error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewTemplateDir loads the templates of the directory at path, checking for
+// changes every interval once started
+func NewTemplateDir(path string, interval time.Duration) (*TemplateDir, error) {
+	d := &TemplateDir{
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	d.current.Store(&templateSet{})
+	if _, err := d.reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// reload parses the templates again when the files changed, reporting
+// whether they did. The current templates are kept on errors.
+func (d *TemplateDir) reload() (bool, error) {
+	files, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return false, err
+	}
+
+	var signature strings.Builder
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".tmpl" {
+			fmt.Fprintf(&signature, "%s %d %d\n", f.Name(), f.Size(), f.ModTime().UnixNano())
+		}
+	}
+	if signature.String() == d.current.Load().(*templateSet).signature {
+		return false, nil
+	}
+
+	set := &templateSet{signature: signature.String(), templates: make(map[string]*template.Template)}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".tmpl" {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(d.path, f.Name()))
+		if err != nil {
+			return false, err
+		}
+		name := strings.TrimSuffix(f.Name(), ".tmpl")
+		t, err := tmpl.New(name, string(content))
+		if err != nil {
+			return false, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		set.templates[name] = t
+	}
+	d.current.Store(set)
+	return true, nil
+}
+
+// start checks for changes every interval until Stop is called
+func (d *TemplateDir) start() {
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				reloaded, err := d.reload()
+				if err != nil {
+					log.Errorf("Error reloading templates, keeping the previous ones: %v", err)
+				} else if reloaded {
+					log.Infof("Reloaded the templates of %s", d.path)
+				}
+				if (err != nil || reloaded) && d.reloaded != nil {
+					d.reloaded(err)
+				}
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops checking for changes
+func (d *TemplateDir) Stop() {
+	close(d.stop)
+	<-d.done
+}
@@ -0,0 +1,60 @@
+package promtotwilio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTemplateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promtotwilio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write("default.tmpl", "{{ .Annotations.summary }}", now)
+	write("HostDown.tmpl", "{{ .Labels.instance }} down", now)
+
+	d, err := NewTemplateDir(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &PayloadMeta{Status: "firing"}
+	o := &Config{TemplateDir: d}
+	hostDown := []byte(`{"labels": {"alertname": "HostDown", "instance": "db-1"}, "annotations": {"summary": "Host down"}}`)
+	diskFull := []byte(`{"labels": {"alertname": "DiskFull"}, "annotations": {"summary": "Disk full"}}`)
+	if got := formatMessage(o, meta, hostDown); got != "db-1 down" {
+		t.Errorf("formatMessage() == %q, want %q", got, "db-1 down")
+	}
+	if got := formatMessage(o, meta, diskFull); got != "Disk full" {
+		t.Errorf("formatMessage() == %q, want %q", got, "Disk full")
+	}
+
+	write("HostDown.tmpl", "{{ .Labels.instance ", now.Add(time.Second))
+	if _, err := d.reload(); err == nil {
+		t.Errorf("reload() of an invalid template succeeded")
+	}
+	if got := formatMessage(o, meta, hostDown); got != "db-1 down" {
+		t.Errorf("formatMessage() == %q after a failed reload, want %q", got, "db-1 down")
+	}
+
+	write("HostDown.tmpl", "{{ .Labels.instance }} is down", now.Add(2*time.Second))
+	if reloaded, err := d.reload(); !reloaded || err != nil {
+		t.Fatalf("reload() == %v, %v, want true, nil", reloaded, err)
+	}
+	if got := formatMessage(o, meta, hostDown); got != "db-1 is down" {
+		t.Errorf("formatMessage() == %q after reload, want %q", got, "db-1 is down")
+	}
+}
@@ -0,0 +1,87 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultRateLimitBackoff is how long the requests are held back after a
+// rate limited one without Retry-After
+const defaultRateLimitBackoff = time.Second
+
+// twilioThrottle holds back all the requests to the Twilio API once one was
+// rate limited, for the Retry-After requested by Twilio
+type twilioThrottle struct {
+	mu    sync.Mutex
+	until time.Time
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newTwilioThrottle() *twilioThrottle {
+	return &twilioThrottle{now: time.Now, sleep: time.Sleep}
+}
+
+// wait returns once the requests aren't held back anymore
+func (t *twilioThrottle) wait() {
+	t.mu.Lock()
+	d := t.until.Sub(t.now())
+	t.mu.Unlock()
+	if d > 0 {
+		t.sleep(d)
+	}
+}
+
+// hold holds back the requests for the delay, or a second when Twilio
+// didn't request one, at most for retryMaxBackoff
+func (t *twilioThrottle) hold(delay time.Duration) {
+	if delay <= 0 {
+		delay = defaultRateLimitBackoff
+	}
+	if delay > retryMaxBackoff {
+		delay = retryMaxBackoff
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := t.now().Add(delay); until.After(t.until) {
+		t.until = until
+		log.Warnf("Rate limited by Twilio, holding back the messages for %s", delay)
+	}
+}
+
+// throttledTwilioClient is a TwilioClient waiting out the rate limiting of
+// the Twilio API before sending the messages
+type throttledTwilioClient struct {
+	client   TwilioClient
+	throttle *twilioThrottle
+}
+
+func (c throttledTwilioClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	c.throttle.wait()
+	receipt, err := c.client.SendMessage(m)
+	if twilioErr, ok := err.(*TwilioError); ok && rateLimited(twilioErr) {
+		twilioRateLimitedTotal.Inc()
+		c.throttle.hold(twilioErr.RetryAfter)
+	}
+	return receipt, err
+}
+
+// rateLimited reports whether Twilio rejected a request because of its
+// rate limits
+func rateLimited(err *TwilioError) bool {
+	return err.Status == http.StatusTooManyRequests || err.Code == 20429
+}
+
+// backoff returns how long the requests to the Twilio API are still held
+// back, as of now
+func (t *twilioThrottle) backoff(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if d := t.until.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package promtotwilio
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestThrottledTwilioClient(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	var slept []time.Duration
+	throttle := newTwilioThrottle()
+	throttle.now = func() time.Time { return now }
+	throttle.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	fake := &fakeTwilioClient{err: &TwilioError{Status: http.StatusTooManyRequests, Code: 20429, RetryAfter: 3 * time.Second}}
+	client := throttledTwilioClient{fake, throttle}
+	before := testutil.ToFloat64(twilioRateLimitedTotal)
+
+	if _, err := client.SendMessage(&Message{To: "+200", Body: "Disk full"}); err == nil {
+		t.Fatal("SendMessage() succeeded, want the rate limiting error")
+	}
+	if got := testutil.ToFloat64(twilioRateLimitedTotal); got != before+1 {
+		t.Errorf("twilioRateLimitedTotal == %g, want %g", got, before+1)
+	}
+	if got := throttle.backoff(now); got != 3*time.Second {
+		t.Errorf("backoff() == %s, want 3s", got)
+	}
+
+	fake.err = nil
+	now = now.Add(time.Second)
+	if _, err := client.SendMessage(&Message{To: "+200", Body: "Disk full"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 1 || slept[0] != 2*time.Second {
+		t.Errorf("slept %v, want [2s]", slept)
+	}
+
+	now = now.Add(2 * time.Second)
+	client.SendMessage(&Message{To: "+200", Body: "Disk full"})
+	if len(slept) != 1 {
+		t.Errorf("slept %v once the backoff was over", slept)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"Sun, 13 Sep 2020 12:00:30 GMT", 30 * time.Second},
+		{"Sun, 13 Sep 2020 11:59:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("retryAfter(%q) == %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleHoldCapped(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	throttle := newTwilioThrottle()
+	throttle.now = func() time.Time { return now }
+
+	throttle.hold(24 * time.Hour)
+	if got := throttle.backoff(now); got != retryMaxBackoff {
+		t.Errorf("backoff() == %s after a Retry-After of a day, want %s", got, retryMaxBackoff)
+	}
+}
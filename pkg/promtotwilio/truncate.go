@@ -0,0 +1,131 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxMessageLength is the maximum length of a message body accepted
+// by Twilio, in characters
+const DefaultMaxMessageLength = 1600
+
+// DefaultEllipsis ends the truncated messages
+const DefaultEllipsis = "…"
+
+// Truncation strategies
+const (
+	// TruncateWord cuts the messages at the last space before their
+	// maximum length, or else like TruncateChar
+	TruncateWord = "word"
+	// TruncateChar cuts the messages at their maximum length
+	TruncateChar = "char"
+	// TruncateNone sends the messages whole, Twilio rejecting the ones over
+	// its maximum length
+	TruncateNone = "none"
+)
+
+// ValidTruncateStrategy reports whether a truncation strategy is known
+func ValidTruncateStrategy(strategy string) bool {
+	return strategy == TruncateWord || strategy == TruncateChar || strategy == TruncateNone
+}
+
+const (
+	zeroWidthJoiner = '\u200d'
+	// regional indicators are paired into flags
+	regionalIndicatorA = '\U0001f1e6'
+	regionalIndicatorZ = '\U0001f1ff'
+	// skin tone modifiers follow the emoji they apply to
+	skinToneLight = '\U0001f3fb'
+	skinToneDark  = '\U0001f3ff'
+)
+
+// truncateMessage shortens a message according to the truncation settings
+// of the configuration, ending it with the suffix, which is kept whole
+func truncateMessage(o *Config, s, suffix string) string {
+	if o.TruncateStrategy == TruncateNone {
+		return s + suffix
+	}
+	ellipsis := o.TruncateEllipsis
+	if ellipsis == "" {
+		ellipsis = DefaultEllipsis
+	}
+	max := o.MaxMessageLength
+	if max > 0 {
+		max -= utf8.RuneCountInString(suffix)
+		if max < 1 {
+			max = 1
+		}
+	}
+	return truncate(s, max, o.TruncateStrategy == TruncateWord, ellipsis) + suffix
+}
+
+// moreAlerts returns the suffix of a message telling that alerts were left
+// out of it, empty when none were
+func moreAlerts(n int) string {
+	switch {
+	case n <= 0:
+		return ""
+	case n == 1:
+		return " +1 more alert"
+	}
+	return fmt.Sprintf(" +%d more alerts", n)
+}
+
+// truncate shortens s to at most max characters, ending it with the
+// ellipsis. It cuts between characters, never within a UTF-8 sequence, and
+// keeps an accented letter or an emoji sequence, e.g. a flag or a family,
+// whole. With words, it cuts at the last space when there is one. A max of
+// 0 or less disables the truncation.
+func truncate(s string, max int, words bool, ellipsis string) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	limit := max - utf8.RuneCountInString(ellipsis)
+	if limit <= 0 {
+		return string([]rune(ellipsis)[:max])
+	}
+
+	runes := []rune(s)
+	n := limit
+	for n > 0 && !graphemeBoundary(runes, n) {
+		n--
+	}
+	if words {
+		for i := n; i > 0; i-- {
+			if unicode.IsSpace(runes[i]) {
+				n = i
+				break
+			}
+		}
+		for n > 0 && unicode.IsSpace(runes[n-1]) {
+			n--
+		}
+	}
+	return string(runes[:n]) + ellipsis
+}
+
+// graphemeBoundary reports whether runes can be cut before the rune at i
+// without splitting a user-perceived character
+func graphemeBoundary(runes []rune, i int) bool {
+	r := runes[i]
+	switch {
+	case unicode.Is(unicode.M, r), unicode.Is(unicode.Variation_Selector, r),
+		r == zeroWidthJoiner, r >= skinToneLight && r <= skinToneDark:
+		return false
+	case runes[i-1] == zeroWidthJoiner:
+		return false
+	case regionalIndicator(r):
+		// a flag starts at an even position of a run of regional indicators
+		run := 0
+		for j := i - 1; j >= 0 && regionalIndicator(runes[j]); j-- {
+			run++
+		}
+		return run%2 == 0
+	}
+	return true
+}
+
+func regionalIndicator(r rune) bool {
+	return r >= regionalIndicatorA && r <= regionalIndicatorZ
+}
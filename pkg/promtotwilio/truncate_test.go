@@ -0,0 +1,74 @@
+package promtotwilio
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"Disk full", 0, "Disk full"},
+		{"Disk full", 9, "Disk full"},
+		{"Disk full", 6, "Disk …"},
+		{"Disk full", 1, "…"},
+		{"Température élevée", 6, "Tempé…"},
+		{"Température élevée", 18, "Température élevée"},
+		// e followed by a combining acute accent
+		{"Cafe\u0301 ferme\u0301", 5, "Caf…"},
+		{"🔥🔥🔥 CPU high", 3, "🔥🔥…"},
+		// thumbs up with a skin tone modifier
+		{"ok 👍\U0001f3fd done", 5, "ok …"},
+		// family of three joined by zero width joiners
+		{"a👨\u200d👩\u200d👧 b", 4, "a…"},
+		{"🇫🇷🇩🇪 down", 3, "🇫🇷…"},
+		{"🇫🇷🇩🇪 down", 4, "🇫🇷…"},
+		{"❤\ufe0f love", 2, "…"},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.max, false, DefaultEllipsis)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) == %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) == %q, not valid UTF-8", tt.s, tt.max, got)
+		}
+		if tt.max > 0 && utf8.RuneCountInString(got) > tt.max {
+			t.Errorf("truncate(%q, %d) == %q, longer than %d", tt.s, tt.max, got, tt.max)
+		}
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	const s = "CPU high on db-1 since 10 minutes"
+	tests := []struct {
+		strategy string
+		ellipsis string
+		want     string
+	}{
+		{TruncateWord, "", "CPU high on db-1…"},
+		{TruncateWord, "...", "CPU high on db-1..."},
+		{TruncateChar, "", "CPU high on db-1 si…"},
+		{TruncateChar, " [cut]", "CPU high on db [cut]"},
+		{TruncateNone, "", s},
+	}
+	for _, tt := range tests {
+		o := &Config{MaxMessageLength: 20, TruncateStrategy: tt.strategy, TruncateEllipsis: tt.ellipsis}
+		if got := truncateMessage(o, s, ""); got != tt.want {
+			t.Errorf("truncateMessage(%s, %q) == %q, want %q", tt.strategy, tt.ellipsis, got, tt.want)
+		}
+	}
+
+	o := &Config{MaxMessageLength: 20, TruncateStrategy: TruncateWord}
+	if got, want := truncateMessage(o, s, moreAlerts(3)), "CPU… +3 more alerts"; got != want {
+		t.Errorf("truncateMessage(with more alerts) == %q, want %q", got, want)
+	}
+
+	o = &Config{MaxMessageLength: 6, TruncateStrategy: TruncateWord}
+	if got, want := truncateMessage(o, "Température", ""), "Tempé…"; got != want {
+		t.Errorf("truncateMessage(word without space) == %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package promtotwilio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const twilioAPIURL = "https://api.twilio.com"
+
+// TwilioClient sends text messages through Twilio
+type TwilioClient interface {
+	SendMessage(m *Message) (*MessageReceipt, error)
+}
+
+// Message is a text message to send
+type Message struct {
+	From string
+	To   string
+	Body string
+	// RequestID is the ID of the webhook request the message comes from
+	RequestID string
+	// StatusCallback, when set, is the URL Twilio posts the statuses of the
+	// message to
+	StatusCallback string
+}
+
+// MessageReceipt is what Twilio returns for an accepted message
+type MessageReceipt struct {
+	Sid      string
+	Status   string
+	Segments int
+	// Price is the cost of the message in the currency of the account, 0
+	// while Twilio doesn't know it yet, which is usual until it is delivered
+	Price float64
+}
+
+// TwilioError is an error returned by the Twilio API
+type TwilioError struct {
+	Status   int    `json:"status"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	MoreInfo string `json:"more_info"`
+	// RetryAfter is the delay requested by Twilio before trying again
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *TwilioError) Error() string {
+	return fmt.Sprintf("twilio error %d (status %d): %s", e.Code, e.Status, e.Message)
+}
+
+// TwilioHTTPClient is a TwilioClient calling the Twilio REST API
+type TwilioHTTPClient struct {
+	AccountSid string
+	AuthToken  string
+	// Subaccount is the SID of a subaccount of the account messages are
+	// sent from instead of the account itself
+	Subaccount string
+	BaseURL    string
+	HTTPClient *http.Client
+	// ValidityPeriod, when set, is how long Twilio tries to deliver a
+	// message before dropping it
+	ValidityPeriod time.Duration
+	// SmartEncoded lets Twilio replace unicode characters to fit messages
+	// in fewer segments
+	SmartEncoded bool
+}
+
+// NewTwilioHTTPClient returns a TwilioHTTPClient for the given account,
+// calling the API at baseURL, or the public one when empty
+func NewTwilioHTTPClient(accountSid, authToken, baseURL string) *TwilioHTTPClient {
+	if baseURL == "" {
+		baseURL = twilioAPIURL
+	}
+	return &TwilioHTTPClient{
+		AccountSid: accountSid,
+		AuthToken:  authToken,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// newTwilioHTTPClient returns a TwilioHTTPClient with the settings of the
+// configuration, using the transport
+func newTwilioHTTPClient(o *Config, transport http.RoundTripper) *TwilioHTTPClient {
+	client := NewTwilioHTTPClient(o.AccountSid, o.AuthToken, o.TwilioAPIURL)
+	client.HTTPClient.Transport = transport
+	if o.TwilioTimeout > 0 {
+		client.HTTPClient.Timeout = o.TwilioTimeout
+	}
+	client.ValidityPeriod = o.ValidityPeriod
+	client.SmartEncoded = o.SmartEncoding
+	return client
+}
+
+// newTwilioTransport returns the transport used to reach the Twilio API,
+// going through the configured proxy, or the one set by the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables. The connection settings
+// left unset keep the defaults of the standard library.
+func newTwilioTransport(o *Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.TwilioProxy != nil {
+		// credentials in the proxy URL are sent as Proxy-Authorization
+		transport.Proxy = http.ProxyURL(o.TwilioProxy)
+	}
+	if o.TwilioMaxIdleConns > 0 {
+		// all the connections go to the same host
+		transport.MaxIdleConns = o.TwilioMaxIdleConns
+		transport.MaxIdleConnsPerHost = o.TwilioMaxIdleConns
+	}
+	if o.TwilioIdleConnTimeout > 0 {
+		transport.IdleConnTimeout = o.TwilioIdleConnTimeout
+	}
+	if o.TwilioKeepAlive > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: o.TwilioKeepAlive}).DialContext
+	}
+	return transport
+}
+
+// SendMessage creates a message with the Twilio Messages API
+func (c *TwilioHTTPClient) SendMessage(m *Message) (*MessageReceipt, error) {
+	form := url.Values{}
+	form.Set("From", m.From)
+	form.Set("To", m.To)
+	form.Set("Body", m.Body)
+	if c.ValidityPeriod > 0 {
+		form.Set("ValidityPeriod", strconv.Itoa(int(c.ValidityPeriod/time.Second)))
+	}
+	if c.SmartEncoded {
+		form.Set("SmartEncoded", "true")
+	}
+	if m.StatusCallback != "" {
+		form.Set("StatusCallback", m.StatusCallback)
+	}
+
+	account := c.AccountSid
+	if c.Subaccount != "" {
+		account = c.Subaccount
+	}
+
+	endpoint := c.BaseURL + "/2010-04-01/Accounts/" + url.PathEscape(account) + "/Messages.json"
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.AccountSid, c.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if m.RequestID != "" {
+		req.Header.Set(requestIDHeader, m.RequestID)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return nil, newTwilioError(res, body)
+	}
+
+	var message struct {
+		Sid         string  `json:"sid"`
+		Status      string  `json:"status"`
+		NumSegments string  `json:"num_segments"`
+		Price       *string `json:"price"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("invalid twilio response: %v", err)
+	}
+
+	segments, _ := strconv.Atoi(message.NumSegments)
+	receipt := &MessageReceipt{
+		Sid:      message.Sid,
+		Status:   message.Status,
+		Segments: segments,
+	}
+	if message.Price != nil {
+		// Twilio reports the prices as negative amounts, e.g. "-0.00750"
+		price, _ := strconv.ParseFloat(*message.Price, 64)
+		receipt.Price = math.Abs(price)
+	}
+	return receipt, nil
+}
+
+// newTwilioError returns the error of a failed Twilio API response
+func newTwilioError(res *http.Response, body []byte) *TwilioError {
+	twilioErr := &TwilioError{Status: res.StatusCode}
+	twilioErr.RetryAfter = retryAfter(res.Header.Get("Retry-After"), time.Now())
+	if json.Unmarshal(body, twilioErr) != nil || twilioErr.Message == "" {
+		twilioErr.Message = http.StatusText(res.StatusCode)
+	}
+	return twilioErr
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date,
+// returning 0 when it is missing or invalid
+func retryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// TwilioAccount describes the account of the credentials
+type TwilioAccount struct {
+	FriendlyName string `json:"friendly_name"`
+	Status       string `json:"status"`
+}
+
+// Account fetches the account, checking the credentials
+func (c *TwilioHTTPClient) Account() (*TwilioAccount, error) {
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/2010-04-01/Accounts/"+url.PathEscape(c.AccountSid)+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.AccountSid, c.AuthToken)
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, newTwilioError(res, body)
+	}
+
+	var account TwilioAccount
+	if err := json.Unmarshal(body, &account); err != nil {
+		return nil, fmt.Errorf("invalid twilio response: %v", err)
+	}
+	return &account, nil
+}
@@ -0,0 +1,71 @@
+package promtotwilio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchdog warns when no webhook is received for timeout, e.g. while
+// Alertmanager's always firing Watchdog alert should keep coming, as the
+// alerting pipeline itself may be down
+type watchdog struct {
+	timeout time.Duration
+	send    func(text string) error
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	fired   bool
+	stopped bool
+}
+
+func newWatchdog(timeout time.Duration, send func(text string) error) *watchdog {
+	w := &watchdog{timeout: timeout, send: send}
+	w.timer = time.AfterFunc(timeout, w.fire)
+	return w
+}
+
+// kick records a webhook, sending a notice when it ends a silence
+func (w *watchdog) kick() {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.timer.Reset(w.timeout)
+	recovered := w.fired
+	w.fired = false
+	w.mu.Unlock()
+
+	if recovered {
+		if err := w.send("Alerting pipeline back: webhooks are received again"); err != nil {
+			log.Errorf("Error sending watchdog notice: %v", err)
+		}
+	}
+}
+
+func (w *watchdog) fire() {
+	w.mu.Lock()
+	if w.stopped || w.fired {
+		w.mu.Unlock()
+		return
+	}
+	w.fired = true
+	w.mu.Unlock()
+
+	log.Warnf("No webhook received for %s", w.timeout)
+	text := fmt.Sprintf("No alerts received from Alertmanager for %s, the alerting pipeline may be down", w.timeout)
+	if err := w.send(text); err != nil {
+		log.Errorf("Error sending watchdog warning: %v", err)
+	}
+}
+
+// Stop stops watching
+func (w *watchdog) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
@@ -0,0 +1,38 @@
+package promtotwilio
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		texts []string
+	)
+	w := newWatchdog(20*time.Millisecond, func(text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		texts = append(texts, text)
+		return nil
+	})
+	defer w.Stop()
+
+	time.Sleep(10 * time.Millisecond)
+	w.kick()
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	if len(texts) != 0 {
+		t.Errorf("watchdog fired while kicked: %q", texts)
+	}
+	mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+	w.kick()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(texts) != 2 {
+		t.Fatalf("watchdog sent %q, want a warning and a notice", texts)
+	}
+}
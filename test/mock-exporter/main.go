@@ -0,0 +1,227 @@
+// Command mock-exporter exposes gauges for Prometheus to alert on in
+// end-to-end tests, driven by hand or by scenarios simulating alert storms,
+// flapping alerts and alerts slowly resolving
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// stormSize is the number of instances going down at once in a storm
+const stormSize = 20
+
+// gauges are the series exposed with their initial, healthy, value
+var gauges = map[string]float64{
+	"mock_service_up":       1,
+	"mock_disk_usage_ratio": 0.5,
+	"mock_flapping_up":      1,
+	"mock_queue_size":       0,
+}
+
+// scenarios are the successive updates of the series of each scenario,
+// applied every step
+var scenarios = map[string][]map[string]float64{
+	"storm":        stormScenario(),
+	"flapping":     flappingScenario(5),
+	"slow-resolve": slowResolveScenario(),
+}
+
+func instance(i int) string {
+	return fmt.Sprintf(`mock_instance_up{instance="instance-%02d"}`, i)
+}
+
+func init() {
+	for i := 1; i <= stormSize; i++ {
+		gauges[instance(i)] = 1
+	}
+}
+
+// stormScenario takes every instance down at once, then back up
+func stormScenario() []map[string]float64 {
+	down, up := make(map[string]float64), make(map[string]float64)
+	for i := 1; i <= stormSize; i++ {
+		down[instance(i)] = 0
+		up[instance(i)] = 1
+	}
+	return []map[string]float64{down, {}, {}, {}, up}
+}
+
+// flappingScenario takes a service down and up again the given times
+func flappingScenario(times int) []map[string]float64 {
+	var steps []map[string]float64
+	for i := 0; i < times; i++ {
+		steps = append(steps, map[string]float64{"mock_flapping_up": 0}, map[string]float64{"mock_flapping_up": 1})
+	}
+	return steps
+}
+
+// slowResolveScenario fills a queue at once and then empties it slowly
+func slowResolveScenario() []map[string]float64 {
+	steps := []map[string]float64{{"mock_queue_size": 1000}}
+	for size := 900.0; size >= 0; size -= 100 {
+		steps = append(steps, map[string]float64{"mock_queue_size": size})
+	}
+	return steps
+}
+
+// exporter holds the values of the series and runs the scenarios
+type exporter struct {
+	step time.Duration
+
+	mu     sync.Mutex
+	values map[string]float64
+	// cancel stops the running scenario
+	cancel chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newExporter(step time.Duration) *exporter {
+	e := &exporter{step: step}
+	e.reset()
+	return e
+}
+
+func (e *exporter) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.values = make(map[string]float64, len(gauges))
+	for series, value := range gauges {
+		e.values[series] = value
+	}
+}
+
+func (e *exporter) set(updates map[string]float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for series, value := range updates {
+		e.values[series] = value
+	}
+}
+
+// run stops the running scenario and starts the given one
+func (e *exporter) run(steps []map[string]float64) {
+	e.stop()
+	cancel := make(chan struct{})
+	e.mu.Lock()
+	e.cancel = cancel
+	e.mu.Unlock()
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		for i, updates := range steps {
+			if i > 0 {
+				select {
+				case <-time.After(e.step):
+				case <-cancel:
+					return
+				}
+			}
+			e.set(updates)
+		}
+	}()
+}
+
+// stop stops the running scenario, if any
+func (e *exporter) stop() {
+	e.mu.Lock()
+	if e.cancel != nil {
+		close(e.cancel)
+		e.cancel = nil
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/metrics":
+		e.metrics(w)
+	case "/gauges":
+		e.setGauge(w, r)
+	case "/scenario":
+		e.scenario(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// metrics writes the series in the Prometheus text format
+func (e *exporter) metrics(w http.ResponseWriter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	series := make([]string, 0, len(e.values))
+	for s := range e.values {
+		series = append(series, s)
+	}
+	sort.Strings(series)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, s := range series {
+		fmt.Fprintf(w, "%s %g\n", s, e.values[s])
+	}
+}
+
+// setGauge sets the value of a series on POST /gauges?series=<series>&value=<value>
+func (e *exporter) setGauge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	series := r.FormValue("series")
+	value, err := strconv.ParseFloat(r.FormValue("value"), 64)
+	if series == "" || err != nil {
+		http.Error(w, "'series' and a numeric 'value' are required", http.StatusBadRequest)
+		return
+	}
+	e.set(map[string]float64{series: value})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scenario starts a scenario on POST /scenario?name=<name>, or stops it and
+// resets the series on DELETE /scenario
+func (e *exporter) scenario(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		steps, ok := scenarios[r.FormValue("name")]
+		if !ok {
+			http.Error(w, "unknown scenario, want one of storm, flapping or slow-resolve", http.StatusBadRequest)
+			return
+		}
+		log.Infof("Starting scenario %s", r.FormValue("name"))
+		e.run(steps)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		e.stop()
+		e.reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func main() {
+	step := 30 * time.Second
+	if value := os.Getenv("SCENARIO_STEP"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("'SCENARIO_STEP' must be a duration: %v", err)
+		}
+		step = d
+	}
+
+	addr := os.Getenv("LISTEN_ADDR")
+	if addr == "" {
+		addr = ":9100"
+	}
+	log.Infof("Listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, newExporter(step)))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScenarios(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"storm", `mock_instance_up{instance="instance-07"} 1`},
+		{"flapping", "mock_flapping_up 1"},
+		{"slow-resolve", "mock_queue_size 0"},
+	}
+	for _, test := range tests {
+		e := newExporter(0)
+		e.run(scenarios[test.name])
+		e.wg.Wait()
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body, _ := ioutil.ReadAll(rec.Body)
+		if !strings.Contains(string(body), test.expected+"\n") {
+			t.Errorf("scenario %s ends with %q, want %q", test.name, body, test.expected)
+		}
+	}
+}
+
+func TestSetGauge(t *testing.T) {
+	e := newExporter(0)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/gauges?series=mock_service_up&value=0", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("StatusCode == %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if e.values["mock_service_up"] != 0 {
+		t.Errorf("mock_service_up == %g, want 0", e.values["mock_service_up"])
+	}
+}
@@ -0,0 +1,248 @@
+// Command mock-twilio is a stand-in for the Twilio API in end-to-end tests:
+// it accepts messages, lists them on /messages and simulates the delivery
+// status callbacks of the messages sent with a StatusCallback
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// message is a message sent to the mock
+type message struct {
+	Sid            string   `json:"sid"`
+	AccountSid     string   `json:"account_sid"`
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	Body           string   `json:"body"`
+	Status         string   `json:"status"`
+	StatusCallback string   `json:"status_callback,omitempty"`
+	Callbacks      []string `json:"callbacks,omitempty"`
+
+	// authToken signs the status callbacks, like Twilio does with the auth
+	// token of the account
+	authToken string
+}
+
+// server records the messages and sends their status callbacks
+type server struct {
+	// delay is the time between two status callbacks of a message
+	delay time.Duration
+	// failing are the receivers whose messages fail to be delivered
+	failing map[string]bool
+	client  *http.Client
+
+	mu       sync.Mutex
+	messages []*message
+	wg       sync.WaitGroup
+}
+
+func newServer(delay time.Duration, failing []string) *server {
+	s := &server{
+		delay:   delay,
+		failing: make(map[string]bool),
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+	for _, receiver := range failing {
+		s.failing[receiver] = true
+	}
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/messages":
+		s.list(w, r)
+	case strings.HasPrefix(r.URL.Path, "/2010-04-01/Accounts/") && strings.HasSuffix(r.URL.Path, "/Messages.json"):
+		s.create(w, r)
+	case strings.HasPrefix(r.URL.Path, "/2010-04-01/Accounts/") && strings.HasSuffix(r.URL.Path, ".json"):
+		s.account(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// create accepts a message like the Twilio API does
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("To") == "" || r.FormValue("Body") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"code":    21604,
+			"message": "A 'To' phone number and a 'Body' are required.",
+			"status":  http.StatusBadRequest,
+		})
+		return
+	}
+
+	s.mu.Lock()
+	m := &message{
+		Sid:            fmt.Sprintf("SM%032d", len(s.messages)+1),
+		AccountSid:     strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/2010-04-01/Accounts/"), "/Messages.json"),
+		From:           r.FormValue("From"),
+		To:             r.FormValue("To"),
+		Body:           r.FormValue("Body"),
+		Status:         "queued",
+		StatusCallback: r.FormValue("StatusCallback"),
+	}
+	_, m.authToken, _ = r.BasicAuth()
+	s.messages = append(s.messages, m)
+	s.mu.Unlock()
+	log.Infof("Message %s to %s: %s", m.Sid, m.To, m.Body)
+
+	// snapshotted before the callbacks update the status
+	response := map[string]interface{}{
+		"sid":          m.Sid,
+		"account_sid":  m.AccountSid,
+		"from":         m.From,
+		"to":           m.To,
+		"body":         m.Body,
+		"status":       m.Status,
+		"num_segments": "1",
+	}
+	if m.StatusCallback != "" {
+		s.wg.Add(1)
+		go s.callbacks(m)
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// account describes the account like the Twilio API does
+func (s *server) account(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sid":           strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/2010-04-01/Accounts/"), ".json"),
+		"friendly_name": "Mock account",
+		"status":        "active",
+	})
+}
+
+// callbacks posts the statuses a message goes through to its StatusCallback
+func (s *server) callbacks(m *message) {
+	defer s.wg.Done()
+	statuses := []string{"queued", "sent", "delivered"}
+	if s.failing[m.To] {
+		statuses = []string{"queued", "failed"}
+	}
+	for _, status := range statuses {
+		time.Sleep(s.delay)
+		form := url.Values{
+			"MessageSid":    {m.Sid},
+			"SmsSid":        {m.Sid},
+			"AccountSid":    {m.AccountSid},
+			"From":          {m.From},
+			"To":            {m.To},
+			"MessageStatus": {status},
+			"SmsStatus":     {status},
+		}
+		if status == "failed" {
+			form.Set("ErrorCode", "30003")
+		}
+
+		s.mu.Lock()
+		m.Status = status
+		m.Callbacks = append(m.Callbacks, status)
+		s.mu.Unlock()
+
+		req, err := http.NewRequest(http.MethodPost, m.StatusCallback, strings.NewReader(form.Encode()))
+		if err != nil {
+			log.Errorf("Error sending status callback of %s: %v", m.Sid, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Twilio-Signature", signature(m.authToken, m.StatusCallback, form))
+		resp, err := s.client.Do(req)
+		if err != nil {
+			log.Errorf("Error sending status callback of %s: %v", m.Sid, err)
+			return
+		}
+		resp.Body.Close()
+	}
+}
+
+// signature returns the X-Twilio-Signature of a request to the URL with the
+// form, signed with the auth token
+func signature(authToken, callbackURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(callbackURL))
+	for _, key := range keys {
+		mac.Write([]byte(key + form.Get(key)))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// list returns the messages sent, or forgets them on DELETE
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		messages := s.messages
+		if messages == nil {
+			messages = []*message{}
+		}
+		writeJSON(w, http.StatusOK, messages)
+	case http.MethodDelete:
+		s.messages = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Error writing response: %v", err)
+	}
+}
+
+func main() {
+	delay := 500 * time.Millisecond
+	if value := os.Getenv("CALLBACK_DELAY"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("'CALLBACK_DELAY' must be a duration: %v", err)
+		}
+		delay = d
+	}
+	var failing []string
+	if value := os.Getenv("FAILING_RECEIVERS"); value != "" {
+		failing = strings.Split(value, ",")
+	}
+
+	addr := os.Getenv("LISTEN_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	log.Infof("Listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, newServer(delay, failing)))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestStatusCallbacks(t *testing.T) {
+	var mu sync.Mutex
+	statuses := make(map[string][]string)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[r.FormValue("To")] = append(statuses[r.FormValue("To")], r.FormValue("MessageStatus"))
+	}))
+	defer callback.Close()
+
+	s := newServer(0, []string{"+300"})
+	mock := httptest.NewServer(s)
+	defer mock.Close()
+
+	for _, to := range []string{"+200", "+300"} {
+		resp, err := http.PostForm(mock.URL+"/2010-04-01/Accounts/AC123/Messages.json", url.Values{
+			"From":           {"+100"},
+			"To":             {to},
+			"Body":           {"Disk full"},
+			"StatusCallback": {callback.URL},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("StatusCode == %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+	}
+	s.wg.Wait()
+
+	expected := map[string][]string{
+		"+200": {"queued", "sent", "delivered"},
+		"+300": {"queued", "failed"},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("statuses == %v, want %v", statuses, expected)
+	}
+
+	resp, err := http.Get(mock.URL + "/messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var messages []message
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Status != "delivered" || messages[1].Status != "failed" {
+		t.Errorf("messages == %+v", messages)
+	}
+}